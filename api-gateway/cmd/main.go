@@ -3,40 +3,117 @@ package main
 import (
 	"fmt"
 	"log"
-	"os"
 
+	"go-microservices/api-gateway/config"
 	"go-microservices/api-gateway/internal/handlers"
+	"go-microservices/api-gateway/internal/middlewares"
+	"go-microservices/api-gateway/internal/problem"
+	"go-microservices/api-gateway/internal/ratelimit"
 	"go-microservices/api-gateway/internal/routes"
+	"go-microservices/pkg/grpcx"
 
 	"go-microservices/api-gateway/internal/clients"
 
 	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/requestid"
+	"github.com/redis/go-redis/v9"
 	"google.golang.org/grpc"
 )
 
 func main() {
 	fmt.Println("Starting API Gateway...")
-	app := fiber.New()
+	env := config.LoadEnv()
+	if err := env.Validate(); err != nil {
+		log.Fatalf("Invalid configuration: %v", err)
+	}
+	app := fiber.New(fiber.Config{
+		ErrorHandler: problem.ErrorHandler,
+		// The proxy header is only read from trusted proxies; it is ignored
+		// entirely when no header is configured.
+		ProxyHeader:             env.ProxyHeader,
+		EnableTrustedProxyCheck: env.ProxyHeader != "",
+		TrustedProxies:          env.TrustedProxies,
+	})
 
-	// Connect to AuthService gRPC
-	authServiceAddr := os.Getenv("AUTH_SERVICE_GRPC")
+	// Every request gets an ID and a deadline for its backend calls; routes
+	// doing slow work (password hashing) override the deadline.
+	app.Use(requestid.New())
+	app.Use(middlewares.Timeout(env.RPCTimeout))
+
+	// Rate-limit buckets live in Redis when configured so every replica
+	// enforces the same limits.
+	var limitStore ratelimit.Store = ratelimit.NewMemoryStore()
+	if env.RedisAddr != "" {
+		rdb := redis.NewClient(&redis.Options{
+			Addr:     env.RedisAddr,
+			Password: env.RedisPassword,
+			DB:       env.RedisDB,
+		})
+		defer rdb.Close()
+		limitStore = ratelimit.NewRedisStore(rdb)
+		log.Printf("Sharing rate limits through Redis at %s", env.RedisAddr)
+	}
+	app.Use(middlewares.RateLimit(limitStore, middlewares.RatePolicy{
+		Name:  "global",
+		Limit: ratelimit.PerMinute(env.RateLimitPerMinute),
+		Key:   middlewares.ByIP,
+	}))
+	limits := routes.Limits{
+		Credentials: middlewares.RateLimit(limitStore, middlewares.RatePolicy{
+			Name:  "credentials",
+			Limit: ratelimit.PerMinute(env.AuthRateLimitPerMinute),
+			Key:   middlewares.ByIP,
+		}),
+		PerUser: middlewares.RateLimit(limitStore, middlewares.RatePolicy{
+			Name:  "user",
+			Limit: ratelimit.PerMinute(env.UserRateLimitPerMinute),
+			Key:   middlewares.ByAPIKey,
+		}),
+	}
+
+	// Every call carries the gateway secret, which is what lets the services
+	// believe the caller metadata the gateway forwards.
+	dialOpts := []grpc.DialOption{
+		grpc.WithInsecure(),
+		grpc.WithUnaryInterceptor(grpcx.SendGatewaySecret(env.GatewaySecret)),
+	}
 
-	conn, err := grpc.Dial(authServiceAddr, grpc.WithInsecure())
+	// Connect to AuthService gRPC
+	conn, err := grpc.Dial(env.AuthServiceGRPC, dialOpts...)
 	if err != nil {
 		log.Fatalf("Failed to connect to AuthService: %v", err)
 	}
 	defer conn.Close()
 
+	// Connect to UserService gRPC
+	userConn, err := grpc.Dial(env.UserServiceGRPC, dialOpts...)
+	if err != nil {
+		log.Fatalf("Failed to connect to UserService: %v", err)
+	}
+	defer userConn.Close()
+
+	// Connect to PostService gRPC
+	postConn, err := grpc.Dial(env.PostServiceGRPC, dialOpts...)
+	if err != nil {
+		log.Fatalf("Failed to connect to PostService: %v", err)
+	}
+	defer postConn.Close()
+
 	authClient := clients.NewAuthClient(conn)
 	authHandler := handlers.NewAuthHandler(authClient)
+	userHandler := handlers.NewUserHandler(clients.NewUserClient(userConn))
+	postHandler := handlers.NewPostHandler(clients.NewPostClient(postConn))
 
-	routes.RegisterAuthRoutes(app, authHandler)
+	requireAuth := middlewares.JWTMiddleware(authClient, middlewares.JWTConfig{
+		CacheTTL:  env.JWTCacheTTL,
+		CacheSize: env.JWTCacheSize,
+	})
 
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = "8080"
-	}
-	log.Printf("API Gateway listening on :%s", port)
+	routes.RegisterAuthRoutes(app, authHandler, requireAuth, limits)
+	routes.RegisterUserRoutes(app, userHandler, requireAuth, limits)
+	routes.RegisterPostRoutes(app, postHandler, requireAuth, limits)
+
+	log.Printf("API Gateway listening on :%s", env.Port)
 
-	log.Fatal(app.Listen(":" + port))
+	log.Fatal(app.Listen(":" + env.Port))
 }
@@ -1,36 +1,130 @@
 package config
 
-// import (
-// 	"os"
-// 	"strconv"
-// )
-
-// type Env struct {
-// 	Port               string
-// 	AuthServiceURL     string
-// 	UserServiceURL     string
-// 	PostServiceURL     string
-// 	CommentServiceURL  string
-// 	LikeServiceURL     string
-// 	FollowServiceURL   string
-// 	NotificationURL    string
-// 	RateLimitPerMinute int
-// }
-
-// func LoadEnv() *Env {
-// 	rateLimit, err := strconv.Atoi(os.Getenv("RATE_LIMIT_PER_MINUTE"))
-// 	if err != nil {
-// 		rateLimit = 60 // default value
-// 	}
-// 	return &Env{
-// 		Port:               getEnv("PORT", "8080"),
-// 		AuthServiceURL:     getEnv("AUTH_SERVICE_URL", "http://localhost:8001"),
-// 		UserServiceURL:     getEnv("USER_SERVICE_URL", "http://localhost:8002"),
-// 		PostServiceURL:     getEnv("POST_SERVICE_URL", "http://localhost:8003"),
-// 		CommentServiceURL:  getEnv("COMMENT_SERVICE_URL", "http://localhost:8004"),
-// 		LikeServiceURL:     getEnv("LIKE_SERVICE_URL", "http://localhost:8005"),
-// 		FollowServiceURL:   getEnv("FOLLOW_SERVICE_URL", "http://localhost:8006"),
-// 		NotificationURL:    getEnv("NOTIFICATION_SERVICE_URL", "http://localhost:8007"),
-// 		RateLimitPerMinute: rateLimit,
-// 	}
-// }
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type Env struct {
+	Port            string
+	AuthServiceGRPC string
+	UserServiceGRPC string
+	PostServiceGRPC string
+
+	// JWTCacheTTL and JWTCacheSize bound the cache of validated tokens.
+	JWTCacheTTL  time.Duration
+	JWTCacheSize int
+	// RPCTimeout is the default deadline for backend calls of a request.
+	RPCTimeout time.Duration
+	// GatewaySecret authenticates the gateway to the services, which only
+	// believe the caller metadata of calls carrying it.
+	GatewaySecret string
+
+	// ProxyHeader names the header carrying the client IP when the gateway
+	// runs behind a proxy, e.g. X-Forwarded-For. Empty uses the peer address.
+	// The header is only believed from TrustedProxies, a list of IPs and
+	// CIDR ranges; requests from any other peer are keyed by their address.
+	ProxyHeader    string
+	TrustedProxies []string
+
+	// RateLimitPerMinute is the global per-IP limit. AuthRateLimitPerMinute
+	// applies per IP to endpoints taking credentials, and
+	// UserRateLimitPerMinute per caller to authenticated endpoints. Zero
+	// disables a limit.
+	RateLimitPerMinute     int
+	AuthRateLimitPerMinute int
+	UserRateLimitPerMinute int
+
+	// RedisAddr enables shared rate-limit buckets across gateway replicas.
+	RedisAddr     string
+	RedisPassword string
+	RedisDB       int
+}
+
+func LoadEnv() *Env {
+	return &Env{
+		Port:            getEnv("PORT", "8080"),
+		AuthServiceGRPC: os.Getenv("AUTH_SERVICE_GRPC"),
+		UserServiceGRPC: os.Getenv("USER_SERVICE_GRPC"),
+		PostServiceGRPC: os.Getenv("POST_SERVICE_GRPC"),
+
+		JWTCacheTTL:  getEnvDuration("JWT_CACHE_TTL", 30*time.Second),
+		JWTCacheSize: getEnvInt("JWT_CACHE_SIZE", 10000),
+		RPCTimeout:   getEnvDuration("RPC_TIMEOUT", 5*time.Second),
+
+		GatewaySecret: os.Getenv("GATEWAY_SECRET"),
+
+		ProxyHeader:    os.Getenv("PROXY_HEADER"),
+		TrustedProxies: getEnvList("TRUSTED_PROXIES"),
+
+		RateLimitPerMinute:     getEnvInt("RATE_LIMIT_PER_MINUTE", 300),
+		AuthRateLimitPerMinute: getEnvInt("AUTH_RATE_LIMIT_PER_MINUTE", 5),
+		UserRateLimitPerMinute: getEnvInt("USER_RATE_LIMIT_PER_MINUTE", 60),
+
+		RedisAddr:     os.Getenv("REDIS_ADDR"),
+		RedisPassword: os.Getenv("REDIS_PASSWORD"),
+		RedisDB:       getEnvInt("REDIS_DB", 0),
+	}
+}
+
+// Validate reports settings that cannot work together.
+func (e *Env) Validate() error {
+	if e.GatewaySecret == "" {
+		return errors.New("GATEWAY_SECRET is required")
+	}
+	limits := []struct {
+		name  string
+		value int
+	}{
+		{"RATE_LIMIT_PER_MINUTE", e.RateLimitPerMinute},
+		{"AUTH_RATE_LIMIT_PER_MINUTE", e.AuthRateLimitPerMinute},
+		{"USER_RATE_LIMIT_PER_MINUTE", e.UserRateLimitPerMinute},
+	}
+	for _, l := range limits {
+		if l.value < 0 {
+			return fmt.Errorf("%s must not be negative, use 0 for no limit", l.name)
+		}
+	}
+	if e.ProxyHeader != "" && len(e.TrustedProxies) == 0 {
+		return errors.New("PROXY_HEADER requires TRUSTED_PROXIES, otherwise any client can choose its IP")
+	}
+	return nil
+}
+
+func getEnv(key, fallback string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return fallback
+}
+
+func getEnvInt(key string, fallback int) int {
+	v, err := strconv.Atoi(os.Getenv(key))
+	if err != nil {
+		return fallback
+	}
+	return v
+}
+
+func getEnvDuration(key string, fallback time.Duration) time.Duration {
+	v, err := time.ParseDuration(os.Getenv(key))
+	if err != nil {
+		return fallback
+	}
+	return v
+}
+
+// getEnvList splits a comma-separated variable, dropping empty entries.
+func getEnvList(key string) []string {
+	var list []string
+	for _, v := range strings.Split(os.Getenv(key), ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			list = append(list, v)
+		}
+	}
+	return list
+}
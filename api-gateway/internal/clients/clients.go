@@ -27,14 +27,162 @@ func (a *AuthClient) SignIn(ctx context.Context, req *pb.SignInRequest) (*pb.Sig
 	return a.client.SignIn(ctx, req)
 }
 
+func (a *AuthClient) VerifyMFA(ctx context.Context, req *pb.VerifyMFARequest) (*pb.VerifyMFAResponse, error) {
+	return a.client.VerifyMFA(ctx, req)
+}
+
+func (a *AuthClient) RequestMagicLink(ctx context.Context, req *pb.RequestMagicLinkRequest) (*pb.RequestMagicLinkResponse, error) {
+	return a.client.RequestMagicLink(ctx, req)
+}
+
+func (a *AuthClient) ConsumeMagicLink(ctx context.Context, req *pb.ConsumeMagicLinkRequest) (*pb.ConsumeMagicLinkResponse, error) {
+	return a.client.ConsumeMagicLink(ctx, req)
+}
+
+func (a *AuthClient) BeginTOTPEnrollment(ctx context.Context, req *pb.BeginTOTPEnrollmentRequest) (*pb.BeginTOTPEnrollmentResponse, error) {
+	return a.client.BeginTOTPEnrollment(ctx, req)
+}
+
+func (a *AuthClient) ConfirmTOTPEnrollment(ctx context.Context, req *pb.ConfirmTOTPEnrollmentRequest) (*pb.ConfirmTOTPEnrollmentResponse, error) {
+	return a.client.ConfirmTOTPEnrollment(ctx, req)
+}
+
+func (a *AuthClient) RefreshToken(ctx context.Context, req *pb.RefreshTokenRequest) (*pb.RefreshTokenResponse, error) {
+	return a.client.RefreshToken(ctx, req)
+}
+
+func (a *AuthClient) SignOut(ctx context.Context, req *pb.SignOutRequest) (*pb.SignOutResponse, error) {
+	return a.client.SignOut(ctx, req)
+}
+
+func (a *AuthClient) RevokeAllSessions(ctx context.Context, req *pb.RevokeAllSessionsRequest) (*pb.RevokeAllSessionsResponse, error) {
+	return a.client.RevokeAllSessions(ctx, req)
+}
+
 func (a *AuthClient) ValidateToken(ctx context.Context, req *pb.ValidateTokenRequest) (*pb.ValidateTokenResponse, error) {
 	return a.client.ValidateToken(ctx, req)
 }
 
+func (a *AuthClient) GetJWKS(ctx context.Context, req *pb.GetJWKSRequest) (*pb.GetJWKSResponse, error) {
+	return a.client.GetJWKS(ctx, req)
+}
+
 func (a *AuthClient) GetUserInfo(ctx context.Context, req *pb.GetUserInfoRequest) (*pb.GetUserInfoResponse, error) {
 	return a.client.GetUserInfo(ctx, req)
 }
 
+func (a *AuthClient) ConfirmEmail(ctx context.Context, req *pb.ConfirmEmailRequest) (*pb.ConfirmEmailResponse, error) {
+	return a.client.ConfirmEmail(ctx, req)
+}
+
+func (a *AuthClient) AssignRole(ctx context.Context, req *pb.AssignRoleRequest) (*pb.AssignRoleResponse, error) {
+	return a.client.AssignRole(ctx, req)
+}
+
+func (a *AuthClient) RevokeRole(ctx context.Context, req *pb.RevokeRoleRequest) (*pb.RevokeRoleResponse, error) {
+	return a.client.RevokeRole(ctx, req)
+}
+
+func (a *AuthClient) ListRoles(ctx context.Context, req *pb.ListRolesRequest) (*pb.ListRolesResponse, error) {
+	return a.client.ListRoles(ctx, req)
+}
+
+func (a *AuthClient) UnlockAccount(ctx context.Context, req *pb.UnlockAccountRequest) (*pb.UnlockAccountResponse, error) {
+	return a.client.UnlockAccount(ctx, req)
+}
+
+func (a *AuthClient) CreateAPIKey(ctx context.Context, req *pb.CreateAPIKeyRequest) (*pb.CreateAPIKeyResponse, error) {
+	return a.client.CreateAPIKey(ctx, req)
+}
+
+func (a *AuthClient) ListAPIKeys(ctx context.Context, req *pb.ListAPIKeysRequest) (*pb.ListAPIKeysResponse, error) {
+	return a.client.ListAPIKeys(ctx, req)
+}
+
+func (a *AuthClient) RevokeAPIKey(ctx context.Context, req *pb.RevokeAPIKeyRequest) (*pb.RevokeAPIKeyResponse, error) {
+	return a.client.RevokeAPIKey(ctx, req)
+}
+
+func (a *AuthClient) ValidateAPIKey(ctx context.Context, req *pb.ValidateAPIKeyRequest) (*pb.ValidateAPIKeyResponse, error) {
+	return a.client.ValidateAPIKey(ctx, req)
+}
+
+func (a *AuthClient) CreateOAuthClient(ctx context.Context, req *pb.CreateOAuthClientRequest) (*pb.CreateOAuthClientResponse, error) {
+	return a.client.CreateOAuthClient(ctx, req)
+}
+
+func (a *AuthClient) ListOAuthClients(ctx context.Context, req *pb.ListOAuthClientsRequest) (*pb.ListOAuthClientsResponse, error) {
+	return a.client.ListOAuthClients(ctx, req)
+}
+
+func (a *AuthClient) DeleteOAuthClient(ctx context.Context, req *pb.DeleteOAuthClientRequest) (*pb.DeleteOAuthClientResponse, error) {
+	return a.client.DeleteOAuthClient(ctx, req)
+}
+
+func (a *AuthClient) GetOpenIDConfiguration(ctx context.Context, req *pb.GetOpenIDConfigurationRequest) (*pb.GetOpenIDConfigurationResponse, error) {
+	return a.client.GetOpenIDConfiguration(ctx, req)
+}
+
+func (a *AuthClient) Authorize(ctx context.Context, req *pb.AuthorizeRequest) (*pb.AuthorizeResponse, error) {
+	return a.client.Authorize(ctx, req)
+}
+
+func (a *AuthClient) Token(ctx context.Context, req *pb.TokenRequest) (*pb.TokenResponse, error) {
+	return a.client.Token(ctx, req)
+}
+
+func (a *AuthClient) IntrospectToken(ctx context.Context, req *pb.IntrospectTokenRequest) (*pb.IntrospectTokenResponse, error) {
+	return a.client.IntrospectToken(ctx, req)
+}
+
+func (a *AuthClient) ListSessions(ctx context.Context, req *pb.ListSessionsRequest) (*pb.ListSessionsResponse, error) {
+	return a.client.ListSessions(ctx, req)
+}
+
+func (a *AuthClient) TerminateSession(ctx context.Context, req *pb.TerminateSessionRequest) (*pb.TerminateSessionResponse, error) {
+	return a.client.TerminateSession(ctx, req)
+}
+
+func (a *AuthClient) Impersonate(ctx context.Context, req *pb.ImpersonateRequest) (*pb.ImpersonateResponse, error) {
+	return a.client.Impersonate(ctx, req)
+}
+
+func (a *AuthClient) GetOpenIDUserInfo(ctx context.Context, req *pb.GetOpenIDUserInfoRequest) (*pb.GetOpenIDUserInfoResponse, error) {
+	return a.client.GetOpenIDUserInfo(ctx, req)
+}
+
+func (a *AuthClient) ResendConfirmation(ctx context.Context, req *pb.ResendConfirmationRequest) (*pb.ResendConfirmationResponse, error) {
+	return a.client.ResendConfirmation(ctx, req)
+}
+
+func (a *AuthClient) RequestPasswordReset(ctx context.Context, req *pb.RequestPasswordResetRequest) (*pb.RequestPasswordResetResponse, error) {
+	return a.client.RequestPasswordReset(ctx, req)
+}
+
+func (a *AuthClient) ResetPassword(ctx context.Context, req *pb.ResetPasswordRequest) (*pb.ResetPasswordResponse, error) {
+	return a.client.ResetPassword(ctx, req)
+}
+
+func (a *AuthClient) ChangePassword(ctx context.Context, req *pb.ChangePasswordRequest) (*pb.ChangePasswordResponse, error) {
+	return a.client.ChangePassword(ctx, req)
+}
+
+func (a *AuthClient) ChangeEmail(ctx context.Context, req *pb.ChangeEmailRequest) (*pb.ChangeEmailResponse, error) {
+	return a.client.ChangeEmail(ctx, req)
+}
+
+func (a *AuthClient) ConfirmEmailChange(ctx context.Context, req *pb.ConfirmEmailChangeRequest) (*pb.ConfirmEmailChangeResponse, error) {
+	return a.client.ConfirmEmailChange(ctx, req)
+}
+
+func (a *AuthClient) ChangeUsername(ctx context.Context, req *pb.ChangeUsernameRequest) (*pb.ChangeUsernameResponse, error) {
+	return a.client.ChangeUsername(ctx, req)
+}
+
+func (a *AuthClient) DeleteAccount(ctx context.Context, req *pb.DeleteAccountRequest) (*pb.DeleteAccountResponse, error) {
+	return a.client.DeleteAccount(ctx, req)
+}
+
 func (a *AuthClient) CreateTest(ctx context.Context, req *pb.CreateTestRequest) (*pb.CreateTestResponse, error) {
 	return a.client.CreateTest(ctx, req)
 }
@@ -0,0 +1,93 @@
+package handlers
+
+import (
+	"net/http"
+
+	"go-microservices/api-gateway/internal/middlewares"
+	"go-microservices/api-gateway/internal/problem"
+	pb "go-microservices/proto/auth"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// ChangePassword changes the caller's password given the current one. The
+// caller's other sessions are signed out.
+func (h *AuthHandler) ChangePassword(c *fiber.Ctx) error {
+	var req pb.ChangePasswordRequest
+	if err := c.BodyParser(&req); err != nil {
+		return problem.Write(c, http.StatusBadRequest, "invalid request body")
+	}
+	req.UserId, _ = c.Locals("userID").(string)
+	req.SessionId, _ = c.Locals("sessionID").(string)
+	ctx, cancel := middlewares.OutgoingContext(c)
+	defer cancel()
+	resp, err := h.AuthClient.ChangePassword(ctx, &req)
+	if err != nil {
+		return problem.FromGRPC(c, err)
+	}
+	return c.JSON(resp)
+}
+
+// ChangeEmail emails a confirmation link to the caller's new address.
+func (h *AuthHandler) ChangeEmail(c *fiber.Ctx) error {
+	var req pb.ChangeEmailRequest
+	if err := c.BodyParser(&req); err != nil {
+		return problem.Write(c, http.StatusBadRequest, "invalid request body")
+	}
+	req.UserId, _ = c.Locals("userID").(string)
+	ctx, cancel := middlewares.OutgoingContext(c)
+	defer cancel()
+	resp, err := h.AuthClient.ChangeEmail(ctx, &req)
+	if err != nil {
+		return problem.FromGRPC(c, err)
+	}
+	return c.Status(http.StatusAccepted).JSON(resp)
+}
+
+// ConfirmEmailChange completes an email change with the token from the
+// confirmation link.
+func (h *AuthHandler) ConfirmEmailChange(c *fiber.Ctx) error {
+	var req pb.ConfirmEmailChangeRequest
+	if err := c.BodyParser(&req); err != nil {
+		return problem.Write(c, http.StatusBadRequest, "invalid request body")
+	}
+	ctx, cancel := middlewares.OutgoingContext(c)
+	defer cancel()
+	resp, err := h.AuthClient.ConfirmEmailChange(ctx, &req)
+	if err != nil {
+		return problem.FromGRPC(c, err)
+	}
+	return c.JSON(resp)
+}
+
+func (h *AuthHandler) ChangeUsername(c *fiber.Ctx) error {
+	var req pb.ChangeUsernameRequest
+	if err := c.BodyParser(&req); err != nil {
+		return problem.Write(c, http.StatusBadRequest, "invalid request body")
+	}
+	req.UserId, _ = c.Locals("userID").(string)
+	ctx, cancel := middlewares.OutgoingContext(c)
+	defer cancel()
+	resp, err := h.AuthClient.ChangeUsername(ctx, &req)
+	if err != nil {
+		return problem.FromGRPC(c, err)
+	}
+	return c.JSON(resp)
+}
+
+// DeleteAccount permanently deletes the caller's account, and with it their
+// profile, given their password.
+func (h *AuthHandler) DeleteAccount(c *fiber.Ctx) error {
+	var req pb.DeleteAccountRequest
+	if err := c.BodyParser(&req); err != nil {
+		return problem.Write(c, http.StatusBadRequest, "invalid request body")
+	}
+	req.UserId, _ = c.Locals("userID").(string)
+	ctx, cancel := middlewares.OutgoingContext(c)
+	defer cancel()
+	resp, err := h.AuthClient.DeleteAccount(ctx, &req)
+	if err != nil {
+		return problem.FromGRPC(c, err)
+	}
+	return c.JSON(resp)
+}
@@ -0,0 +1,52 @@
+package handlers
+
+import (
+	"net/http"
+
+	"go-microservices/api-gateway/internal/middlewares"
+	"go-microservices/api-gateway/internal/problem"
+	pb "go-microservices/proto/auth"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// CreateAPIKey issues an API key for the caller. The key is in the response
+// and cannot be retrieved again.
+func (h *AuthHandler) CreateAPIKey(c *fiber.Ctx) error {
+	var req pb.CreateAPIKeyRequest
+	if err := c.BodyParser(&req); err != nil {
+		return problem.Write(c, http.StatusBadRequest, "invalid request body")
+	}
+	req.UserId, _ = c.Locals("userID").(string)
+	ctx, cancel := middlewares.OutgoingContext(c)
+	defer cancel()
+	resp, err := h.AuthClient.CreateAPIKey(ctx, &req)
+	if err != nil {
+		return problem.FromGRPC(c, err)
+	}
+	return c.Status(http.StatusCreated).JSON(resp)
+}
+
+// ListAPIKeys lists the caller's API keys without their secrets.
+func (h *AuthHandler) ListAPIKeys(c *fiber.Ctx) error {
+	userID, _ := c.Locals("userID").(string)
+	ctx, cancel := middlewares.OutgoingContext(c)
+	defer cancel()
+	resp, err := h.AuthClient.ListAPIKeys(ctx, &pb.ListAPIKeysRequest{UserId: userID})
+	if err != nil {
+		return problem.FromGRPC(c, err)
+	}
+	return c.JSON(resp)
+}
+
+// RevokeAPIKey revokes the caller's API key in the :id path parameter.
+func (h *AuthHandler) RevokeAPIKey(c *fiber.Ctx) error {
+	userID, _ := c.Locals("userID").(string)
+	ctx, cancel := middlewares.OutgoingContext(c)
+	defer cancel()
+	resp, err := h.AuthClient.RevokeAPIKey(ctx, &pb.RevokeAPIKeyRequest{UserId: userID, Id: c.Params("id")})
+	if err != nil {
+		return problem.FromGRPC(c, err)
+	}
+	return c.JSON(resp)
+}
@@ -1,10 +1,11 @@
 package handlers
 
 import (
-	"context"
 	"net/http"
 
 	"go-microservices/api-gateway/internal/clients"
+	"go-microservices/api-gateway/internal/middlewares"
+	"go-microservices/api-gateway/internal/problem"
 	pb "go-microservices/proto/auth"
 
 	"github.com/gofiber/fiber/v2"
@@ -21,11 +22,13 @@ func NewAuthHandler(authClient *clients.AuthClient) *AuthHandler {
 func (h *AuthHandler) SignUp(c *fiber.Ctx) error {
 	var req pb.SignUpRequest
 	if err := c.BodyParser(&req); err != nil {
-		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "invalid request"})
+		return problem.Write(c, http.StatusBadRequest, "invalid request body")
 	}
-	resp, err := h.AuthClient.SignUp(context.Background(), &req)
+	ctx, cancel := middlewares.OutgoingContext(c)
+	defer cancel()
+	resp, err := h.AuthClient.SignUp(ctx, &req)
 	if err != nil {
-		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+		return problem.FromGRPC(c, err)
 	}
 	return c.JSON(resp)
 }
@@ -33,11 +36,91 @@ func (h *AuthHandler) SignUp(c *fiber.Ctx) error {
 func (h *AuthHandler) SignIn(c *fiber.Ctx) error {
 	var req pb.SignInRequest
 	if err := c.BodyParser(&req); err != nil {
-		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "invalid request"})
+		return problem.Write(c, http.StatusBadRequest, "invalid request body")
 	}
-	resp, err := h.AuthClient.SignIn(context.Background(), &req)
+	ctx, cancel := middlewares.OutgoingContext(c)
+	defer cancel()
+	resp, err := h.AuthClient.SignIn(ctx, &req)
 	if err != nil {
-		return c.Status(http.StatusUnauthorized).JSON(fiber.Map{"error": err.Error()})
+		return problem.FromGRPC(c, err)
+	}
+	return c.JSON(resp)
+}
+
+// RequestMagicLink emails a passwordless sign-in link.
+func (h *AuthHandler) RequestMagicLink(c *fiber.Ctx) error {
+	var req pb.RequestMagicLinkRequest
+	if err := c.BodyParser(&req); err != nil {
+		return problem.Write(c, http.StatusBadRequest, "invalid request body")
+	}
+	ctx, cancel := middlewares.OutgoingContext(c)
+	defer cancel()
+	resp, err := h.AuthClient.RequestMagicLink(ctx, &req)
+	if err != nil {
+		return problem.FromGRPC(c, err)
+	}
+	return c.JSON(resp)
+}
+
+// ConsumeMagicLink exchanges the token from a sign-in link for the same
+// response SignIn gives.
+func (h *AuthHandler) ConsumeMagicLink(c *fiber.Ctx) error {
+	var req pb.ConsumeMagicLinkRequest
+	if err := c.BodyParser(&req); err != nil {
+		return problem.Write(c, http.StatusBadRequest, "invalid request body")
+	}
+	ctx, cancel := middlewares.OutgoingContext(c)
+	defer cancel()
+	resp, err := h.AuthClient.ConsumeMagicLink(ctx, &req)
+	if err != nil {
+		return problem.FromGRPC(c, err)
+	}
+	return c.JSON(resp)
+}
+
+func (h *AuthHandler) RefreshToken(c *fiber.Ctx) error {
+	var req pb.RefreshTokenRequest
+	if err := c.BodyParser(&req); err != nil {
+		return problem.Write(c, http.StatusBadRequest, "invalid request body")
+	}
+	ctx, cancel := middlewares.OutgoingContext(c)
+	defer cancel()
+	resp, err := h.AuthClient.RefreshToken(ctx, &req)
+	if err != nil {
+		return problem.FromGRPC(c, err)
+	}
+	return c.JSON(resp)
+}
+
+// SignOut revokes the caller's access token and, when supplied in the body,
+// their refresh token.
+func (h *AuthHandler) SignOut(c *fiber.Ctx) error {
+	var req pb.SignOutRequest
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&req); err != nil {
+			return problem.Write(c, http.StatusBadRequest, "invalid request body")
+		}
+	}
+	if token, ok := c.Locals("accessToken").(string); ok {
+		req.AccessToken = token
+	}
+	ctx, cancel := middlewares.OutgoingContext(c)
+	defer cancel()
+	resp, err := h.AuthClient.SignOut(ctx, &req)
+	if err != nil {
+		return problem.FromGRPC(c, err)
+	}
+	return c.JSON(resp)
+}
+
+// RevokeAllSessions signs the caller out on every device.
+func (h *AuthHandler) RevokeAllSessions(c *fiber.Ctx) error {
+	userID, _ := c.Locals("userID").(string)
+	ctx, cancel := middlewares.OutgoingContext(c)
+	defer cancel()
+	resp, err := h.AuthClient.RevokeAllSessions(ctx, &pb.RevokeAllSessionsRequest{UserId: userID})
+	if err != nil {
+		return problem.FromGRPC(c, err)
 	}
 	return c.JSON(resp)
 }
@@ -45,23 +128,103 @@ func (h *AuthHandler) SignIn(c *fiber.Ctx) error {
 func (h *AuthHandler) ValidateToken(c *fiber.Ctx) error {
 	var req pb.ValidateTokenRequest
 	if err := c.BodyParser(&req); err != nil {
-		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "invalid request"})
+		return problem.Write(c, http.StatusBadRequest, "invalid request body")
 	}
-	resp, err := h.AuthClient.ValidateToken(context.Background(), &req)
+	ctx, cancel := middlewares.OutgoingContext(c)
+	defer cancel()
+	resp, err := h.AuthClient.ValidateToken(ctx, &req)
 	if err != nil {
-		return c.Status(http.StatusUnauthorized).JSON(fiber.Map{"error": err.Error()})
+		return problem.FromGRPC(c, err)
 	}
 	return c.JSON(resp)
 }
 
+// GetJWKS serves the auth service's public signing keys as a JWK Set.
+func (h *AuthHandler) GetJWKS(c *fiber.Ctx) error {
+	ctx, cancel := middlewares.OutgoingContext(c)
+	defer cancel()
+	resp, err := h.AuthClient.GetJWKS(ctx, &pb.GetJWKSRequest{})
+	if err != nil {
+		return problem.FromGRPC(c, err)
+	}
+	keys := resp.Keys
+	if keys == nil {
+		keys = []*pb.JWK{}
+	}
+	c.Set(fiber.HeaderCacheControl, "public, max-age=300")
+	return c.JSON(fiber.Map{"keys": keys})
+}
+
 func (h *AuthHandler) GetUserInfo(c *fiber.Ctx) error {
 	var req pb.GetUserInfoRequest
 	if err := c.BodyParser(&req); err != nil {
-		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "invalid request"})
+		return problem.Write(c, http.StatusBadRequest, "invalid request body")
+	}
+	ctx, cancel := middlewares.OutgoingContext(c)
+	defer cancel()
+	resp, err := h.AuthClient.GetUserInfo(ctx, &req)
+	if err != nil {
+		return problem.FromGRPC(c, err)
+	}
+	return c.JSON(resp)
+}
+
+// ConfirmEmail accepts the confirmation token either as a JSON body or as the
+// ?token= query parameter used in emailed links.
+func (h *AuthHandler) ConfirmEmail(c *fiber.Ctx) error {
+	req := pb.ConfirmEmailRequest{Token: c.Query("token")}
+	if req.Token == "" {
+		if err := c.BodyParser(&req); err != nil {
+			return problem.Write(c, http.StatusBadRequest, "invalid request body")
+		}
+	}
+	ctx, cancel := middlewares.OutgoingContext(c)
+	defer cancel()
+	resp, err := h.AuthClient.ConfirmEmail(ctx, &req)
+	if err != nil {
+		return problem.FromGRPC(c, err)
+	}
+	return c.JSON(resp)
+}
+
+func (h *AuthHandler) ResendConfirmation(c *fiber.Ctx) error {
+	var req pb.ResendConfirmationRequest
+	if err := c.BodyParser(&req); err != nil {
+		return problem.Write(c, http.StatusBadRequest, "invalid request body")
+	}
+	ctx, cancel := middlewares.OutgoingContext(c)
+	defer cancel()
+	resp, err := h.AuthClient.ResendConfirmation(ctx, &req)
+	if err != nil {
+		return problem.FromGRPC(c, err)
+	}
+	return c.JSON(resp)
+}
+
+func (h *AuthHandler) RequestPasswordReset(c *fiber.Ctx) error {
+	var req pb.RequestPasswordResetRequest
+	if err := c.BodyParser(&req); err != nil {
+		return problem.Write(c, http.StatusBadRequest, "invalid request body")
+	}
+	ctx, cancel := middlewares.OutgoingContext(c)
+	defer cancel()
+	resp, err := h.AuthClient.RequestPasswordReset(ctx, &req)
+	if err != nil {
+		return problem.FromGRPC(c, err)
+	}
+	return c.JSON(resp)
+}
+
+func (h *AuthHandler) ResetPassword(c *fiber.Ctx) error {
+	var req pb.ResetPasswordRequest
+	if err := c.BodyParser(&req); err != nil {
+		return problem.Write(c, http.StatusBadRequest, "invalid request body")
 	}
-	resp, err := h.AuthClient.GetUserInfo(context.Background(), &req)
+	ctx, cancel := middlewares.OutgoingContext(c)
+	defer cancel()
+	resp, err := h.AuthClient.ResetPassword(ctx, &req)
 	if err != nil {
-		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+		return problem.FromGRPC(c, err)
 	}
 	return c.JSON(resp)
 }
@@ -72,21 +235,25 @@ func (h *AuthHandler) CreateTest(c *fiber.Ctx) error {
 		Test string `json:"test"`
 	}
 	if err := c.BodyParser(&body); err != nil {
-		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "invalid request"})
+		return problem.Write(c, http.StatusBadRequest, "invalid request body")
 	}
 	req := pb.CreateTestRequest{Content: body.Test}
-	resp, err := h.AuthClient.CreateTest(context.Background(), &req)
+	ctx, cancel := middlewares.OutgoingContext(c)
+	defer cancel()
+	resp, err := h.AuthClient.CreateTest(ctx, &req)
 	if err != nil {
-		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+		return problem.FromGRPC(c, err)
 	}
 	return c.JSON(resp)
 }
 
 // ListTests retrieves tests from the auth service
 func (h *AuthHandler) ListTests(c *fiber.Ctx) error {
-	resp, err := h.AuthClient.ListTests(context.Background(), &pb.ListTestsRequest{})
+	ctx, cancel := middlewares.OutgoingContext(c)
+	defer cancel()
+	resp, err := h.AuthClient.ListTests(ctx, &pb.ListTestsRequest{})
 	if err != nil {
-		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+		return problem.FromGRPC(c, err)
 	}
 	return c.JSON(resp)
 }
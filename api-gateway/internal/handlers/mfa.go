@@ -0,0 +1,54 @@
+package handlers
+
+import (
+	"net/http"
+
+	"go-microservices/api-gateway/internal/middlewares"
+	"go-microservices/api-gateway/internal/problem"
+	pb "go-microservices/proto/auth"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// VerifyMFA completes a sign-in that returned mfa_required.
+func (h *AuthHandler) VerifyMFA(c *fiber.Ctx) error {
+	var req pb.VerifyMFARequest
+	if err := c.BodyParser(&req); err != nil {
+		return problem.Write(c, http.StatusBadRequest, "invalid request body")
+	}
+	ctx, cancel := middlewares.OutgoingContext(c)
+	defer cancel()
+	resp, err := h.AuthClient.VerifyMFA(ctx, &req)
+	if err != nil {
+		return problem.FromGRPC(c, err)
+	}
+	return c.JSON(resp)
+}
+
+// BeginTOTPEnrollment starts TOTP enrollment for the caller.
+func (h *AuthHandler) BeginTOTPEnrollment(c *fiber.Ctx) error {
+	userID, _ := c.Locals("userID").(string)
+	ctx, cancel := middlewares.OutgoingContext(c)
+	defer cancel()
+	resp, err := h.AuthClient.BeginTOTPEnrollment(ctx, &pb.BeginTOTPEnrollmentRequest{UserId: userID})
+	if err != nil {
+		return problem.FromGRPC(c, err)
+	}
+	return c.JSON(resp)
+}
+
+// ConfirmTOTPEnrollment enables TOTP for the caller given a code from their app.
+func (h *AuthHandler) ConfirmTOTPEnrollment(c *fiber.Ctx) error {
+	var req pb.ConfirmTOTPEnrollmentRequest
+	if err := c.BodyParser(&req); err != nil {
+		return problem.Write(c, http.StatusBadRequest, "invalid request body")
+	}
+	req.UserId, _ = c.Locals("userID").(string)
+	ctx, cancel := middlewares.OutgoingContext(c)
+	defer cancel()
+	resp, err := h.AuthClient.ConfirmTOTPEnrollment(ctx, &req)
+	if err != nil {
+		return problem.FromGRPC(c, err)
+	}
+	return c.JSON(resp)
+}
@@ -0,0 +1,196 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"go-microservices/api-gateway/internal/middlewares"
+	"go-microservices/api-gateway/internal/problem"
+	pb "go-microservices/proto/auth"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// OpenIDConfiguration serves the OpenID Connect discovery document.
+func (h *AuthHandler) OpenIDConfiguration(c *fiber.Ctx) error {
+	ctx, cancel := middlewares.OutgoingContext(c)
+	defer cancel()
+	resp, err := h.AuthClient.GetOpenIDConfiguration(ctx, &pb.GetOpenIDConfigurationRequest{})
+	if err != nil {
+		return problem.FromGRPC(c, err)
+	}
+	c.Set(fiber.HeaderCacheControl, "public, max-age=300")
+	return c.JSON(resp)
+}
+
+// Authorize handles an OAuth authorization request for the signed-in caller
+// and redirects back to the client with a code or an error.
+func (h *AuthHandler) Authorize(c *fiber.Ctx) error {
+	token, _ := c.Locals("accessToken").(string)
+	ctx, cancel := middlewares.OutgoingContext(c)
+	defer cancel()
+	resp, err := h.AuthClient.Authorize(ctx, &pb.AuthorizeRequest{
+		AccessToken:         token,
+		ResponseType:        c.Query("response_type"),
+		ClientId:            c.Query("client_id"),
+		RedirectUri:         c.Query("redirect_uri"),
+		Scope:               c.Query("scope"),
+		State:               c.Query("state"),
+		CodeChallenge:       c.Query("code_challenge"),
+		CodeChallengeMethod: c.Query("code_challenge_method"),
+		Nonce:               c.Query("nonce"),
+	})
+	if err != nil {
+		return problem.FromGRPC(c, err)
+	}
+	return c.Redirect(resp.RedirectUri, http.StatusFound)
+}
+
+// Token is the OAuth token endpoint. It takes a form-encoded body and
+// accepts client credentials in the body or with HTTP Basic. Errors use the
+// OAuth error format rather than problem details.
+func (h *AuthHandler) Token(c *fiber.Ctx) error {
+	req := pb.TokenRequest{
+		GrantType:    c.FormValue("grant_type"),
+		Code:         c.FormValue("code"),
+		RedirectUri:  c.FormValue("redirect_uri"),
+		CodeVerifier: c.FormValue("code_verifier"),
+		ClientId:     c.FormValue("client_id"),
+		ClientSecret: c.FormValue("client_secret"),
+		Scope:        c.FormValue("scope"),
+	}
+	if id, secret, ok := basicAuth(c); ok {
+		req.ClientId, req.ClientSecret = id, secret
+	}
+
+	ctx, cancel := middlewares.OutgoingContext(c)
+	defer cancel()
+	resp, err := h.AuthClient.Token(ctx, &req)
+	if err != nil {
+		return problem.FromGRPC(c, err)
+	}
+
+	c.Set(fiber.HeaderCacheControl, "no-store")
+	if resp.Error != "" {
+		code := http.StatusBadRequest
+		if resp.Error == "invalid_client" {
+			code = http.StatusUnauthorized
+			c.Set(fiber.HeaderWWWAuthenticate, `Basic realm="token"`)
+		}
+		return c.Status(code).JSON(fiber.Map{"error": resp.Error, "error_description": resp.ErrorDescription})
+	}
+	return c.JSON(resp)
+}
+
+// Introspect is the RFC 7662 token introspection endpoint for resource
+// servers, which authenticate as confidential OAuth clients.
+func (h *AuthHandler) Introspect(c *fiber.Ctx) error {
+	req := pb.IntrospectTokenRequest{
+		Token:         c.FormValue("token"),
+		TokenTypeHint: c.FormValue("token_type_hint"),
+		ClientId:      c.FormValue("client_id"),
+		ClientSecret:  c.FormValue("client_secret"),
+	}
+	if id, secret, ok := basicAuth(c); ok {
+		req.ClientId, req.ClientSecret = id, secret
+	}
+
+	ctx, cancel := middlewares.OutgoingContext(c)
+	defer cancel()
+	resp, err := h.AuthClient.IntrospectToken(ctx, &req)
+	if err != nil {
+		return problem.FromGRPC(c, err)
+	}
+
+	c.Set(fiber.HeaderCacheControl, "no-store")
+	if resp.Error != "" {
+		c.Set(fiber.HeaderWWWAuthenticate, `Basic realm="introspect"`)
+		return c.Status(http.StatusUnauthorized).JSON(fiber.Map{"error": resp.Error, "error_description": resp.ErrorDescription})
+	}
+	if !resp.Active {
+		// The generated JSON tags would drop "active": false.
+		return c.JSON(fiber.Map{"active": false})
+	}
+	return c.JSON(resp)
+}
+
+// OpenIDUserInfo serves the OpenID Connect userinfo endpoint for access
+// tokens granted the openid scope.
+func (h *AuthHandler) OpenIDUserInfo(c *fiber.Ctx) error {
+	token, ok := c.Locals("accessToken").(string)
+	if !ok {
+		return problem.Write(c, http.StatusUnauthorized, "an access token is required")
+	}
+	ctx, cancel := middlewares.OutgoingContext(c)
+	defer cancel()
+	resp, err := h.AuthClient.GetOpenIDUserInfo(ctx, &pb.GetOpenIDUserInfoRequest{AccessToken: token})
+	if err != nil {
+		return problem.FromGRPC(c, err)
+	}
+	return c.JSON(resp)
+}
+
+// CreateOAuthClient registers an OAuth client. The client secret is in the
+// response and cannot be retrieved again.
+func (h *AuthHandler) CreateOAuthClient(c *fiber.Ctx) error {
+	var req pb.CreateOAuthClientRequest
+	if err := c.BodyParser(&req); err != nil {
+		return problem.Write(c, http.StatusBadRequest, "invalid request body")
+	}
+	ctx, cancel := middlewares.OutgoingContext(c)
+	defer cancel()
+	resp, err := h.AuthClient.CreateOAuthClient(ctx, &req)
+	if err != nil {
+		return problem.FromGRPC(c, err)
+	}
+	return c.Status(http.StatusCreated).JSON(resp)
+}
+
+func (h *AuthHandler) ListOAuthClients(c *fiber.Ctx) error {
+	ctx, cancel := middlewares.OutgoingContext(c)
+	defer cancel()
+	resp, err := h.AuthClient.ListOAuthClients(ctx, &pb.ListOAuthClientsRequest{})
+	if err != nil {
+		return problem.FromGRPC(c, err)
+	}
+	return c.JSON(resp)
+}
+
+// DeleteOAuthClient deletes the OAuth client in the :id path parameter.
+func (h *AuthHandler) DeleteOAuthClient(c *fiber.Ctx) error {
+	ctx, cancel := middlewares.OutgoingContext(c)
+	defer cancel()
+	resp, err := h.AuthClient.DeleteOAuthClient(ctx, &pb.DeleteOAuthClientRequest{ClientId: c.Params("id")})
+	if err != nil {
+		return problem.FromGRPC(c, err)
+	}
+	return c.JSON(resp)
+}
+
+// basicAuth reads client credentials from an HTTP Basic Authorization
+// header. Both parts are form-encoded as RFC 6749 section 2.3.1 requires.
+func basicAuth(c *fiber.Ctx) (string, string, bool) {
+	scheme, encoded, found := strings.Cut(c.Get(fiber.HeaderAuthorization), " ")
+	if !found || !strings.EqualFold(scheme, "Basic") {
+		return "", "", false
+	}
+	decoded, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", "", false
+	}
+	rawID, rawSecret, found := strings.Cut(string(decoded), ":")
+	if !found {
+		return "", "", false
+	}
+	id, err := url.QueryUnescape(rawID)
+	if err != nil {
+		return "", "", false
+	}
+	secret, err := url.QueryUnescape(rawSecret)
+	if err != nil {
+		return "", "", false
+	}
+	return id, secret, true
+}
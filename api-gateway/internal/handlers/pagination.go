@@ -0,0 +1,31 @@
+package handlers
+
+import (
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// pagination reads the page and page_size query parameters. Missing values
+// are left as zero so the backing service applies its defaults.
+func pagination(c *fiber.Ctx) (page, pageSize int32, ok bool) {
+	parse := func(key string) (int32, bool) {
+		raw := c.Query(key)
+		if raw == "" {
+			return 0, true
+		}
+		n, err := strconv.ParseInt(raw, 10, 32)
+		if err != nil || n < 0 {
+			return 0, false
+		}
+		return int32(n), true
+	}
+
+	if page, ok = parse("page"); !ok {
+		return 0, 0, false
+	}
+	if pageSize, ok = parse("page_size"); !ok {
+		return 0, 0, false
+	}
+	return page, pageSize, true
+}
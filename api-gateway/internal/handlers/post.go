@@ -0,0 +1,84 @@
+package handlers
+
+import (
+	"net/http"
+
+	"go-microservices/api-gateway/internal/clients"
+	"go-microservices/api-gateway/internal/middlewares"
+	"go-microservices/api-gateway/internal/problem"
+	pbPost "go-microservices/proto/post"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type PostHandler struct {
+	PostClient *clients.PostClient
+}
+
+func NewPostHandler(postClient *clients.PostClient) *PostHandler {
+	return &PostHandler{PostClient: postClient}
+}
+
+// CreatePost publishes a post authored by the signed-in user.
+func (h *PostHandler) CreatePost(c *fiber.Ctx) error {
+	var req pbPost.CreatePostRequest
+	if err := c.BodyParser(&req); err != nil {
+		return problem.Write(c, http.StatusBadRequest, "invalid request body")
+	}
+	ctx, cancel := middlewares.OutgoingContext(c)
+	defer cancel()
+	resp, err := h.PostClient.CreatePost(ctx, &req)
+	if err != nil {
+		return problem.FromGRPC(c, err)
+	}
+	return c.Status(http.StatusCreated).JSON(resp)
+}
+
+func (h *PostHandler) GetPost(c *fiber.Ctx) error {
+	ctx, cancel := middlewares.OutgoingContext(c)
+	defer cancel()
+	resp, err := h.PostClient.GetPost(ctx, &pbPost.GetPostRequest{Id: c.Params("id")})
+	if err != nil {
+		return problem.FromGRPC(c, err)
+	}
+	return c.JSON(resp)
+}
+
+func (h *PostHandler) UpdatePost(c *fiber.Ctx) error {
+	var req pbPost.UpdatePostRequest
+	if err := c.BodyParser(&req); err != nil {
+		return problem.Write(c, http.StatusBadRequest, "invalid request body")
+	}
+	req.Id = c.Params("id")
+	ctx, cancel := middlewares.OutgoingContext(c)
+	defer cancel()
+	resp, err := h.PostClient.UpdatePost(ctx, &req)
+	if err != nil {
+		return problem.FromGRPC(c, err)
+	}
+	return c.JSON(resp)
+}
+
+func (h *PostHandler) DeletePost(c *fiber.Ctx) error {
+	ctx, cancel := middlewares.OutgoingContext(c)
+	defer cancel()
+	if err := h.PostClient.DeletePost(ctx, &pbPost.DeletePostRequest{Id: c.Params("id")}); err != nil {
+		return problem.FromGRPC(c, err)
+	}
+	return c.SendStatus(http.StatusNoContent)
+}
+
+// ListPosts pages through posts using the page and page_size query parameters.
+func (h *PostHandler) ListPosts(c *fiber.Ctx) error {
+	page, pageSize, ok := pagination(c)
+	if !ok {
+		return problem.Write(c, http.StatusBadRequest, "page and page_size must be non-negative integers")
+	}
+	ctx, cancel := middlewares.OutgoingContext(c)
+	defer cancel()
+	resp, err := h.PostClient.ListPosts(ctx, &pbPost.ListPostsRequest{Page: page, PageSize: pageSize})
+	if err != nil {
+		return problem.FromGRPC(c, err)
+	}
+	return c.JSON(resp)
+}
@@ -0,0 +1,71 @@
+package handlers
+
+import (
+	"net/http"
+
+	"go-microservices/api-gateway/internal/middlewares"
+	"go-microservices/api-gateway/internal/problem"
+	pb "go-microservices/proto/auth"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// ListRoles returns every defined role with its effective permissions.
+func (h *AuthHandler) ListRoles(c *fiber.Ctx) error {
+	ctx, cancel := middlewares.OutgoingContext(c)
+	defer cancel()
+	resp, err := h.AuthClient.ListRoles(ctx, &pb.ListRolesRequest{})
+	if err != nil {
+		return problem.FromGRPC(c, err)
+	}
+	return c.JSON(resp)
+}
+
+// ListUserRoles returns the roles held by the user in the :id path parameter.
+func (h *AuthHandler) ListUserRoles(c *fiber.Ctx) error {
+	ctx, cancel := middlewares.OutgoingContext(c)
+	defer cancel()
+	resp, err := h.AuthClient.ListRoles(ctx, &pb.ListRolesRequest{UserId: c.Params("id")})
+	if err != nil {
+		return problem.FromGRPC(c, err)
+	}
+	return c.JSON(resp)
+}
+
+// AssignRole grants the role named in the body to the user in :id.
+func (h *AuthHandler) AssignRole(c *fiber.Ctx) error {
+	var req pb.AssignRoleRequest
+	if err := c.BodyParser(&req); err != nil {
+		return problem.Write(c, http.StatusBadRequest, "invalid request body")
+	}
+	req.UserId = c.Params("id")
+	ctx, cancel := middlewares.OutgoingContext(c)
+	defer cancel()
+	resp, err := h.AuthClient.AssignRole(ctx, &req)
+	if err != nil {
+		return problem.FromGRPC(c, err)
+	}
+	return c.JSON(resp)
+}
+
+// RevokeRole takes the :role away from the user in :id.
+func (h *AuthHandler) RevokeRole(c *fiber.Ctx) error {
+	ctx, cancel := middlewares.OutgoingContext(c)
+	defer cancel()
+	resp, err := h.AuthClient.RevokeRole(ctx, &pb.RevokeRoleRequest{UserId: c.Params("id"), Role: c.Params("role")})
+	if err != nil {
+		return problem.FromGRPC(c, err)
+	}
+	return c.JSON(resp)
+}
+
+// UnlockAccount lifts the sign-in lockout of the user in :id.
+func (h *AuthHandler) UnlockAccount(c *fiber.Ctx) error {
+	ctx, cancel := middlewares.OutgoingContext(c)
+	defer cancel()
+	resp, err := h.AuthClient.UnlockAccount(ctx, &pb.UnlockAccountRequest{UserId: c.Params("id")})
+	if err != nil {
+		return problem.FromGRPC(c, err)
+	}
+	return c.JSON(resp)
+}
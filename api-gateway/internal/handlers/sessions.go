@@ -0,0 +1,77 @@
+package handlers
+
+import (
+	"net/http"
+
+	"go-microservices/api-gateway/internal/middlewares"
+	"go-microservices/api-gateway/internal/problem"
+	pb "go-microservices/proto/auth"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// ListMySessions lists the caller's active sessions, flagging the one the
+// request was made from.
+func (h *AuthHandler) ListMySessions(c *fiber.Ctx) error {
+	userID, _ := c.Locals("userID").(string)
+	sessionID, _ := c.Locals("sessionID").(string)
+	return h.listSessions(c, &pb.ListSessionsRequest{UserId: userID, CurrentSessionId: sessionID})
+}
+
+// TerminateMySession signs the caller out of the session in the :id path
+// parameter.
+func (h *AuthHandler) TerminateMySession(c *fiber.Ctx) error {
+	userID, _ := c.Locals("userID").(string)
+	return h.terminateSession(c, &pb.TerminateSessionRequest{UserId: userID, SessionId: c.Params("id")})
+}
+
+// ListUserSessions lists the active sessions of the user in the :id path
+// parameter.
+func (h *AuthHandler) ListUserSessions(c *fiber.Ctx) error {
+	return h.listSessions(c, &pb.ListSessionsRequest{UserId: c.Params("id")})
+}
+
+// TerminateUserSession ends the :session session of the user in the :id path
+// parameter.
+func (h *AuthHandler) TerminateUserSession(c *fiber.Ctx) error {
+	return h.terminateSession(c, &pb.TerminateSessionRequest{UserId: c.Params("id"), SessionId: c.Params("session")})
+}
+
+// Impersonate issues the caller an access token for the user in the :id path
+// parameter. The body must give a reason, which is recorded in the audit log.
+func (h *AuthHandler) Impersonate(c *fiber.Ctx) error {
+	var req pb.ImpersonateRequest
+	if err := c.BodyParser(&req); err != nil {
+		return problem.Write(c, http.StatusBadRequest, "invalid request body")
+	}
+	req.AccessToken, _ = c.Locals("accessToken").(string)
+	req.UserId = c.Params("id")
+	ctx, cancel := middlewares.OutgoingContext(c)
+	defer cancel()
+	resp, err := h.AuthClient.Impersonate(ctx, &req)
+	if err != nil {
+		return problem.FromGRPC(c, err)
+	}
+	c.Set(fiber.HeaderCacheControl, "no-store")
+	return c.JSON(resp)
+}
+
+func (h *AuthHandler) listSessions(c *fiber.Ctx, req *pb.ListSessionsRequest) error {
+	ctx, cancel := middlewares.OutgoingContext(c)
+	defer cancel()
+	resp, err := h.AuthClient.ListSessions(ctx, req)
+	if err != nil {
+		return problem.FromGRPC(c, err)
+	}
+	return c.JSON(resp)
+}
+
+func (h *AuthHandler) terminateSession(c *fiber.Ctx, req *pb.TerminateSessionRequest) error {
+	ctx, cancel := middlewares.OutgoingContext(c)
+	defer cancel()
+	resp, err := h.AuthClient.TerminateSession(ctx, req)
+	if err != nil {
+		return problem.FromGRPC(c, err)
+	}
+	return c.JSON(resp)
+}
@@ -0,0 +1,83 @@
+package handlers
+
+import (
+	"net/http"
+
+	"go-microservices/api-gateway/internal/clients"
+	"go-microservices/api-gateway/internal/middlewares"
+	"go-microservices/api-gateway/internal/problem"
+	pbUser "go-microservices/proto/user"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type UserHandler struct {
+	UserClient *clients.UserClient
+}
+
+func NewUserHandler(userClient *clients.UserClient) *UserHandler {
+	return &UserHandler{UserClient: userClient}
+}
+
+func (h *UserHandler) CreateUser(c *fiber.Ctx) error {
+	var req pbUser.CreateUserRequest
+	if err := c.BodyParser(&req); err != nil {
+		return problem.Write(c, http.StatusBadRequest, "invalid request body")
+	}
+	ctx, cancel := middlewares.OutgoingContext(c)
+	defer cancel()
+	resp, err := h.UserClient.CreateUser(ctx, &req)
+	if err != nil {
+		return problem.FromGRPC(c, err)
+	}
+	return c.Status(http.StatusCreated).JSON(resp)
+}
+
+func (h *UserHandler) GetUser(c *fiber.Ctx) error {
+	ctx, cancel := middlewares.OutgoingContext(c)
+	defer cancel()
+	resp, err := h.UserClient.GetUser(ctx, &pbUser.GetUserRequest{Id: c.Params("id")})
+	if err != nil {
+		return problem.FromGRPC(c, err)
+	}
+	return c.JSON(resp)
+}
+
+func (h *UserHandler) UpdateUser(c *fiber.Ctx) error {
+	var req pbUser.UpdateUserRequest
+	if err := c.BodyParser(&req); err != nil {
+		return problem.Write(c, http.StatusBadRequest, "invalid request body")
+	}
+	req.Id = c.Params("id")
+	ctx, cancel := middlewares.OutgoingContext(c)
+	defer cancel()
+	resp, err := h.UserClient.UpdateUser(ctx, &req)
+	if err != nil {
+		return problem.FromGRPC(c, err)
+	}
+	return c.JSON(resp)
+}
+
+func (h *UserHandler) DeleteUser(c *fiber.Ctx) error {
+	ctx, cancel := middlewares.OutgoingContext(c)
+	defer cancel()
+	if err := h.UserClient.DeleteUser(ctx, &pbUser.DeleteUserRequest{Id: c.Params("id")}); err != nil {
+		return problem.FromGRPC(c, err)
+	}
+	return c.SendStatus(http.StatusNoContent)
+}
+
+// ListUsers pages through users using the page and page_size query parameters.
+func (h *UserHandler) ListUsers(c *fiber.Ctx) error {
+	page, pageSize, ok := pagination(c)
+	if !ok {
+		return problem.Write(c, http.StatusBadRequest, "page and page_size must be non-negative integers")
+	}
+	ctx, cancel := middlewares.OutgoingContext(c)
+	defer cancel()
+	resp, err := h.UserClient.ListUsers(ctx, &pbUser.ListUsersRequest{Page: page, PageSize: pageSize})
+	if err != nil {
+		return problem.FromGRPC(c, err)
+	}
+	return c.JSON(resp)
+}
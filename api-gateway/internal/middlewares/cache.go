@@ -0,0 +1,102 @@
+package middlewares
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"sync"
+	"time"
+)
+
+// principal is the identity a validated token resolves to.
+type principal struct {
+	Method      string
+	UserID      string
+	Email       string
+	Roles       []string
+	Permissions []string
+	// SessionID names the sign-in a JWT belongs to.
+	SessionID string
+	// ClientID is set when the token was issued to an OAuth client.
+	ClientID string
+	// ActorID is set on impersonation tokens to the admin acting as UserID.
+	ActorID string
+}
+
+type cacheEntry struct {
+	principal principal
+	expires   time.Time
+}
+
+// validationCache remembers successful token validations for a bounded time
+// and number of entries, so repeated requests with the same token do not
+// each cost a round-trip to the auth service. A revoked token may keep
+// working for up to ttl.
+type validationCache struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	max     int
+	entries map[string]cacheEntry
+}
+
+func newValidationCache(ttl time.Duration, max int) *validationCache {
+	return &validationCache{ttl: ttl, max: max, entries: make(map[string]cacheEntry)}
+}
+
+func (c *validationCache) get(token string) (principal, bool) {
+	if c.ttl <= 0 {
+		return principal{}, false
+	}
+	key := cacheKey(token)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[key]
+	if !ok {
+		return principal{}, false
+	}
+	if time.Now().After(e.expires) {
+		delete(c.entries, key)
+		return principal{}, false
+	}
+	return e.principal, true
+}
+
+// put caches p until the cache TTL elapses or the token expires, whichever
+// comes first.
+func (c *validationCache) put(token string, p principal, tokenExpiry time.Time) {
+	if c.ttl <= 0 || c.max <= 0 {
+		return
+	}
+	expires := time.Now().Add(c.ttl)
+	if !tokenExpiry.IsZero() && tokenExpiry.Before(expires) {
+		expires = tokenExpiry
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.entries) >= c.max {
+		c.evict()
+	}
+	c.entries[cacheKey(token)] = cacheEntry{principal: p, expires: expires}
+}
+
+// evict drops expired entries and, if the cache is still full, arbitrary
+// ones until there is room. Callers must hold c.mu.
+func (c *validationCache) evict() {
+	now := time.Now()
+	for k, e := range c.entries {
+		if now.After(e.expires) {
+			delete(c.entries, k)
+		}
+	}
+	for k := range c.entries {
+		if len(c.entries) < c.max {
+			break
+		}
+		delete(c.entries, k)
+	}
+}
+
+func cacheKey(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,72 @@
+package middlewares
+
+import (
+	"context"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"google.golang.org/grpc/metadata"
+)
+
+// Metadata keys forwarded to the backend services on every call.
+const (
+	MetadataRequestID = "x-request-id"
+	MetadataUserID    = "x-user-id"
+	MetadataClientIP  = "x-client-ip"
+	MetadataUserAgent = "x-client-user-agent"
+	MetadataActorID   = "x-actor-id"
+	MetadataPerms     = "x-user-permissions"
+)
+
+const (
+	timeoutLocal   = "rpcTimeout"
+	requestIDLocal = "requestid"
+)
+
+// Timeout sets the deadline for backend calls made while handling the route.
+// It can be declared globally and overridden per route; the innermost
+// declaration wins.
+func Timeout(d time.Duration) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		c.Locals(timeoutLocal, d)
+		return c.Next()
+	}
+}
+
+// OutgoingContext derives the context for a gRPC call from the request: it
+// carries the route's deadline plus the request ID, client IP, client user
+// agent, authenticated user ID and permissions and, when impersonating, the
+// acting admin's ID as metadata. Callers must invoke the returned cancel function.
+//
+// Only the deadline bounds the call: fasthttp does not report a client
+// disconnecting, so a call started for a client that has gone away runs
+// until it completes or the deadline passes.
+func OutgoingContext(c *fiber.Ctx) (context.Context, context.CancelFunc) {
+	ctx := c.UserContext()
+
+	pairs := make([]string, 0, 8)
+	pairs = append(pairs, MetadataClientIP, c.IP())
+	if ua := c.Get(fiber.HeaderUserAgent); ua != "" {
+		pairs = append(pairs, MetadataUserAgent, ua)
+	}
+	if id, ok := c.Locals(requestIDLocal).(string); ok && id != "" {
+		pairs = append(pairs, MetadataRequestID, id)
+	}
+	if userID, ok := c.Locals("userID").(string); ok && userID != "" {
+		pairs = append(pairs, MetadataUserID, userID)
+	}
+	if actorID, ok := c.Locals("actorID").(string); ok && actorID != "" {
+		pairs = append(pairs, MetadataActorID, actorID)
+	}
+	if perms, ok := c.Locals("userPermissions").([]string); ok {
+		for _, p := range perms {
+			pairs = append(pairs, MetadataPerms, p)
+		}
+	}
+	ctx = metadata.AppendToOutgoingContext(ctx, pairs...)
+
+	if d, ok := c.Locals(timeoutLocal).(time.Duration); ok && d > 0 {
+		return context.WithTimeout(ctx, d)
+	}
+	return context.WithCancel(ctx)
+}
@@ -1,79 +1,174 @@
 package middlewares
 
 import (
-	"bytes"
-	"encoding/json"
-	"net/http"
-	"os"
 	"strings"
 	"time"
 
+	"go-microservices/api-gateway/internal/clients"
+	"go-microservices/api-gateway/internal/problem"
+	pb "go-microservices/proto/auth"
+
 	"github.com/gofiber/fiber/v2"
 )
 
-type validateRequest struct {
-	Token string `json:"token"`
+// JWTConfig tunes the validation cache used by JWTMiddleware. A zero CacheTTL
+// disables caching.
+type JWTConfig struct {
+	CacheTTL  time.Duration
+	CacheSize int
 }
 
-type validateResponse struct {
-	Sub   string `json:"sub"`
-	Role  string `json:"role"`
-	Email string `json:"email"`
-}
+// Authentication methods recorded in the authMethod local.
+const (
+	AuthMethodJWT    = "jwt"
+	AuthMethodAPIKey = "api_key"
+)
+
+// JWTMiddleware returns a Fiber middleware that authenticates the caller with
+// the auth service over gRPC. It accepts a bearer JWT or an API key sent in
+// X-API-Key or as "Authorization: ApiKey <key>"; an API key resolves to its
+// owner with the key's scopes as permissions. Successful validations are
+// cached per credential so only the first request pays for the round-trip.
+// Build it once and share it between routes so they share the cache.
+func JWTMiddleware(authClient *clients.AuthClient, cfg JWTConfig) fiber.Handler {
+	cache := newValidationCache(cfg.CacheTTL, cfg.CacheSize)
 
-// JWTMiddleware returns a Fiber middleware that validates JWT via the auth service.
-func JWTMiddleware() fiber.Handler {
 	return func(c *fiber.Ctx) error {
-		authHeader := c.Get("Authorization")
-		var token string
+		credential, method := apiKey(c), AuthMethodAPIKey
+		if credential == "" {
+			credential, method = bearerToken(c), AuthMethodJWT
+		}
+		if credential == "" {
+			return problem.Write(c, fiber.StatusUnauthorized, "missing token")
+		}
 
-		if authHeader != "" {
-			parts := strings.Split(authHeader, " ")
-			if len(parts) == 2 && parts[0] == "Bearer" {
-				token = parts[1]
+		p, ok := cache.get(credential)
+		if !ok {
+			var (
+				expires time.Time
+				err     *authError
+			)
+			if method == AuthMethodAPIKey {
+				p, expires, err = validateAPIKey(c, authClient, credential)
+			} else {
+				p, expires, err = validateJWT(c, authClient, credential)
+			}
+			if err != nil {
+				return problem.Write(c, err.status, err.detail)
 			}
+			cache.put(credential, p, expires)
 		}
 
-		if token == "" {
-			token = c.Cookies("access_token")
+		if p.Method == AuthMethodJWT {
+			c.Locals("accessToken", credential)
 		}
+		c.Locals("authMethod", p.Method)
+		c.Locals("clientID", p.ClientID)
+		c.Locals("actorID", p.ActorID)
+		c.Locals("sessionID", p.SessionID)
+		c.Locals("userID", p.UserID)
+		c.Locals("userEmail", p.Email)
+		c.Locals("userRoles", p.Roles)
+		c.Locals("userPermissions", p.Permissions)
 
-		if token == "" {
-			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "missing token"})
-		}
+		return c.Next()
+	}
+}
 
-		authURL := os.Getenv("AUTH_VALIDATE_URL") // e.g. "http://auth-service:8080/api/auth/validate"
-		if authURL == "" {
-			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "auth service URL not configured"})
-		}
+// SessionOnly rejects callers authenticated with an API key or with a token
+// issued to an OAuth client. It guards operations a leaked credential must
+// not reach, such as minting more keys. It must run after JWTMiddleware.
+func SessionOnly(c *fiber.Ctx) error {
+	method, _ := c.Locals("authMethod").(string)
+	clientID, _ := c.Locals("clientID").(string)
+	if method != AuthMethodJWT || clientID != "" {
+		return problem.Write(c, fiber.StatusForbidden, "this operation requires a signed-in session")
+	}
+	return c.Next()
+}
 
-		reqBody, _ := json.Marshal(validateRequest{Token: token})
-		client := &http.Client{Timeout: 5 * time.Second}
-		req, err := http.NewRequest(http.MethodPost, authURL, bytes.NewReader(reqBody))
-		if err != nil {
-			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "failed to build request"})
-		}
-		req.Header.Set("Content-Type", "application/json")
+// NotImpersonating rejects requests made with an impersonation token. It
+// guards account security changes, such as MFA enrollment, that an admin
+// acting as a user must not make. It must run after JWTMiddleware.
+func NotImpersonating(c *fiber.Ctx) error {
+	if actorID, _ := c.Locals("actorID").(string); actorID != "" {
+		return problem.Write(c, fiber.StatusForbidden, "this operation is not allowed while impersonating a user")
+	}
+	return c.Next()
+}
 
-		resp, err := client.Do(req)
-		if err != nil {
-			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "token validation failed"})
-		}
-		defer resp.Body.Close()
+// authError is an authentication failure to report to the client.
+type authError struct {
+	status int
+	detail string
+}
 
-		if resp.StatusCode != http.StatusOK {
-			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "invalid token"})
-		}
+func validateJWT(c *fiber.Ctx, authClient *clients.AuthClient, token string) (principal, time.Time, *authError) {
+	ctx, cancel := OutgoingContext(c)
+	defer cancel()
+	resp, err := authClient.ValidateToken(ctx, &pb.ValidateTokenRequest{Token: token})
+	if err != nil {
+		return principal{}, time.Time{}, &authError{fiber.StatusServiceUnavailable, "token validation unavailable"}
+	}
+	if !resp.Valid {
+		return principal{}, time.Time{}, &authError{fiber.StatusUnauthorized, "invalid token"}
+	}
 
-		var vResp validateResponse
-		if err := json.NewDecoder(resp.Body).Decode(&vResp); err != nil {
-			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "invalid response from auth service"})
-		}
+	return principal{
+		Method:      AuthMethodJWT,
+		UserID:      resp.UserId,
+		Email:       resp.Email,
+		Roles:       resp.Roles,
+		Permissions: resp.Permissions,
+		SessionID:   resp.SessionId,
+		ClientID:    resp.ClientId,
+		ActorID:     resp.ActorId,
+	}, unixTime(resp.ExpiresAt), nil
+}
 
-		c.Locals("userID", vResp.Sub)
-		c.Locals("userRole", vResp.Role)
-		c.Locals("userEmail", vResp.Email)
+func validateAPIKey(c *fiber.Ctx, authClient *clients.AuthClient, key string) (principal, time.Time, *authError) {
+	ctx, cancel := OutgoingContext(c)
+	defer cancel()
+	resp, err := authClient.ValidateAPIKey(ctx, &pb.ValidateAPIKeyRequest{Key: key})
+	if err != nil {
+		return principal{}, time.Time{}, &authError{fiber.StatusServiceUnavailable, "api key validation unavailable"}
+	}
+	if !resp.Valid {
+		return principal{}, time.Time{}, &authError{fiber.StatusUnauthorized, "invalid api key"}
+	}
 
-		return c.Next()
+	return principal{
+		Method:      AuthMethodAPIKey,
+		UserID:      resp.UserId,
+		Permissions: resp.Scopes,
+	}, unixTime(resp.ExpiresAt), nil
+}
+
+// apiKey reads an API key from X-API-Key or an "Authorization: ApiKey"
+// header.
+func apiKey(c *fiber.Ctx) string {
+	if key := c.Get("X-API-Key"); key != "" {
+		return key
+	}
+	if parts := strings.Fields(c.Get(fiber.HeaderAuthorization)); len(parts) == 2 && strings.EqualFold(parts[0], "ApiKey") {
+		return parts[1]
+	}
+	return ""
+}
+
+// bearerToken reads the token from the Authorization header, falling back to
+// the access_token cookie.
+func bearerToken(c *fiber.Ctx) string {
+	if parts := strings.Fields(c.Get(fiber.HeaderAuthorization)); len(parts) == 2 && strings.EqualFold(parts[0], "Bearer") {
+		return parts[1]
+	}
+	return c.Cookies("access_token")
+}
+
+// unixTime converts Unix seconds to a time, treating 0 as unset.
+func unixTime(sec int64) time.Time {
+	if sec == 0 {
+		return time.Time{}
 	}
+	return time.Unix(sec, 0)
 }
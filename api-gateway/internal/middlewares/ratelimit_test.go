@@ -0,0 +1,101 @@
+package middlewares
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"testing"
+
+	"go-microservices/api-gateway/internal/ratelimit"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// newRateLimitedApp serves 200 behind RateLimit, counting requests per the
+// X-Client header.
+func newRateLimitedApp(store ratelimit.Store, limit ratelimit.Limit) *fiber.App {
+	app := fiber.New()
+	app.Use(RateLimit(store, RatePolicy{
+		Name:  "test",
+		Limit: limit,
+		Key:   func(c *fiber.Ctx) string { return c.Get("X-Client") },
+	}))
+	app.Get("/", func(c *fiber.Ctx) error { return c.SendStatus(fiber.StatusOK) })
+	return app
+}
+
+func get(t *testing.T, app *fiber.App, client string) (int, map[string]string) {
+	t.Helper()
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("X-Client", client)
+	resp, err := app.Test(req)
+	if err != nil {
+		t.Fatalf("request: %v", err)
+	}
+	defer resp.Body.Close()
+	headers := map[string]string{}
+	for _, h := range []string{"RateLimit-Limit", "RateLimit-Remaining", "RateLimit-Reset", fiber.HeaderRetryAfter, fiber.HeaderContentType} {
+		headers[h] = resp.Header.Get(h)
+	}
+	return resp.StatusCode, headers
+}
+
+func TestRateLimitHeadersAndRejection(t *testing.T) {
+	// Two requests a minute: a token refills every 30 seconds.
+	app := newRateLimitedApp(ratelimit.NewMemoryStore(), ratelimit.PerMinute(2))
+
+	tests := []struct {
+		status                  int
+		limit, remaining, reset string
+		retryAfter, contentType string
+	}{
+		{status: fiber.StatusOK, limit: "2", remaining: "1", reset: "30"},
+		{status: fiber.StatusOK, limit: "2", remaining: "0", reset: "60"},
+		{status: fiber.StatusTooManyRequests, limit: "2", remaining: "0", reset: "60", retryAfter: "30", contentType: "application/problem+json"},
+	}
+	for i, tt := range tests {
+		status, h := get(t, app, "a")
+		if status != tt.status {
+			t.Fatalf("request %d: status %d, want %d", i+1, status, tt.status)
+		}
+		if h["RateLimit-Limit"] != tt.limit || h["RateLimit-Remaining"] != tt.remaining || h["RateLimit-Reset"] != tt.reset {
+			t.Fatalf("request %d: RateLimit headers %v, want limit %s remaining %s reset %s", i+1, h, tt.limit, tt.remaining, tt.reset)
+		}
+		if h[fiber.HeaderRetryAfter] != tt.retryAfter {
+			t.Fatalf("request %d: Retry-After %q, want %q", i+1, h[fiber.HeaderRetryAfter], tt.retryAfter)
+		}
+		if tt.contentType != "" && h[fiber.HeaderContentType] != tt.contentType {
+			t.Fatalf("request %d: Content-Type %q, want %q", i+1, h[fiber.HeaderContentType], tt.contentType)
+		}
+	}
+
+	// Another client has a bucket of its own.
+	if status, h := get(t, app, "b"); status != fiber.StatusOK || h["RateLimit-Remaining"] != "1" {
+		t.Fatalf("other client: status %d, headers %v", status, h)
+	}
+}
+
+func TestRateLimitUnlimitedPolicySetsNoHeaders(t *testing.T) {
+	app := newRateLimitedApp(ratelimit.NewMemoryStore(), ratelimit.PerMinute(0))
+	for i := 0; i < 3; i++ {
+		status, h := get(t, app, "a")
+		if status != fiber.StatusOK || h["RateLimit-Limit"] != "" {
+			t.Fatalf("request %d: status %d, headers %v", i+1, status, h)
+		}
+	}
+}
+
+type failingStore struct{}
+
+func (failingStore) Allow(context.Context, string, ratelimit.Limit) (ratelimit.Result, error) {
+	return ratelimit.Result{}, errors.New("connection refused")
+}
+
+func TestRateLimitFailsOpen(t *testing.T) {
+	app := newRateLimitedApp(failingStore{}, ratelimit.PerMinute(1))
+	for i := 0; i < 2; i++ {
+		if status, _ := get(t, app, "a"); status != fiber.StatusOK {
+			t.Fatalf("request %d: status %d, want the request let through", i+1, status)
+		}
+	}
+}
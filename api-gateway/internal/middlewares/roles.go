@@ -1,36 +1,35 @@
 package middlewares
 
 import (
+	"go-microservices/api-gateway/internal/problem"
+
 	"github.com/gofiber/fiber/v2"
-	"gorm.io/gorm"
 )
 
-func OnlyAdmin(db *gorm.DB, fn fiber.Handler) fiber.Handler {
+// Require allows the request through only if the caller's access token
+// grants every one of perms. It must run after JWTMiddleware. Permissions
+// come from the caller's roles, including those inherited from parent roles,
+// so Require("posts:delete") admits moderators and admins alike.
+func Require(perms ...string) fiber.Handler {
 	return func(c *fiber.Ctx) error {
-		role, ok := c.Locals("userRole").(string)
-		if !ok || role != "Admin" {
-			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "Access denied"})
+		granted, ok := c.Locals("userPermissions").([]string)
+		if !ok {
+			return problem.Write(c, fiber.StatusUnauthorized, "authentication required")
 		}
-		return fn(c)
-	}
-}
-
-func OnlyModerator(db *gorm.DB, fn fiber.Handler) fiber.Handler {
-	return func(c *fiber.Ctx) error {
-		role, ok := c.Locals("userRole").(string)
-		if !ok || (role != "Moderator" && role != "Admin") {
-			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "Access denied"})
+		for _, want := range perms {
+			if !contains(granted, want) {
+				return problem.Write(c, fiber.StatusForbidden, "missing permission "+want)
+			}
 		}
-		return fn(c)
+		return c.Next()
 	}
 }
 
-func OnlyUser(db *gorm.DB, fn fiber.Handler) fiber.Handler {
-	return func(c *fiber.Ctx) error {
-		role, ok := c.Locals("userRole").(string)
-		if !ok || role != "User" {
-			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "Access denied"})
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
 		}
-		return fn(c)
 	}
+	return false
 }
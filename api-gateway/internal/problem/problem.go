@@ -0,0 +1,141 @@
+// Package problem renders errors as RFC 7807 problem details and translates
+// gRPC statuses from the backend services into HTTP responses.
+package problem
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const contentType = "application/problem+json"
+
+// Details is the JSON body of an error response.
+type Details struct {
+	Type          string         `json:"type"`
+	Title         string         `json:"title"`
+	Status        int            `json:"status"`
+	Detail        string         `json:"detail,omitempty"`
+	Instance      string         `json:"instance,omitempty"`
+	Reason        string         `json:"reason,omitempty"`
+	InvalidParams []InvalidParam `json:"invalid-params,omitempty"`
+}
+
+// InvalidParam describes one rejected request field. Code, when set, is a
+// stable identifier of the rule the field failed.
+type InvalidParam struct {
+	Name   string `json:"name"`
+	Reason string `json:"reason"`
+	Code   string `json:"code,omitempty"`
+}
+
+// httpStatus maps gRPC codes to HTTP statuses, following the mapping used by
+// grpc-gateway.
+var httpStatus = map[codes.Code]int{
+	codes.OK:                 http.StatusOK,
+	codes.Canceled:           499,
+	codes.Unknown:            http.StatusInternalServerError,
+	codes.InvalidArgument:    http.StatusBadRequest,
+	codes.DeadlineExceeded:   http.StatusGatewayTimeout,
+	codes.NotFound:           http.StatusNotFound,
+	codes.AlreadyExists:      http.StatusConflict,
+	codes.PermissionDenied:   http.StatusForbidden,
+	codes.ResourceExhausted:  http.StatusTooManyRequests,
+	codes.FailedPrecondition: http.StatusBadRequest,
+	codes.Aborted:            http.StatusConflict,
+	codes.OutOfRange:         http.StatusBadRequest,
+	codes.Unimplemented:      http.StatusNotImplemented,
+	codes.Internal:           http.StatusInternalServerError,
+	codes.Unavailable:        http.StatusServiceUnavailable,
+	codes.DataLoss:           http.StatusInternalServerError,
+	codes.Unauthenticated:    http.StatusUnauthorized,
+}
+
+// hiddenMessages replaces backend messages that may carry internals such as
+// database errors or network addresses.
+var hiddenMessages = map[codes.Code]string{
+	codes.Unknown:          "an unexpected error occurred",
+	codes.Internal:         "an unexpected error occurred",
+	codes.DataLoss:         "an unexpected error occurred",
+	codes.Unavailable:      "the service is temporarily unavailable",
+	codes.DeadlineExceeded: "the request timed out",
+	codes.Canceled:         "the request was cancelled",
+}
+
+// Write sends a problem response with the given status and detail.
+func Write(c *fiber.Ctx, statusCode int, detail string) error {
+	return send(c, Details{Status: statusCode, Detail: detail})
+}
+
+// FromGRPC translates an error returned by a gRPC client into a problem
+// response. Messages of server-side failures are logged and replaced with a
+// generic description.
+func FromGRPC(c *fiber.Ctx, err error) error {
+	st, ok := status.FromError(err)
+	if !ok {
+		log.Printf("%s %s: %v", c.Method(), c.Path(), err)
+		return Write(c, http.StatusInternalServerError, hiddenMessages[codes.Internal])
+	}
+
+	code, found := httpStatus[st.Code()]
+	if !found {
+		code = http.StatusInternalServerError
+	}
+	d := Details{Status: code, Detail: st.Message()}
+	if msg, hide := hiddenMessages[st.Code()]; hide {
+		log.Printf("%s %s: %s: %s", c.Method(), c.Path(), st.Code(), st.Message())
+		d.Detail = msg
+	}
+
+	for _, detail := range st.Details() {
+		switch v := detail.(type) {
+		case *errdetails.BadRequest:
+			for _, fv := range v.GetFieldViolations() {
+				d.InvalidParams = append(d.InvalidParams, InvalidParam{Name: fv.GetField(), Reason: fv.GetDescription(), Code: fv.GetReason()})
+			}
+		case *errdetails.ErrorInfo:
+			d.Reason = v.GetReason()
+		case *errdetails.RetryInfo:
+			if delay := v.GetRetryDelay(); delay != nil {
+				secs := int(delay.AsDuration().Seconds() + 0.999)
+				c.Set(fiber.HeaderRetryAfter, strconv.Itoa(secs))
+			}
+		}
+	}
+	return send(c, d)
+}
+
+// ErrorHandler is a fiber.ErrorHandler that renders unhandled errors, such as
+// unknown routes, as problem details.
+func ErrorHandler(c *fiber.Ctx, err error) error {
+	var fe *fiber.Error
+	if errors.As(err, &fe) {
+		return Write(c, fe.Code, fe.Message)
+	}
+	if _, ok := status.FromError(err); ok {
+		return FromGRPC(c, err)
+	}
+	log.Printf("%s %s: %v", c.Method(), c.Path(), err)
+	return Write(c, http.StatusInternalServerError, hiddenMessages[codes.Internal])
+}
+
+func send(c *fiber.Ctx, d Details) error {
+	if d.Type == "" {
+		d.Type = "about:blank"
+	}
+	if d.Title == "" {
+		d.Title = http.StatusText(d.Status)
+		if d.Title == "" {
+			d.Title = "Client Closed Request"
+		}
+	}
+	d.Instance = c.OriginalURL()
+
+	return c.Status(d.Status).JSON(d, contentType)
+}
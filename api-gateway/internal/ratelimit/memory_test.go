@@ -0,0 +1,35 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestMemoryStoreZeroLimitAllowsEverything(t *testing.T) {
+	store := NewMemoryStore()
+	for i := 0; i < 3; i++ {
+		res, err := store.Allow(context.Background(), "k", PerMinute(0))
+		if err != nil {
+			t.Fatalf("Allow: %v", err)
+		}
+		if !res.Allowed {
+			t.Fatalf("request %d rejected by a zero limit", i)
+		}
+	}
+}
+
+func TestMemoryStoreRejectsOverLimit(t *testing.T) {
+	store := NewMemoryStore()
+	ctx := context.Background()
+	if res, _ := store.Allow(ctx, "k", PerMinute(1)); !res.Allowed {
+		t.Fatal("first request rejected")
+	}
+	res, _ := store.Allow(ctx, "k", PerMinute(1))
+	if res.Allowed {
+		t.Fatal("second request allowed")
+	}
+	if res.RetryAfter <= 0 || res.RetryAfter > time.Minute {
+		t.Fatalf("unexpected Retry-After %v", res.RetryAfter)
+	}
+}
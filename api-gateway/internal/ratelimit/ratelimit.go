@@ -0,0 +1,86 @@
+// Package ratelimit implements token-bucket rate limiting with pluggable
+// storage: MemoryStore for a single gateway instance and RedisStore when
+// limits must hold across replicas.
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"time"
+)
+
+// Limit describes a token bucket: it holds up to Burst tokens and refills at
+// Requests per Per. Each request takes one token. A limit of zero requests
+// does not limit at all.
+type Limit struct {
+	Requests int
+	Per      time.Duration
+	Burst    int
+}
+
+// PerMinute allows n requests a minute with bursts of up to n.
+func PerMinute(n int) Limit {
+	return Limit{Requests: n, Per: time.Minute, Burst: n}
+}
+
+// Unlimited reports whether the limit lets every request through.
+func (l Limit) Unlimited() bool {
+	return l.Requests <= 0 || l.Per <= 0
+}
+
+// rate returns the refill rate in tokens per second.
+func (l Limit) rate() float64 {
+	return float64(l.Requests) / l.Per.Seconds()
+}
+
+func (l Limit) capacity() float64 {
+	if l.Burst > 0 {
+		return float64(l.Burst)
+	}
+	return float64(l.Requests)
+}
+
+// Result is the outcome of taking a token.
+type Result struct {
+	Allowed bool
+	// Limit is the bucket capacity.
+	Limit int
+	// Remaining is the number of whole tokens left after this request.
+	Remaining int
+	// RetryAfter is how long until a token is available; zero when Allowed.
+	RetryAfter time.Duration
+	// Reset is how long until the bucket is full again.
+	Reset time.Duration
+}
+
+// Store keeps the buckets. Allow takes a token from the bucket at key,
+// creating a full bucket if none exists.
+type Store interface {
+	Allow(ctx context.Context, key string, limit Limit) (Result, error)
+}
+
+// take applies the token-bucket algorithm to a bucket last updated at
+// updated holding tokens, returning the new token count and the result.
+func take(limit Limit, tokens float64, updated, now time.Time) (float64, Result) {
+	capacity := limit.capacity()
+	rate := limit.rate()
+
+	if elapsed := now.Sub(updated).Seconds(); elapsed > 0 {
+		tokens = math.Min(capacity, tokens+elapsed*rate)
+	}
+
+	res := Result{Limit: int(capacity)}
+	if tokens >= 1 {
+		tokens--
+		res.Allowed = true
+	} else {
+		res.RetryAfter = seconds((1 - tokens) / rate)
+	}
+	res.Remaining = int(math.Floor(tokens))
+	res.Reset = seconds((capacity - tokens) / rate)
+	return tokens, res
+}
+
+func seconds(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
@@ -0,0 +1,81 @@
+package ratelimit
+
+import (
+	"context"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const keyPrefix = "gateway:ratelimit:"
+
+// takeScript runs the token-bucket update atomically in Redis. It uses the
+// Redis clock so gateway replicas with skewed clocks share one timeline.
+//
+// KEYS[1] bucket key; ARGV[1] capacity; ARGV[2] refill rate (tokens/second),
+// where a rate of zero allows every request.
+// Returns {allowed, tokens*1000, retry_after_ms, reset_ms}.
+var takeScript = redis.NewScript(`
+local capacity = tonumber(ARGV[1])
+local rate = tonumber(ARGV[2])
+if rate <= 0 then
+  return {1, math.floor(capacity * 1000), 0, 0}
+end
+local t = redis.call('TIME')
+local now = tonumber(t[1]) + tonumber(t[2]) / 1000000
+
+local state = redis.call('HMGET', KEYS[1], 'tokens', 'updated')
+local tokens = tonumber(state[1])
+local updated = tonumber(state[2])
+if tokens == nil then
+  tokens = capacity
+  updated = now
+end
+
+local elapsed = now - updated
+if elapsed > 0 then
+  tokens = math.min(capacity, tokens + elapsed * rate)
+end
+
+local allowed = 0
+local retry = 0
+if tokens >= 1 then
+  tokens = tokens - 1
+  allowed = 1
+else
+  retry = (1 - tokens) / rate
+end
+local reset = (capacity - tokens) / rate
+
+redis.call('HSET', KEYS[1], 'tokens', tostring(tokens), 'updated', tostring(now))
+redis.call('PEXPIRE', KEYS[1], math.ceil(reset * 1000) + 1000)
+
+return {allowed, math.floor(tokens * 1000), math.ceil(retry * 1000), math.ceil(reset * 1000)}
+`)
+
+// RedisStore keeps buckets in Redis so every gateway replica enforces the
+// same limits. Buckets expire once they have refilled.
+type RedisStore struct {
+	client *redis.Client
+}
+
+func NewRedisStore(client *redis.Client) *RedisStore {
+	return &RedisStore{client: client}
+}
+
+func (r *RedisStore) Allow(ctx context.Context, key string, limit Limit) (Result, error) {
+	if limit.Unlimited() {
+		return Result{Allowed: true}, nil
+	}
+	vals, err := takeScript.Run(ctx, r.client, []string{keyPrefix + key}, limit.capacity(), limit.rate()).Int64Slice()
+	if err != nil {
+		return Result{}, err
+	}
+	return Result{
+		Allowed:    vals[0] == 1,
+		Limit:      int(limit.capacity()),
+		Remaining:  int(vals[1] / 1000),
+		RetryAfter: time.Duration(vals[2]) * time.Millisecond,
+		Reset:      time.Duration(vals[3]) * time.Millisecond,
+	}, nil
+}
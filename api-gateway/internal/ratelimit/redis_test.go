@@ -0,0 +1,73 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+// newTestRedisStore returns a RedisStore backed by miniredis, whose clock
+// the script reads through TIME and the test moves with SetTime.
+func newTestRedisStore(t *testing.T) (*RedisStore, *miniredis.Miniredis) {
+	t.Helper()
+	m := miniredis.RunT(t)
+	m.SetTime(time.Unix(1700000000, 0))
+	client := redis.NewClient(&redis.Options{Addr: m.Addr()})
+	t.Cleanup(func() { client.Close() })
+	return NewRedisStore(client), m
+}
+
+func TestRedisStoreTokenBucket(t *testing.T) {
+	store, m := newTestRedisStore(t)
+	ctx := context.Background()
+	start := time.Unix(1700000000, 0)
+	// Two requests a minute: a token refills every 30 seconds.
+	limit := PerMinute(2)
+
+	tests := []struct {
+		name       string
+		at         time.Duration
+		allowed    bool
+		remaining  int
+		retryAfter time.Duration
+		reset      time.Duration
+	}{
+		{name: "first", allowed: true, remaining: 1, reset: 30 * time.Second},
+		{name: "second", allowed: true, remaining: 0, reset: time.Minute},
+		{name: "over limit", allowed: false, remaining: 0, retryAfter: 30 * time.Second, reset: time.Minute},
+		{name: "half refilled", at: 15 * time.Second, allowed: false, remaining: 0, retryAfter: 15 * time.Second, reset: 45 * time.Second},
+		{name: "refilled", at: 30 * time.Second, allowed: true, remaining: 0, reset: time.Minute},
+	}
+	for _, tt := range tests {
+		m.SetTime(start.Add(tt.at))
+		res, err := store.Allow(ctx, "k", limit)
+		if err != nil {
+			t.Fatalf("%s: Allow: %v", tt.name, err)
+		}
+		want := Result{Allowed: tt.allowed, Limit: 2, Remaining: tt.remaining, RetryAfter: tt.retryAfter, Reset: tt.reset}
+		if res != want {
+			t.Fatalf("%s: got %+v, want %+v", tt.name, res, want)
+		}
+	}
+
+	if ttl := m.TTL(keyPrefix + "k"); ttl <= 0 || ttl > time.Minute+time.Second {
+		t.Fatalf("expected the bucket to expire once refilled, TTL %v", ttl)
+	}
+	if res, err := store.Allow(ctx, "other", limit); err != nil || !res.Allowed || res.Remaining != 1 {
+		t.Fatalf("expected another key to have a full bucket, got %+v %v", res, err)
+	}
+}
+
+func TestRedisStoreZeroLimitSkipsRedis(t *testing.T) {
+	store, m := newTestRedisStore(t)
+	res, err := store.Allow(context.Background(), "k", PerMinute(0))
+	if err != nil || !res.Allowed {
+		t.Fatalf("expected a zero limit to allow, got %+v %v", res, err)
+	}
+	if keys := m.Keys(); len(keys) != 0 {
+		t.Fatalf("expected no buckets, got %v", keys)
+	}
+}
@@ -1,25 +1,145 @@
 package routes
 
 import (
+	"time"
+
 	"go-microservices/api-gateway/internal/handlers"
 	"go-microservices/api-gateway/internal/middlewares"
 
 	"github.com/gofiber/fiber/v2"
 )
 
-func RegisterAuthRoutes(app *fiber.App, authHandler *handlers.AuthHandler) {
+// Permissions checked at the gateway. They are granted through roles managed
+// by the auth service.
+const (
+	permUsersRead        = "users:read"
+	permUsersWrite       = "users:write"
+	permPostsWrite       = "posts:write"
+	permRolesManage      = "roles:manage"
+	permAccountsUnlock   = "accounts:unlock"
+	permClientsManage    = "clients:manage"
+	permSessionsManage   = "sessions:manage"
+	permUsersImpersonate = "users:impersonate"
+)
+
+// Limits are the rate limiters routes declare on top of the global per-IP
+// limit.
+type Limits struct {
+	// Credentials guards endpoints that take passwords or send email, per IP.
+	Credentials fiber.Handler
+	// PerUser applies to authenticated endpoints, per caller. Declare it
+	// after requireAuth.
+	PerUser fiber.Handler
+}
+
+// RegisterAuthRoutes mounts the auth endpoints. requireAuth is the shared
+// JWT middleware guarding routes that need a signed-in caller.
+func RegisterAuthRoutes(app *fiber.App, authHandler *handlers.AuthHandler, requireAuth fiber.Handler, limits Limits) {
+	app.Get("/.well-known/jwks.json", authHandler.GetJWKS)
+
+	// OAuth 2.0 and OpenID Connect. Users authorize clients from a signed-in
+	// session; the token endpoint authenticates clients itself.
+	app.Get("/.well-known/openid-configuration", authHandler.OpenIDConfiguration)
+	app.Get("/authorize", requireAuth, limits.PerUser, middlewares.SessionOnly, middlewares.NotImpersonating, authHandler.Authorize)
+	app.Post("/token", authHandler.Token)
+	app.Post("/introspect", authHandler.Introspect)
+	app.Get("/userinfo", requireAuth, limits.PerUser, authHandler.OpenIDUserInfo)
+	app.Post("/userinfo", requireAuth, limits.PerUser, authHandler.OpenIDUserInfo)
+
 	api := app.Group("/api/v1")
 
+	// Password hashing makes these noticeably slower than the default deadline allows
+	// for under load.
+	slow := middlewares.Timeout(10 * time.Second)
+
 	// Health route
 	api.Get("/", func(c *fiber.Ctx) error {
 		return c.SendString("API Gateway is running!")
 	})
 
-	api.Post("/signup", authHandler.SignUp)
-	api.Post("/signin", authHandler.SignIn)
+	strict := limits.Credentials
+	// Account security settings, including API keys themselves, can only be
+	// changed from a signed-in session, never with an API key, and not by an
+	// admin impersonating the account.
+	session := middlewares.SessionOnly
+	own := middlewares.NotImpersonating
+
+	api.Post("/signup", strict, slow, authHandler.SignUp)
+	api.Post("/signin", strict, slow, authHandler.SignIn)
+	api.Post("/signin/mfa", strict, authHandler.VerifyMFA)
+	api.Post("/signin/magic-link", strict, authHandler.RequestMagicLink)
+	api.Post("/signin/magic-link/consume", strict, authHandler.ConsumeMagicLink)
+	api.Post("/refresh", authHandler.RefreshToken)
+	api.Post("/signout", requireAuth, limits.PerUser, session, authHandler.SignOut)
+	api.Post("/signout/all", requireAuth, limits.PerUser, session, own, authHandler.RevokeAllSessions)
+	api.Post("/confirm-email", authHandler.ConfirmEmail)
+	api.Post("/confirm-email/resend", strict, authHandler.ResendConfirmation)
+	api.Post("/confirm-email/change", authHandler.ConfirmEmailChange)
+	api.Post("/password/forgot", strict, authHandler.RequestPasswordReset)
+	api.Post("/password/reset", strict, slow, authHandler.ResetPassword)
 	api.Post("/validate", authHandler.ValidateToken)
-	api.Post("/userinfo", middlewares.JWTMiddleware(), authHandler.GetUserInfo)
+	api.Post("/userinfo", requireAuth, limits.PerUser, authHandler.GetUserInfo)
+	api.Post("/mfa/totp", requireAuth, limits.PerUser, session, own, authHandler.BeginTOTPEnrollment)
+	api.Post("/mfa/totp/confirm", requireAuth, limits.PerUser, session, own, authHandler.ConfirmTOTPEnrollment)
+
+	me := api.Group("/me", requireAuth, limits.PerUser, session)
+	me.Get("/sessions", authHandler.ListMySessions)
+	me.Delete("/sessions/:id", own, authHandler.TerminateMySession)
+	me.Post("/password", strict, slow, own, authHandler.ChangePassword)
+	me.Post("/email", strict, own, authHandler.ChangeEmail)
+	me.Post("/username", own, authHandler.ChangeUsername)
+	me.Delete("/", strict, slow, own, authHandler.DeleteAccount)
+
+	apiKeys := api.Group("/api-keys", requireAuth, limits.PerUser, session, own)
+	apiKeys.Get("/", authHandler.ListAPIKeys)
+	apiKeys.Post("/", authHandler.CreateAPIKey)
+	apiKeys.Delete("/:id", authHandler.RevokeAPIKey)
+
+	admin := api.Group("/admin", requireAuth, limits.PerUser)
+	manageRoles := middlewares.Require(permRolesManage)
+	admin.Get("/roles", manageRoles, authHandler.ListRoles)
+	admin.Get("/users/:id/roles", manageRoles, authHandler.ListUserRoles)
+	admin.Post("/users/:id/roles", manageRoles, authHandler.AssignRole)
+	admin.Delete("/users/:id/roles/:role", manageRoles, authHandler.RevokeRole)
+	admin.Post("/users/:id/unlock", middlewares.Require(permAccountsUnlock), authHandler.UnlockAccount)
+	manageSessions := middlewares.Require(permSessionsManage)
+	admin.Get("/users/:id/sessions", manageSessions, authHandler.ListUserSessions)
+	admin.Delete("/users/:id/sessions/:session", manageSessions, authHandler.TerminateUserSession)
+	admin.Post("/users/:id/impersonate", middlewares.Require(permUsersImpersonate), session, own, authHandler.Impersonate)
+	manageClients := middlewares.Require(permClientsManage)
+	admin.Get("/oauth/clients", manageClients, authHandler.ListOAuthClients)
+	admin.Post("/oauth/clients", manageClients, authHandler.CreateOAuthClient)
+	admin.Delete("/oauth/clients/:id", manageClients, authHandler.DeleteOAuthClient)
+
 	// Test endpoints
 	api.Post("/test", authHandler.CreateTest)
 	api.Get("/tests", authHandler.ListTests)
 }
+
+// RegisterUserRoutes mounts user profile CRUD under /api/v1/users. Reading
+// profiles needs users:read; changing them needs users:write.
+func RegisterUserRoutes(app *fiber.App, userHandler *handlers.UserHandler, requireAuth fiber.Handler, limits Limits) {
+	users := app.Group("/api/v1/users", requireAuth, limits.PerUser)
+
+	read := middlewares.Require(permUsersRead)
+	write := middlewares.Require(permUsersWrite)
+
+	users.Get("/", read, userHandler.ListUsers)
+	users.Post("/", write, userHandler.CreateUser)
+	users.Get("/:id", read, userHandler.GetUser)
+	users.Put("/:id", write, userHandler.UpdateUser)
+	users.Delete("/:id", write, userHandler.DeleteUser)
+}
+
+// RegisterPostRoutes mounts post CRUD under /api/v1/posts. Reading is public
+// and writing needs posts:write. The post service only lets authors edit
+// their posts, and lets them or holders of posts:delete delete them.
+func RegisterPostRoutes(app *fiber.App, postHandler *handlers.PostHandler, requireAuth fiber.Handler, limits Limits) {
+	posts := app.Group("/api/v1/posts")
+
+	posts.Get("/", postHandler.ListPosts)
+	posts.Get("/:id", postHandler.GetPost)
+	posts.Post("/", requireAuth, limits.PerUser, middlewares.Require(permPostsWrite), postHandler.CreatePost)
+	posts.Put("/:id", requireAuth, limits.PerUser, middlewares.Require(permPostsWrite), postHandler.UpdatePost)
+	posts.Delete("/:id", requireAuth, limits.PerUser, middlewares.Require(permPostsWrite), postHandler.DeletePost)
+}
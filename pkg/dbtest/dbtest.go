@@ -0,0 +1,37 @@
+// Package dbtest opens throwaway databases for tests. They are in-memory
+// SQLite databases, so tests exercise the real repositories without a
+// Postgres server; Postgres-only SQL must be avoided or guarded.
+package dbtest
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// Open returns a fresh database private to t, migrated by migrate and
+// closed when t finishes. Errors are translated like the services'
+// databases, so unique violations surface as gorm.ErrDuplicatedKey.
+func Open(t testing.TB, migrate func(*gorm.DB) error) *gorm.DB {
+	t.Helper()
+	dsn := "file:" + url.PathEscape(t.Name()) + "?mode=memory&cache=shared"
+	db, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{
+		TranslateError: true,
+		Logger:         logger.Discard,
+	})
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	t.Cleanup(func() { sqlDB.Close() })
+	if err := migrate(db); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	return db
+}
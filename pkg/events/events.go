@@ -0,0 +1,104 @@
+// Package events defines the domain events services exchange and the
+// interfaces the transports carrying them implement.
+//
+// Events are delivered at least once: publishers may repeat an event after a
+// failure, so handlers must be idempotent, using Event.ID to spot repeats.
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+)
+
+// Event types published by the auth service.
+const (
+	TypeUserRegistered  = "auth.user_registered"
+	TypeEmailChanged    = "auth.email_changed"
+	TypeUsernameChanged = "auth.username_changed"
+	TypeAccountDeleted  = "auth.account_deleted"
+)
+
+// Event is the envelope every domain event travels in. Key identifies the
+// aggregate the event is about (the auth user ID for account events);
+// transports that partition or order messages do so by key.
+//
+// Seq grows with every event a publisher records, so a consumer receiving
+// events for a key out of order can recognise the stale ones. It is zero
+// when the publisher does not number its events.
+type Event struct {
+	ID         string          `json:"id"`
+	Type       string          `json:"type"`
+	Key        string          `json:"key"`
+	Seq        uint64          `json:"seq,omitempty"`
+	OccurredAt time.Time       `json:"occurred_at"`
+	Payload    json.RawMessage `json:"payload"`
+}
+
+// New wraps payload in an event envelope.
+func New(id, typ, key string, occurredAt time.Time, payload interface{}) (Event, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return Event{}, err
+	}
+	return Event{ID: id, Type: typ, Key: key, OccurredAt: occurredAt, Payload: data}, nil
+}
+
+// Decode unmarshals the event's payload into v.
+func (e Event) Decode(v interface{}) error {
+	return json.Unmarshal(e.Payload, v)
+}
+
+// UserRegistered is published when an account is created.
+type UserRegistered struct {
+	UserID   string `json:"user_id"`
+	Username string `json:"username"`
+	Email    string `json:"email"`
+}
+
+// EmailChanged is published when an account switches to a confirmed new
+// email address. Username lets a consumer that missed the registration
+// create the profile.
+type EmailChanged struct {
+	UserID   string `json:"user_id"`
+	Username string `json:"username"`
+	OldEmail string `json:"old_email"`
+	NewEmail string `json:"new_email"`
+}
+
+// UsernameChanged is published when an account is renamed.
+type UsernameChanged struct {
+	UserID      string `json:"user_id"`
+	Email       string `json:"email"`
+	OldUsername string `json:"old_username"`
+	NewUsername string `json:"new_username"`
+}
+
+// AccountDeleted is published when an account is permanently deleted.
+type AccountDeleted struct {
+	UserID string `json:"user_id"`
+	Email  string `json:"email"`
+}
+
+// Handler processes one event. Returning an error asks the transport to
+// deliver the event again later, where it supports redelivery.
+type Handler func(ctx context.Context, e Event) error
+
+// Publisher sends events. Publish returns once the transport has accepted
+// the event.
+type Publisher interface {
+	Publish(ctx context.Context, e Event) error
+}
+
+// Subscriber delivers events to a handler. Subscribe blocks until ctx is
+// cancelled or the subscription fails.
+type Subscriber interface {
+	Subscribe(ctx context.Context, h Handler) error
+}
+
+// Bus is a transport that can both publish and subscribe.
+type Bus interface {
+	Publisher
+	Subscriber
+	Close() error
+}
@@ -0,0 +1,92 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// ErrClosed is returned when publishing to a closed bus.
+var ErrClosed = errors.New("events: bus closed")
+
+// MemoryBus delivers events to subscribers in the same process. Publish
+// runs every handler synchronously and returns the first error, so the
+// publisher retries exactly as it would after a broker failure. It needs
+// no external services, which makes it the transport for tests.
+type MemoryBus struct {
+	mu       sync.RWMutex
+	handlers map[int]Handler
+	next     int
+	closed   bool
+	done     chan struct{}
+}
+
+func NewMemoryBus() *MemoryBus {
+	return &MemoryBus{handlers: make(map[int]Handler), done: make(chan struct{})}
+}
+
+func (b *MemoryBus) Publish(ctx context.Context, e Event) error {
+	b.mu.RLock()
+	if b.closed {
+		b.mu.RUnlock()
+		return ErrClosed
+	}
+	handlers := make([]Handler, 0, len(b.handlers))
+	for _, h := range b.handlers {
+		handlers = append(handlers, h)
+	}
+	b.mu.RUnlock()
+
+	var first error
+	for _, h := range handlers {
+		if err := h(ctx, e); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
+
+// Subscribe registers h until ctx is cancelled or the bus is closed.
+func (b *MemoryBus) Subscribe(ctx context.Context, h Handler) error {
+	stop, err := b.Listen(h)
+	if err != nil {
+		return err
+	}
+	defer stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-b.done:
+		return ErrClosed
+	}
+}
+
+// Listen registers h until stop is called. Unlike Subscribe it returns as
+// soon as h is registered, so events published afterwards are sure to
+// reach it.
+func (b *MemoryBus) Listen(h Handler) (stop func(), err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return nil, ErrClosed
+	}
+	id := b.next
+	b.next++
+	b.handlers[id] = h
+	return func() {
+		b.mu.Lock()
+		delete(b.handlers, id)
+		b.mu.Unlock()
+	}, nil
+}
+
+func (b *MemoryBus) Close() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if !b.closed {
+		b.closed = true
+		close(b.done)
+	}
+	return nil
+}
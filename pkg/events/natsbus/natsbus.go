@@ -0,0 +1,121 @@
+// Package natsbus carries events over NATS JetStream, which stores them
+// until every durable consumer has acknowledged them.
+package natsbus
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"go-microservices/pkg/events"
+
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+const (
+	// DefaultStream is the JetStream stream events are stored in.
+	DefaultStream = "EVENTS"
+	// subjectPrefix is prepended to the event type to form the subject.
+	subjectPrefix = "events."
+	// retryDelay is how long a failed event waits before redelivery.
+	retryDelay = 5 * time.Second
+	// maxDeliver bounds redeliveries, so an event that can never be handled
+	// does not hold back the ones after it forever.
+	maxDeliver = 20
+)
+
+// Bus publishes to and consumes from one JetStream stream.
+type Bus struct {
+	nc     *nats.Conn
+	js     jetstream.JetStream
+	stream string
+	// durable names the consumer Subscribe uses; services consuming the
+	// same events each need their own.
+	durable string
+}
+
+// New connects to url and creates the stream if it does not exist.
+func New(ctx context.Context, url, stream, durable string) (*Bus, error) {
+	if stream == "" {
+		stream = DefaultStream
+	}
+	nc, err := nats.Connect(url, nats.Name(durable), nats.MaxReconnects(-1))
+	if err != nil {
+		return nil, fmt.Errorf("natsbus: connect: %w", err)
+	}
+	js, err := jetstream.New(nc)
+	if err != nil {
+		nc.Close()
+		return nil, fmt.Errorf("natsbus: jetstream: %w", err)
+	}
+	if _, err := js.CreateOrUpdateStream(ctx, jetstream.StreamConfig{
+		Name:     stream,
+		Subjects: []string{subjectPrefix + ">"},
+		Storage:  jetstream.FileStorage,
+	}); err != nil {
+		nc.Close()
+		return nil, fmt.Errorf("natsbus: create stream %s: %w", stream, err)
+	}
+	return &Bus{nc: nc, js: js, stream: stream, durable: durable}, nil
+}
+
+// Publish sends the event with its ID as the message ID, so JetStream drops
+// an event published again within its duplicate window.
+func (b *Bus) Publish(ctx context.Context, e events.Event) error {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	msg := &nats.Msg{Subject: subjectPrefix + e.Type, Data: data}
+	if _, err := b.js.PublishMsg(ctx, msg, jetstream.WithMsgID(e.ID)); err != nil {
+		return fmt.Errorf("natsbus: publish %s: %w", e.Type, err)
+	}
+	return nil
+}
+
+// Subscribe consumes through the bus's durable consumer until ctx is
+// cancelled. Events are handled one at a time so they are applied in the
+// order they were published; a handler error redelivers the event later.
+func (b *Bus) Subscribe(ctx context.Context, h events.Handler) error {
+	if b.durable == "" {
+		return fmt.Errorf("natsbus: subscribing requires a durable consumer name")
+	}
+	cons, err := b.js.CreateOrUpdateConsumer(ctx, b.stream, jetstream.ConsumerConfig{
+		Durable:       b.durable,
+		AckPolicy:     jetstream.AckExplicitPolicy,
+		MaxAckPending: 1,
+		MaxDeliver:    maxDeliver,
+	})
+	if err != nil {
+		return fmt.Errorf("natsbus: create consumer %s: %w", b.durable, err)
+	}
+
+	cc, err := cons.Consume(func(msg jetstream.Msg) {
+		var e events.Event
+		if err := json.Unmarshal(msg.Data(), &e); err != nil {
+			log.Printf("natsbus: dropping malformed message on %s: %v", msg.Subject(), err)
+			msg.Term()
+			return
+		}
+		if err := h(ctx, e); err != nil {
+			log.Printf("natsbus: event %s (%s) failed, will retry: %v", e.ID, e.Type, err)
+			msg.NakWithDelay(retryDelay)
+			return
+		}
+		msg.Ack()
+	})
+	if err != nil {
+		return fmt.Errorf("natsbus: consume: %w", err)
+	}
+	defer cc.Stop()
+
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func (b *Bus) Close() error {
+	return b.nc.Drain()
+}
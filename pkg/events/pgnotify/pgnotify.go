@@ -0,0 +1,114 @@
+// Package pgnotify carries events over Postgres LISTEN/NOTIFY, so services
+// sharing a database can exchange events without a broker.
+//
+// NOTIFY is fire-and-forget: events published while no subscriber is
+// listening are lost, and a failing handler cannot ask for redelivery. It
+// suits local development; production should use a broker such as NATS.
+package pgnotify
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"go-microservices/pkg/events"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// DefaultChannel is the notification channel used when none is given.
+const DefaultChannel = "domain_events"
+
+const (
+	reconnectDelay = 2 * time.Second
+	handleAttempts = 3
+)
+
+// Bus publishes with NOTIFY and subscribes with LISTEN on one channel.
+type Bus struct {
+	dsn     string
+	channel string
+	pool    *pgxpool.Pool
+}
+
+func New(ctx context.Context, dsn, channel string) (*Bus, error) {
+	if channel == "" {
+		channel = DefaultChannel
+	}
+	pool, err := pgxpool.New(ctx, dsn)
+	if err != nil {
+		return nil, fmt.Errorf("pgnotify: connect: %w", err)
+	}
+	return &Bus{dsn: dsn, channel: channel, pool: pool}, nil
+}
+
+func (b *Bus) Publish(ctx context.Context, e events.Event) error {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	if _, err := b.pool.Exec(ctx, "SELECT pg_notify($1, $2)", b.channel, string(data)); err != nil {
+		return fmt.Errorf("pgnotify: notify: %w", err)
+	}
+	return nil
+}
+
+// Subscribe listens on a dedicated connection, reconnecting after failures
+// until ctx is cancelled. A handler error is retried a few times and then
+// logged, since the notification cannot be delivered again.
+func (b *Bus) Subscribe(ctx context.Context, h events.Handler) error {
+	for {
+		err := b.listen(ctx, h)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		log.Printf("pgnotify: listener on %q failed: %v; reconnecting", b.channel, err)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(reconnectDelay):
+		}
+	}
+}
+
+func (b *Bus) listen(ctx context.Context, h events.Handler) error {
+	conn, err := pgx.Connect(ctx, b.dsn)
+	if err != nil {
+		return err
+	}
+	defer conn.Close(context.Background())
+
+	if _, err := conn.Exec(ctx, "LISTEN "+pgx.Identifier{b.channel}.Sanitize()); err != nil {
+		return err
+	}
+	for {
+		n, err := conn.WaitForNotification(ctx)
+		if err != nil {
+			return err
+		}
+		var e events.Event
+		if err := json.Unmarshal([]byte(n.Payload), &e); err != nil {
+			log.Printf("pgnotify: dropping malformed event on %q: %v", b.channel, err)
+			continue
+		}
+		for attempt := 1; ; attempt++ {
+			err := h(ctx, e)
+			if err == nil {
+				break
+			}
+			if attempt == handleAttempts || ctx.Err() != nil {
+				log.Printf("pgnotify: giving up on event %s (%s): %v", e.ID, e.Type, err)
+				break
+			}
+			time.Sleep(time.Duration(attempt) * time.Second)
+		}
+	}
+}
+
+func (b *Bus) Close() error {
+	b.pool.Close()
+	return nil
+}
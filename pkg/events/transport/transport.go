@@ -0,0 +1,45 @@
+// Package transport opens the event bus a service is configured to use.
+package transport
+
+import (
+	"context"
+	"fmt"
+
+	"go-microservices/pkg/events"
+	"go-microservices/pkg/events/natsbus"
+	"go-microservices/pkg/events/pgnotify"
+)
+
+// Transports accepted in Config.Kind. There is deliberately no in-memory
+// transport: the auth and user services run in separate processes, so
+// events published to one would never reach the other.
+const (
+	Postgres = "postgres"
+	NATS     = "nats"
+)
+
+// Config selects and configures a transport.
+type Config struct {
+	// Kind is postgres or nats.
+	Kind string
+	// PostgresDSN is the database whose LISTEN/NOTIFY the postgres
+	// transport uses.
+	PostgresDSN string
+	// NATSURL is the NATS server the nats transport connects to.
+	NATSURL string
+	// Consumer names the service's durable subscription, where the
+	// transport keeps one.
+	Consumer string
+}
+
+// Open returns the configured bus.
+func Open(ctx context.Context, cfg Config) (events.Bus, error) {
+	switch cfg.Kind {
+	case Postgres:
+		return pgnotify.New(ctx, cfg.PostgresDSN, pgnotify.DefaultChannel)
+	case NATS:
+		return natsbus.New(ctx, cfg.NATSURL, natsbus.DefaultStream, cfg.Consumer)
+	default:
+		return nil, fmt.Errorf("unknown events transport %q", cfg.Kind)
+	}
+}
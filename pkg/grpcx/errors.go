@@ -0,0 +1,28 @@
+package grpcx
+
+import (
+	"strings"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Violation describes one invalid request field.
+func Violation(field, description string) *errdetails.BadRequest_FieldViolation {
+	return &errdetails.BadRequest_FieldViolation{Field: field, Description: description}
+}
+
+// BadRequest returns an InvalidArgument status carrying the violations as
+// BadRequest details, so the gateway can report them per field.
+func BadRequest(violations ...*errdetails.BadRequest_FieldViolation) error {
+	msgs := make([]string, 0, len(violations))
+	for _, v := range violations {
+		msgs = append(msgs, v.GetField()+": "+v.GetDescription())
+	}
+	st := status.New(codes.InvalidArgument, strings.Join(msgs, "; "))
+	if detailed, err := st.WithDetails(&errdetails.BadRequest{FieldViolations: violations}); err == nil {
+		return detailed.Err()
+	}
+	return st.Err()
+}
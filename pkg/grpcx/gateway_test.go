@@ -0,0 +1,56 @@
+package grpcx
+
+import (
+	"context"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func TestGatewayAuth(t *testing.T) {
+	tests := []struct {
+		name   string
+		secret string
+		md     metadata.MD
+		want   codes.Code
+	}{
+		{"matching secret", "s3cret", metadata.Pairs(MetadataGatewaySecret, "s3cret"), codes.OK},
+		{"no secret", "s3cret", metadata.MD{}, codes.Unauthenticated},
+		{"wrong secret", "s3cret", metadata.Pairs(MetadataGatewaySecret, "guess"), codes.Unauthenticated},
+		{"unconfigured", "", metadata.Pairs(MetadataGatewaySecret, ""), codes.Unauthenticated},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := metadata.NewIncomingContext(context.Background(), tt.md)
+			var fromGateway bool
+			_, err := GatewayAuth(tt.secret)(ctx, nil, &grpc.UnaryServerInfo{}, func(ctx context.Context, req interface{}) (interface{}, error) {
+				fromGateway = FromGateway(ctx)
+				return nil, nil
+			})
+			if got := status.Code(err); got != tt.want {
+				t.Fatalf("got %v, want %v", got, tt.want)
+			}
+			if fromGateway != (tt.want == codes.OK) {
+				t.Fatalf("FromGateway = %v inside the handler", fromGateway)
+			}
+		})
+	}
+}
+
+func TestSendGatewaySecret(t *testing.T) {
+	var got []string
+	invoker := func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+		md, _ := metadata.FromOutgoingContext(ctx)
+		got = md.Get(MetadataGatewaySecret)
+		return nil
+	}
+	if err := SendGatewaySecret("s3cret")(context.Background(), "/svc/Method", nil, nil, nil, invoker); err != nil {
+		t.Fatalf("interceptor: %v", err)
+	}
+	if len(got) != 1 || got[0] != "s3cret" {
+		t.Fatalf("sent secret %v", got)
+	}
+}
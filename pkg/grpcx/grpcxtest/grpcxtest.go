@@ -0,0 +1,30 @@
+// Package grpcxtest helps tests call service handlers directly, the way
+// they are called behind the grpcx interceptors.
+package grpcxtest
+
+import (
+	"context"
+
+	"go-microservices/pkg/grpcx"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+const secret = "grpcxtest"
+
+// FromGateway returns a context carrying md as incoming metadata on a call
+// grpcx.GatewayAuth has authenticated as coming from the gateway.
+func FromGateway(md metadata.MD) context.Context {
+	md = metadata.Join(md, metadata.Pairs(grpcx.MetadataGatewaySecret, secret))
+	var authed context.Context
+	_, err := grpcx.GatewayAuth(secret)(metadata.NewIncomingContext(context.Background(), md), nil, &grpc.UnaryServerInfo{},
+		func(ctx context.Context, req interface{}) (interface{}, error) {
+			authed = ctx
+			return nil, nil
+		})
+	if err != nil {
+		panic(err)
+	}
+	return authed
+}
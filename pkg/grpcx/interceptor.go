@@ -0,0 +1,40 @@
+// Package grpcx holds the gRPC server plumbing shared by the services.
+package grpcx
+
+import (
+	"context"
+	"log"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// UnaryInterceptor reports calls that ran out of time or were abandoned by
+// the gateway as DeadlineExceeded/Canceled rather than Internal, and logs
+// failed calls with the request ID the gateway forwarded.
+func UnaryInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	resp, err := handler(ctx, req)
+	if err == nil {
+		return resp, nil
+	}
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		if code := status.Code(err); code == codes.Internal || code == codes.Unknown {
+			err = status.FromContextError(ctxErr).Err()
+		}
+	}
+	if code := status.Code(err); code == codes.Internal || code == codes.Unknown || code == codes.DeadlineExceeded {
+		log.Printf("%s failed (request %s): %v", info.FullMethod, RequestID(ctx), err)
+	}
+	return resp, err
+}
+
+// RequestID returns the request ID the gateway forwarded, or "-".
+func RequestID(ctx context.Context) string {
+	md, _ := metadata.FromIncomingContext(ctx)
+	if ids := md.Get("x-request-id"); len(ids) > 0 {
+		return ids[0]
+	}
+	return "-"
+}
@@ -1,26 +1,6 @@
-// -----------------------------------------------------------------------------
-// File: post.proto
-//
-// This Protocol Buffers file defines the gRPC contract for the PostService.
-// It includes service definitions for creating, retrieving, updating, deleting,
-// and listing posts. Each RPC method has corresponding request and response
-// messages. The syntax follows proto3 conventions and uses message types for
-// structured data exchange between microservices.
-//
-// Syntax:
-// - Uses proto3 syntax for modern Protocol Buffers features.
-// - Defines service and message types for CRUD operations.
-// - Supports repeated fields for listing multiple posts.
-//
-// Purpose:
-// - Enables strongly-typed, language-agnostic communication between services.
-// - Provides a contract for backend and API Gateway integration.
-// - Facilitates code generation for gRPC servers and clients.
-// -----------------------------------------------------------------------------
-
 // Code generated by protoc-gen-go. DO NOT EDIT.
 // versions:
-// 	protoc-gen-go v1.36.7
+// 	protoc-gen-go v1.36.10
 // 	protoc        v6.31.1
 // source: post.proto
 
@@ -126,9 +106,9 @@ func (x *Post) GetUpdatedAt() int64 {
 	return 0
 }
 
+// CreatePostRequest creates a post authored by the calling user.
 type CreatePostRequest struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
-	AuthorId      string                 `protobuf:"bytes,1,opt,name=author_id,json=authorId,proto3" json:"author_id,omitempty"`
 	Title         string                 `protobuf:"bytes,2,opt,name=title,proto3" json:"title,omitempty"`
 	Content       string                 `protobuf:"bytes,3,opt,name=content,proto3" json:"content,omitempty"`
 	unknownFields protoimpl.UnknownFields
@@ -165,13 +145,6 @@ func (*CreatePostRequest) Descriptor() ([]byte, []int) {
 	return file_post_proto_rawDescGZIP(), []int{1}
 }
 
-func (x *CreatePostRequest) GetAuthorId() string {
-	if x != nil {
-		return x.AuthorId
-	}
-	return ""
-}
-
 func (x *CreatePostRequest) GetTitle() string {
 	if x != nil {
 		return x.Title
@@ -521,6 +494,9 @@ func (x *ListPostsRequest) GetPageSize() int32 {
 type ListPostsResponse struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	Posts         []*Post                `protobuf:"bytes,1,rep,name=posts,proto3" json:"posts,omitempty"`
+	TotalCount    int64                  `protobuf:"varint,2,opt,name=total_count,json=totalCount,proto3" json:"total_count,omitempty"`
+	Page          int32                  `protobuf:"varint,3,opt,name=page,proto3" json:"page,omitempty"`
+	PageSize      int32                  `protobuf:"varint,4,opt,name=page_size,json=pageSize,proto3" json:"page_size,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
@@ -562,6 +538,27 @@ func (x *ListPostsResponse) GetPosts() []*Post {
 	return nil
 }
 
+func (x *ListPostsResponse) GetTotalCount() int64 {
+	if x != nil {
+		return x.TotalCount
+	}
+	return 0
+}
+
+func (x *ListPostsResponse) GetPage() int32 {
+	if x != nil {
+		return x.Page
+	}
+	return 0
+}
+
+func (x *ListPostsResponse) GetPageSize() int32 {
+	if x != nil {
+		return x.PageSize
+	}
+	return 0
+}
+
 var File_post_proto protoreflect.FileDescriptor
 
 const file_post_proto_rawDesc = "" +
@@ -576,11 +573,10 @@ const file_post_proto_rawDesc = "" +
 	"\n" +
 	"created_at\x18\x05 \x01(\x03R\tcreatedAt\x12\x1d\n" +
 	"\n" +
-	"updated_at\x18\x06 \x01(\x03R\tupdatedAt\"`\n" +
-	"\x11CreatePostRequest\x12\x1b\n" +
-	"\tauthor_id\x18\x01 \x01(\tR\bauthorId\x12\x14\n" +
+	"updated_at\x18\x06 \x01(\x03R\tupdatedAt\"T\n" +
+	"\x11CreatePostRequest\x12\x14\n" +
 	"\x05title\x18\x02 \x01(\tR\x05title\x12\x18\n" +
-	"\acontent\x18\x03 \x01(\tR\acontent\"4\n" +
+	"\acontent\x18\x03 \x01(\tR\acontentJ\x04\b\x01\x10\x02R\tauthor_id\"4\n" +
 	"\x12CreatePostResponse\x12\x1e\n" +
 	"\x04post\x18\x01 \x01(\v2\n" +
 	".post.PostR\x04post\" \n" +
@@ -600,10 +596,14 @@ const file_post_proto_rawDesc = "" +
 	"\x02id\x18\x01 \x01(\tR\x02id\"C\n" +
 	"\x10ListPostsRequest\x12\x12\n" +
 	"\x04page\x18\x01 \x01(\x05R\x04page\x12\x1b\n" +
-	"\tpage_size\x18\x02 \x01(\x05R\bpageSize\"5\n" +
+	"\tpage_size\x18\x02 \x01(\x05R\bpageSize\"\x87\x01\n" +
 	"\x11ListPostsResponse\x12 \n" +
 	"\x05posts\x18\x01 \x03(\v2\n" +
-	".post.PostR\x05posts2\xc4\x02\n" +
+	".post.PostR\x05posts\x12\x1f\n" +
+	"\vtotal_count\x18\x02 \x01(\x03R\n" +
+	"totalCount\x12\x12\n" +
+	"\x04page\x18\x03 \x01(\x05R\x04page\x12\x1b\n" +
+	"\tpage_size\x18\x04 \x01(\x05R\bpageSize2\xc4\x02\n" +
 	"\vPostService\x12?\n" +
 	"\n" +
 	"CreatePost\x12\x17.post.CreatePostRequest\x1a\x18.post.CreatePostResponse\x126\n" +
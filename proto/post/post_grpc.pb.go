@@ -1,23 +1,3 @@
-// -----------------------------------------------------------------------------
-// File: post.proto
-//
-// This Protocol Buffers file defines the gRPC contract for the PostService.
-// It includes service definitions for creating, retrieving, updating, deleting,
-// and listing posts. Each RPC method has corresponding request and response
-// messages. The syntax follows proto3 conventions and uses message types for
-// structured data exchange between microservices.
-//
-// Syntax:
-// - Uses proto3 syntax for modern Protocol Buffers features.
-// - Defines service and message types for CRUD operations.
-// - Supports repeated fields for listing multiple posts.
-//
-// Purpose:
-// - Enables strongly-typed, language-agnostic communication between services.
-// - Provides a contract for backend and API Gateway integration.
-// - Facilitates code generation for gRPC servers and clients.
-// -----------------------------------------------------------------------------
-
 // Code generated by protoc-gen-go-grpc. DO NOT EDIT.
 // versions:
 // - protoc-gen-go-grpc v1.5.1
@@ -1,17 +1,30 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"net"
 	"os"
+	"time"
 
+	"go-microservices/pkg/events/transport"
+	"go-microservices/pkg/grpcx"
 	pb "go-microservices/proto/auth"
 
+	"go-microservices/services/auth-service/config"
 	"go-microservices/services/auth-service/internal/database"
+	"go-microservices/services/auth-service/internal/keys"
+	"go-microservices/services/auth-service/internal/mailer"
+	"go-microservices/services/auth-service/internal/outbox"
+	"go-microservices/services/auth-service/internal/password"
+	"go-microservices/services/auth-service/internal/rbac"
 	"go-microservices/services/auth-service/internal/repository"
+	"go-microservices/services/auth-service/internal/revocation"
 	"go-microservices/services/auth-service/internal/server"
+	"go-microservices/services/auth-service/internal/utils"
 
+	"github.com/redis/go-redis/v9"
 	"google.golang.org/grpc"
 )
 
@@ -31,10 +44,81 @@ func main() {
 		log.Fatalf("failed to init database: %v", err)
 	}
 
+	env := config.LoadEnv()
+	if env.GatewaySecret == "" {
+		log.Fatal("GATEWAY_SECRET is required")
+	}
 	repo := repository.NewRepository(db)
-	srv := server.NewAuthServer(repo)
 
-	grpcServer := grpc.NewServer()
+	if err := rbac.Seed(context.Background(), repo, env.AdminEmail); err != nil {
+		log.Fatalf("failed to seed roles: %v", err)
+	}
+
+	if env.JWTSigningAlg != keys.AlgHS256 {
+		ring, err := keys.NewRing(repo, env.JWTSigningAlg, env.JWTKeyRotation, utils.RefreshTokenTTL)
+		if err != nil {
+			log.Fatalf("invalid JWT signing configuration: %v", err)
+		}
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		if err := ring.Load(ctx); err != nil {
+			log.Fatalf("failed to load JWT signing keys: %v", err)
+		}
+		go ring.Run(ctx, time.Hour)
+		utils.UseKeyRing(ring)
+		log.Printf("Signing JWTs with %s", env.JWTSigningAlg)
+	}
+
+	var revoked revocation.Store = revocation.NewPostgresStore(repo)
+	if env.RedisAddr != "" {
+		rdb := redis.NewClient(&redis.Options{
+			Addr:     env.RedisAddr,
+			Password: env.RedisPassword,
+			DB:       env.RedisDB,
+		})
+		defer rdb.Close()
+		revoked = revocation.NewRedisCache(rdb, revoked)
+		log.Printf("Caching token revocations in Redis at %s", env.RedisAddr)
+	}
+
+	opts := []server.Option{
+		server.WithConfig(env),
+		server.WithRevocationStore(revoked),
+		server.WithMailer(mailer.New(env)),
+	}
+	if env.BreachedPasswordsFile != "" {
+		list, err := password.LoadHashFile(env.BreachedPasswordsFile)
+		if err != nil {
+			log.Fatalf("failed to load breached passwords: %v", err)
+		}
+		opts = append(opts, server.WithBreachedPasswords(list))
+		log.Printf("Screening passwords against %d breached password hashes", list.Len())
+	}
+
+	if env.EventsTransport != "" {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		bus, err := transport.Open(ctx, transport.Config{
+			Kind:        env.EventsTransport,
+			PostgresDSN: database.DSN(),
+			NATSURL:     env.NATSURL,
+		})
+		if err != nil {
+			log.Fatalf("failed to open events transport: %v", err)
+		}
+		defer bus.Close()
+		go outbox.NewRelay(repo, bus).Run(ctx, env.OutboxInterval)
+		log.Printf("Publishing domain events over %s", env.EventsTransport)
+		if env.EventsTransport == transport.Postgres {
+			log.Println("The postgres events transport does not store events; any the user service misses are lost")
+		}
+	} else {
+		log.Println("EVENTS_TRANSPORT not set; domain events will wait in the outbox")
+	}
+
+	srv := server.NewAuthServer(repo, opts...)
+
+	grpcServer := grpc.NewServer(grpc.ChainUnaryInterceptor(grpcx.GatewayAuth(env.GatewaySecret), grpcx.UnaryInterceptor))
 	pb.RegisterAuthServiceServer(grpcServer, srv)
 	log.Printf("Auth Service listening on %s", port)
 	if err := grpcServer.Serve(lis); err != nil {
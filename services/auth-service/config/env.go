@@ -3,20 +3,194 @@ package config
 import (
 	"os"
 	"strconv"
+	"time"
 )
 
 type Env struct {
-	Port           int
-	JWTSecret      string
-	TokenDuration  int
-	DatabaseURL    string
-	RedisAddr      string
-	RedisPassword  string
-	RedisDB        int
-	EmailHost      string
-	EmailPort      int
-	EmailUsername  string
-	EmailPassword  string
-	EmailFrom      string
-	FrontendURL    string
-}
\ No newline at end of file
+	Port          int
+	JWTSecret     string
+	TokenDuration int
+	DatabaseURL   string
+	RedisAddr     string
+	RedisPassword string
+	RedisDB       int
+	EmailHost     string
+	EmailPort     int
+	EmailUsername string
+	EmailPassword string
+	EmailFrom     string
+	FrontendURL   string
+
+	// GatewaySecret authenticates the gateway's calls; calls without it are
+	// refused. It must match the gateway's GATEWAY_SECRET.
+	GatewaySecret string
+
+	// JWTSigningAlg selects HS256 (shared secret), RS256 or EdDSA signing.
+	JWTSigningAlg string
+	// JWTKeyRotation is how often a new asymmetric signing key is generated.
+	JWTKeyRotation time.Duration
+
+	// RequireVerifiedEmail makes SignIn refuse accounts that have not
+	// confirmed their email address yet.
+	RequireVerifiedEmail bool
+
+	// LockoutThreshold failed sign-ins within LockoutDuration lock an account
+	// for LockoutDuration. IPLockoutThreshold does the same per client IP.
+	LockoutThreshold   int
+	IPLockoutThreshold int
+	LockoutDuration    time.Duration
+
+	// PasswordMinLength, PasswordMaxBytes and PasswordMinClasses make up the
+	// password policy. PasswordMaxBytes defaults to what PasswordHash can
+	// take; see passwordMaxBytes. BreachedPasswordsFile optionally names a
+	// list of SHA-1 hashes of breached passwords to reject.
+	PasswordMinLength     int
+	PasswordMaxBytes      int
+	PasswordMinClasses    int
+	BreachedPasswordsFile string
+
+	// PasswordHash selects the algorithm new password hashes use, argon2id
+	// or bcrypt. Hashes made with the other algorithm, or with weaker
+	// parameters, are upgraded when their owner next signs in.
+	PasswordHash      string
+	Argon2Memory      int
+	Argon2Iterations  int
+	Argon2Parallelism int
+	BcryptCost        int
+
+	// UsernameChangeCooldown is how long after a username change the next
+	// one is refused.
+	UsernameChangeCooldown time.Duration
+
+	// EventsTransport selects how domain events leave the outbox: postgres
+	// (LISTEN/NOTIFY, for local development) or nats; anything else stops
+	// the service at startup. When empty events are stored but not
+	// published. OutboxInterval is how often the outbox
+	// is checked for new events.
+	EventsTransport string
+	NATSURL         string
+	OutboxInterval  time.Duration
+
+	// MagicLinkLimit is how many sign-in links one address may be sent
+	// within MagicLinkWindow.
+	MagicLinkLimit  int
+	MagicLinkWindow time.Duration
+
+	// TOTPIssuer names the service in authenticator apps.
+	TOTPIssuer string
+
+	// OIDCIssuer is the public base URL of the gateway serving the OAuth and
+	// OpenID Connect endpoints. It is the iss claim of ID tokens.
+	OIDCIssuer string
+
+	// AdminEmail is granted the admin role when that account signs up, or at
+	// startup if it already exists.
+	AdminEmail string
+}
+
+const (
+	// bcryptMaxBytes is the most bcrypt accepts; it ignores anything longer.
+	bcryptMaxBytes = 72
+	// argon2MaxBytes only bounds the work a single sign-in can cause, since
+	// argon2id hashes passwords of any length.
+	argon2MaxBytes = 1024
+)
+
+func LoadEnv() *Env {
+	passwordHash := getEnv("PASSWORD_HASH", "argon2id")
+	return &Env{
+		Port:          getEnvInt("PORT", 50051),
+		JWTSecret:     os.Getenv("JWT_SECRET"),
+		TokenDuration: getEnvInt("TOKEN_DURATION", 15),
+		DatabaseURL:   os.Getenv("DATABASE_URL"),
+		RedisAddr:     os.Getenv("REDIS_ADDR"),
+		RedisPassword: os.Getenv("REDIS_PASSWORD"),
+		RedisDB:       getEnvInt("REDIS_DB", 0),
+		EmailHost:     os.Getenv("EMAIL_HOST"),
+		EmailPort:     getEnvInt("EMAIL_PORT", 587),
+		EmailUsername: os.Getenv("EMAIL_USERNAME"),
+		EmailPassword: os.Getenv("EMAIL_PASSWORD"),
+		EmailFrom:     os.Getenv("EMAIL_FROM"),
+		FrontendURL:   getEnv("FRONTEND_URL", "http://localhost:3000"),
+
+		GatewaySecret: os.Getenv("GATEWAY_SECRET"),
+
+		JWTSigningAlg:  getEnv("JWT_SIGNING_ALG", "HS256"),
+		JWTKeyRotation: getEnvDuration("JWT_KEY_ROTATION", 30*24*time.Hour),
+
+		RequireVerifiedEmail: getEnvBool("REQUIRE_VERIFIED_EMAIL", false),
+
+		LockoutThreshold:   getEnvInt("LOCKOUT_THRESHOLD", 5),
+		IPLockoutThreshold: getEnvInt("IP_LOCKOUT_THRESHOLD", 20),
+		LockoutDuration:    getEnvDuration("LOCKOUT_DURATION", 15*time.Minute),
+
+		PasswordMinLength:     getEnvInt("PASSWORD_MIN_LENGTH", 10),
+		PasswordMaxBytes:      passwordMaxBytes(passwordHash),
+		PasswordMinClasses:    getEnvInt("PASSWORD_MIN_CLASSES", 2),
+		BreachedPasswordsFile: os.Getenv("BREACHED_PASSWORDS_FILE"),
+
+		PasswordHash:      passwordHash,
+		Argon2Memory:      getEnvInt("ARGON2_MEMORY_KIB", 64*1024),
+		Argon2Iterations:  getEnvInt("ARGON2_ITERATIONS", 3),
+		Argon2Parallelism: getEnvInt("ARGON2_PARALLELISM", 4),
+		BcryptCost:        getEnvInt("BCRYPT_COST", 12),
+
+		UsernameChangeCooldown: getEnvDuration("USERNAME_CHANGE_COOLDOWN", 30*24*time.Hour),
+
+		EventsTransport: os.Getenv("EVENTS_TRANSPORT"),
+		NATSURL:         getEnv("NATS_URL", "nats://localhost:4222"),
+		OutboxInterval:  getEnvDuration("OUTBOX_INTERVAL", time.Second),
+
+		MagicLinkLimit:  getEnvInt("MAGIC_LINK_LIMIT", 3),
+		MagicLinkWindow: getEnvDuration("MAGIC_LINK_WINDOW", time.Hour),
+
+		TOTPIssuer: getEnv("TOTP_ISSUER", "go-microservices"),
+
+		OIDCIssuer: getEnv("OIDC_ISSUER", "http://localhost:8080"),
+
+		AdminEmail: os.Getenv("ADMIN_EMAIL"),
+	}
+}
+
+// passwordMaxBytes reads PASSWORD_MAX_BYTES, defaulting to the limit of the
+// hash algorithm. bcrypt never gets more than it can hash.
+func passwordMaxBytes(hash string) int {
+	if hash == "bcrypt" {
+		if n := getEnvInt("PASSWORD_MAX_BYTES", bcryptMaxBytes); n > 0 && n < bcryptMaxBytes {
+			return n
+		}
+		return bcryptMaxBytes
+	}
+	return getEnvInt("PASSWORD_MAX_BYTES", argon2MaxBytes)
+}
+
+func getEnv(key, fallback string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return fallback
+}
+
+func getEnvInt(key string, fallback int) int {
+	v, err := strconv.Atoi(os.Getenv(key))
+	if err != nil {
+		return fallback
+	}
+	return v
+}
+
+func getEnvBool(key string, fallback bool) bool {
+	v, err := strconv.ParseBool(os.Getenv(key))
+	if err != nil {
+		return fallback
+	}
+	return v
+}
+
+func getEnvDuration(key string, fallback time.Duration) time.Duration {
+	v, err := time.ParseDuration(os.Getenv(key))
+	if err != nil {
+		return fallback
+	}
+	return v
+}
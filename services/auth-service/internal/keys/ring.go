@@ -0,0 +1,169 @@
+package keys
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"go-microservices/services/auth-service/internal/models"
+)
+
+// Store persists the key ring so every auth-service replica signs and
+// verifies with the same keys.
+type Store interface {
+	ListSigningKeys(ctx context.Context) ([]models.SigningKey, error)
+	CreateSigningKey(ctx context.Context, k *models.SigningKey) error
+	DeleteRetiredSigningKeys(ctx context.Context) error
+}
+
+// Ring holds the asymmetric keys used to sign and verify JWTs. The newest key
+// signs; every key that has not retired yet stays available for
+// verification, so tokens signed before a rotation remain valid until they
+// expire.
+type Ring struct {
+	store    Store
+	alg      string
+	rotation time.Duration
+	grace    time.Duration
+
+	mu   sync.RWMutex
+	keys []*Key // newest first
+}
+
+// NewRing creates a ring that signs with alg and rotates to a fresh key every
+// rotation period. grace should be at least the longest token lifetime: a key
+// is kept for verification until rotation+grace after it was created.
+func NewRing(store Store, alg string, rotation, grace time.Duration) (*Ring, error) {
+	if alg != AlgRS256 && alg != AlgEdDSA {
+		return nil, fmt.Errorf("unsupported signing algorithm %q", alg)
+	}
+	if rotation <= 0 {
+		return nil, errors.New("key rotation period must be positive")
+	}
+	return &Ring{store: store, alg: alg, rotation: rotation, grace: grace}, nil
+}
+
+// Load reads the ring from the store, generating a new signing key when the
+// store is empty or the newest key is due for rotation.
+func (r *Ring) Load(ctx context.Context) error {
+	stored, err := r.store.ListSigningKeys(ctx)
+	if err != nil {
+		return fmt.Errorf("list signing keys: %w", err)
+	}
+
+	loaded := make([]*Key, 0, len(stored))
+	for _, m := range stored {
+		k, err := fromModel(m)
+		if err != nil {
+			log.Printf("skipping unreadable signing key %s: %v", m.KID, err)
+			continue
+		}
+		loaded = append(loaded, k)
+	}
+
+	r.mu.Lock()
+	r.keys = loaded
+	r.mu.Unlock()
+
+	if r.rotationDue(time.Now()) {
+		return r.Rotate(ctx)
+	}
+	return nil
+}
+
+// Rotate generates and persists a new signing key. Existing keys keep
+// verifying until they retire.
+func (r *Ring) Rotate(ctx context.Context) error {
+	now := time.Now()
+	k, err := generate(r.alg, now, now.Add(r.rotation+r.grace))
+	if err != nil {
+		return fmt.Errorf("generate signing key: %w", err)
+	}
+	m, err := toModel(k)
+	if err != nil {
+		return fmt.Errorf("encode signing key: %w", err)
+	}
+	if err := r.store.CreateSigningKey(ctx, m); err != nil {
+		return fmt.Errorf("store signing key: %w", err)
+	}
+
+	r.mu.Lock()
+	r.keys = append([]*Key{k}, r.keys...)
+	r.mu.Unlock()
+	log.Printf("rotated JWT signing key, new kid %s (%s)", k.ID, k.Algorithm)
+	return nil
+}
+
+// Run reloads the ring every interval, rotating when due and deleting
+// retired keys, until ctx is cancelled. Reloading also picks up keys rotated
+// by other replicas.
+func (r *Ring) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := r.store.DeleteRetiredSigningKeys(ctx); err != nil {
+				log.Printf("failed to delete retired signing keys: %v", err)
+			}
+			if err := r.Load(ctx); err != nil {
+				log.Printf("failed to refresh signing keys: %v", err)
+			}
+		}
+	}
+}
+
+// SigningKey returns the key new tokens should be signed with.
+func (r *Ring) SigningKey() (*Key, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for _, k := range r.keys {
+		if k.Algorithm == r.alg {
+			return k, nil
+		}
+	}
+	return nil, errors.New("no signing key available")
+}
+
+// VerificationKey looks up a key that has not retired by kid.
+func (r *Ring) VerificationKey(kid string) (*Key, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	now := time.Now()
+	for _, k := range r.keys {
+		if k.ID == kid && now.Before(k.RetiresAt) {
+			return k, true
+		}
+	}
+	return nil, false
+}
+
+// PublicKeys returns the JWKs of every key still valid for verification.
+func (r *Ring) PublicKeys() []JWK {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	now := time.Now()
+	jwks := make([]JWK, 0, len(r.keys))
+	for _, k := range r.keys {
+		if now.Before(k.RetiresAt) {
+			jwks = append(jwks, k.JWK())
+		}
+	}
+	return jwks
+}
+
+func (r *Ring) rotationDue(now time.Time) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for _, k := range r.keys {
+		if k.Algorithm == r.alg {
+			return now.Sub(k.CreatedAt) >= r.rotation
+		}
+	}
+	return true
+}
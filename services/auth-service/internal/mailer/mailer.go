@@ -0,0 +1,30 @@
+package mailer
+
+import (
+	"context"
+	"log"
+
+	"go-microservices/services/auth-service/config"
+)
+
+// Message is a plain-text email.
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer delivers transactional email such as confirmation and reset links.
+type Mailer interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// New returns an SMTP mailer when EMAIL_HOST is configured and an in-memory
+// mailer otherwise, so the service runs offline without a mail server.
+func New(env *config.Env) Mailer {
+	if env.EmailHost == "" {
+		log.Println("EMAIL_HOST not set; emails will be kept in memory and not delivered")
+		return NewMemoryMailer()
+	}
+	return NewSMTPMailer(env.EmailHost, env.EmailPort, env.EmailUsername, env.EmailPassword, env.EmailFrom)
+}
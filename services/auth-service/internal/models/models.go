@@ -8,16 +8,244 @@ import (
 
 type Auth struct {
 	gorm.Model
-	Username          string    `gorm:"uniqueIndex;not null"`
-	Email             string    `gorm:"uniqueIndex;not null"`
-	Password          string    `gorm:"not null"`
-	VerificationToken string    `json:"-"`
-	ResetToken        string    `json:"-"`
-	ResetTokenExpiry  time.Time `json:"-"`
-	Role              string    `gorm:"type:varchar(50);default:user" json:"role,omitempty"`
+	Username                string    `gorm:"uniqueIndex;not null"`
+	Email                   string    `gorm:"uniqueIndex;not null"`
+	Password                string    `gorm:"not null"`
+	EmailVerified           bool      `gorm:"default:false"`
+	VerificationToken       string    `gorm:"index" json:"-"`
+	VerificationTokenExpiry time.Time `json:"-"`
+	ResetToken              string    `gorm:"index" json:"-"`
+	ResetTokenExpiry        time.Time `json:"-"`
+
+	// PendingEmail is the address a ChangeEmail request is waiting to have
+	// confirmed. It replaces Email once the link sent to it is opened.
+	PendingEmail           string    `json:"-"`
+	EmailChangeToken       string    `gorm:"index" json:"-"`
+	EmailChangeTokenExpiry time.Time `json:"-"`
+	// UsernameChangedAt starts the cooldown before the username may be
+	// changed again.
+	UsernameChangedAt *time.Time `json:"-"`
+
+	// Failed sign-in tracking; see LoginIPFailure for the per-IP side.
+	FailedLoginCount  int        `gorm:"not null;default:0" json:"-"`
+	LastFailedLoginAt *time.Time `json:"-"`
+	LockedUntil       *time.Time `json:"-"`
+
+	// TOTP two-factor authentication. TOTPPendingSecret holds the secret of
+	// an enrollment until its first code is confirmed; TOTPLastStep is the
+	// last accepted time step, which stops codes from being replayed.
+	TOTPEnabled       bool   `gorm:"not null;default:false" json:"-"`
+	TOTPSecret        string `json:"-"`
+	TOTPPendingSecret string `json:"-"`
+	TOTPLastStep      int64  `gorm:"not null;default:0" json:"-"`
+}
+
+// APIKey is a long-lived credential for machine clients. Only the SHA-256
+// hash of the key is stored; Prefix is kept in clear so users can tell their
+// keys apart.
+type APIKey struct {
+	ID         uint      `gorm:"primaryKey"`
+	AuthID     uint      `gorm:"index;not null"`
+	Name       string    `gorm:"not null"`
+	Prefix     string    `gorm:"type:varchar(16);not null"`
+	KeyHash    string    `gorm:"type:varchar(64);uniqueIndex;not null" json:"-"`
+	Scopes     []string  `gorm:"serializer:json;type:text"`
+	ExpiresAt  *time.Time
+	LastUsedAt *time.Time
+	RevokedAt  *time.Time
+	CreatedAt  time.Time
+}
+
+// RecoveryCode is a single-use code that can stand in for a TOTP code. Only
+// its SHA-256 hash is stored.
+type RecoveryCode struct {
+	ID        uint   `gorm:"primaryKey"`
+	AuthID    uint   `gorm:"index;not null"`
+	CodeHash  string `gorm:"type:varchar(64);not null"`
+	UsedAt    *time.Time
+	CreatedAt time.Time
+}
+
+// MFAChallenge is issued by SignIn when the password was right but a second
+// factor is still needed. It is exchanged for tokens by VerifyMFA. Method is
+// the first factor the sign-in used, as an amr value.
+type MFAChallenge struct {
+	TokenHash string    `gorm:"primaryKey;type:varchar(64)"`
+	AuthID    uint      `gorm:"index;not null"`
+	Method    string    `gorm:"type:varchar(16);not null;default:pwd"`
+	ExpiresAt time.Time `gorm:"not null"`
+	CreatedAt time.Time
+}
+
+// MagicLink is a passwordless sign-in link emailed to Email, the address the
+// account had when the link was requested. Only the token's hash is stored;
+// used links are kept until they age out so they still count towards the
+// per-address request limit.
+type MagicLink struct {
+	TokenHash string    `gorm:"primaryKey;type:varchar(64)"`
+	AuthID    uint      `gorm:"not null"`
+	Email     string    `gorm:"index;not null"`
+	ExpiresAt time.Time `gorm:"not null"`
+	UsedAt    *time.Time
+	CreatedAt time.Time
+}
+
+// LoginIPFailure counts failed sign-ins from one client IP across all
+// accounts, so password spraying is throttled as well as guessing against a
+// single account.
+type LoginIPFailure struct {
+	IP            string    `gorm:"primaryKey;type:varchar(64)"`
+	Failures      int       `gorm:"not null"`
+	LastFailureAt time.Time `gorm:"not null"`
+	BlockedUntil  *time.Time
+}
+
+// Permission is a single capability checked by the gateway, named
+// "<resource>:<action>".
+type Permission struct {
+	Name        string `gorm:"primaryKey;type:varchar(100)"`
+	Description string
+}
+
+// Role groups permissions. A role also grants every permission of its
+// Parent, so roles form a hierarchy (admin ⊇ moderator ⊇ user).
+type Role struct {
+	Name        string `gorm:"primaryKey;type:varchar(50)"`
+	Description string
+	Parent      string `gorm:"type:varchar(50)"`
+	// RequireMFA withholds the role's permissions from sessions that did not
+	// pass a second factor.
+	RequireMFA  bool         `gorm:"not null;default:false"`
+	Permissions []Permission `gorm:"many2many:role_permissions;joinForeignKey:RoleName;joinReferences:PermissionName"`
+}
+
+// UserRole assigns a role to an account.
+type UserRole struct {
+	AuthID    uint   `gorm:"primaryKey"`
+	RoleName  string `gorm:"primaryKey;type:varchar(50)"`
+	CreatedAt time.Time
+}
+
+// RefreshToken records an issued refresh token so it can be rotated. Tokens
+// minted from the same sign-in share a FamilyID; presenting a token that was
+// already exchanged revokes every token in its family.
+type RefreshToken struct {
+	gorm.Model
+	AuthID    uint      `gorm:"index;not null"`
+	FamilyID  string    `gorm:"type:varchar(64);index;not null"`
+	TokenHash string    `gorm:"type:varchar(64);uniqueIndex;not null"`
+	ExpiresAt time.Time `gorm:"not null"`
+	UsedAt    *time.Time
+	RevokedAt *time.Time
+	// MFA records that the sign-in starting the family passed a second
+	// factor, so refreshed access tokens keep MFA-gated permissions.
+	MFA bool `gorm:"not null;default:false"`
+	// Method is the first factor that sign-in used, as an amr value, so
+	// refreshed access tokens keep reporting it.
+	Method string `gorm:"type:varchar(16);not null;default:pwd"`
+}
+
+// RevokedToken marks a single token, identified by its jti claim, as no longer
+// valid. Rows can be purged once ExpiresAt has passed.
+type RevokedToken struct {
+	JTI       string    `gorm:"primaryKey;type:varchar(64)"`
+	ExpiresAt time.Time `gorm:"index;not null"`
+	CreatedAt time.Time
+}
+
+// TokenCutoff invalidates every token issued to an account before
+// RevokedBefore, which is how all sessions are signed out at once.
+type TokenCutoff struct {
+	AuthID        uint      `gorm:"primaryKey"`
+	RevokedBefore time.Time `gorm:"not null"`
+	UpdatedAt     time.Time
+}
+
+// SigningKey is one entry of the asymmetric JWT key ring. PrivateKey holds the
+// PKCS#8 PEM encoding; the table must be protected like any other secret.
+type SigningKey struct {
+	KID        string    `gorm:"primaryKey;type:varchar(64)"`
+	Algorithm  string    `gorm:"type:varchar(16);not null"`
+	PrivateKey string    `gorm:"type:text;not null" json:"-"`
+	CreatedAt  time.Time `gorm:"index"`
+	RetiresAt  time.Time `gorm:"index;not null"`
 }
 
 type Test struct {
 	gorm.Model
 	Content string `gorm:"type:text;not null"`
 }
+
+// OAuthClient is an application registered to sign users in through the auth
+// service or to call the API as itself. Public clients have no secret.
+type OAuthClient struct {
+	ID           string   `gorm:"primaryKey;type:varchar(32)"`
+	Name         string   `gorm:"not null"`
+	SecretHash   string   `gorm:"type:varchar(64)" json:"-"`
+	RedirectURIs []string `gorm:"serializer:json;type:text"`
+	GrantTypes   []string `gorm:"serializer:json;type:text"`
+	Scopes       []string `gorm:"serializer:json;type:text"`
+	CreatedAt    time.Time
+}
+
+// AuthorizationCode is an issued, not yet redeemed authorization code. Only
+// the code's hash is stored. SessionID is the user's session, which tokens
+// issued for the code belong to. MFA records whether that session passed a
+// second factor and AMR its authentication methods, space-separated.
+type AuthorizationCode struct {
+	CodeHash      string `gorm:"primaryKey;type:varchar(64)"`
+	ClientID      string `gorm:"index;not null"`
+	AuthID        uint   `gorm:"not null"`
+	SessionID     string `gorm:"type:varchar(64);not null"`
+	RedirectURI   string `gorm:"not null"`
+	Scope         string
+	Nonce         string
+	AMR           string
+	CodeChallenge string    `gorm:"not null"`
+	MFA           bool      `gorm:"not null;default:false"`
+	ExpiresAt     time.Time `gorm:"not null"`
+	CreatedAt     time.Time
+}
+
+// AuditLog records an administrative action taken on an account. ActorID
+// is the admin who took it and AuthID the account it affected.
+type AuditLog struct {
+	ID        uint   `gorm:"primaryKey"`
+	Action    string `gorm:"index;not null"`
+	ActorID   uint   `gorm:"index;not null"`
+	AuthID    uint   `gorm:"index;not null"`
+	Reason    string
+	IP        string `gorm:"type:varchar(64)"`
+	UserAgent string
+	CreatedAt time.Time
+}
+
+// Session is a sign-in on one device. Its ID is the refresh-token family ID,
+// which tokens issued to the session carry as their sid claim.
+type Session struct {
+	ID         string `gorm:"primaryKey;type:varchar(64)"`
+	AuthID     uint   `gorm:"index;not null"`
+	UserAgent  string
+	IP         string `gorm:"type:varchar(64)"`
+	MFA        bool   `gorm:"not null;default:false"`
+	CreatedAt  time.Time
+	LastSeenAt time.Time `gorm:"not null"`
+	ExpiresAt  time.Time `gorm:"not null"`
+	RevokedAt  *time.Time
+}
+
+// OutboxEvent is a domain event waiting to be published. It is written in
+// the same transaction as the change it describes and published afterwards
+// by the outbox relay, so an event is never lost or sent for a change that
+// was rolled back. Payload is the JSON-encoded event body.
+type OutboxEvent struct {
+	ID          uint   `gorm:"primaryKey"`
+	EventID     string `gorm:"type:varchar(64);uniqueIndex;not null"`
+	Type        string `gorm:"type:varchar(100);not null"`
+	Key         string `gorm:"type:varchar(100);not null"`
+	Payload     string `gorm:"type:text;not null"`
+	Attempts    int    `gorm:"not null;default:0"`
+	LastError   string
+	CreatedAt   time.Time
+	PublishedAt *time.Time `gorm:"index"`
+}
@@ -0,0 +1,121 @@
+// Package outbox implements the transactional outbox: domain events are
+// stored alongside the change they describe and a relay publishes them once
+// the change has committed.
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"time"
+
+	"go-microservices/pkg/events"
+	"go-microservices/services/auth-service/internal/models"
+	"go-microservices/services/auth-service/internal/repository"
+	"go-microservices/services/auth-service/internal/utils"
+)
+
+const (
+	// batchSize is how many events the relay publishes per transaction.
+	batchSize = 100
+	// retention is how long published events are kept for inspection.
+	retention = 7 * 24 * time.Hour
+	// purgeInterval is how often published events past retention are
+	// deleted.
+	purgeInterval = time.Hour
+)
+
+// Record stores an event for publishing. Call it with the repository of
+// the transaction making the change, so the event commits or rolls back
+// with it.
+func Record(ctx context.Context, repo *repository.Repository, typ, key string, payload interface{}) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	return repo.AddOutboxEvent(ctx, &models.OutboxEvent{
+		EventID: utils.GenerateRandomToken(),
+		Type:    typ,
+		Key:     key,
+		Payload: string(data),
+	})
+}
+
+// Relay publishes stored events in the order they were recorded. Only one
+// relay across all replicas publishes at a time; the others wait their
+// turn. Events stay stored until published, so a broker outage delays
+// delivery rather than losing events.
+type Relay struct {
+	repo *repository.Repository
+	pub  events.Publisher
+}
+
+func NewRelay(repo *repository.Repository, pub events.Publisher) *Relay {
+	return &Relay{repo: repo, pub: pub}
+}
+
+// Run publishes pending events every interval until ctx is cancelled.
+func (r *Relay) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	var lastPurge time.Time
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			for {
+				n, err := r.Flush(ctx)
+				if err != nil {
+					log.Printf("failed to publish outbox events: %v", err)
+				}
+				if err != nil || n < batchSize {
+					break
+				}
+			}
+			if time.Since(lastPurge) >= purgeInterval {
+				if err := r.repo.PurgePublishedOutboxEvents(ctx, time.Now().Add(-retention)); err != nil {
+					log.Printf("failed to purge published outbox events: %v", err)
+				}
+				lastPurge = time.Now()
+			}
+		}
+	}
+}
+
+// Flush publishes one batch of pending events and returns how many were
+// published. It stops at the first event the publisher rejects, so later
+// events are not delivered ahead of it.
+func (r *Relay) Flush(ctx context.Context) (int, error) {
+	published := 0
+	err := r.repo.Transaction(ctx, func(tx *repository.Repository) error {
+		locked, err := tx.LockOutbox(ctx)
+		if err != nil || !locked {
+			return err
+		}
+		pending, err := tx.PendingOutboxEvents(ctx, batchSize)
+		if err != nil {
+			return err
+		}
+		for _, m := range pending {
+			e := events.Event{
+				ID:         m.EventID,
+				Type:       m.Type,
+				Key:        m.Key,
+				Seq:        uint64(m.ID),
+				OccurredAt: m.CreatedAt,
+				Payload:    json.RawMessage(m.Payload),
+			}
+			if err := r.pub.Publish(ctx, e); err != nil {
+				log.Printf("failed to publish event %s (%s): %v", m.EventID, m.Type, err)
+				return tx.RecordOutboxFailure(ctx, m.ID, err.Error())
+			}
+			if err := tx.MarkOutboxEventPublished(ctx, m.ID, time.Now()); err != nil {
+				return err
+			}
+			published++
+		}
+		return nil
+	})
+	return published, err
+}
@@ -0,0 +1,121 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"go-microservices/pkg/dbtest"
+	"go-microservices/pkg/events"
+	"go-microservices/services/auth-service/internal/database"
+	"go-microservices/services/auth-service/internal/models"
+	"go-microservices/services/auth-service/internal/repository"
+)
+
+func newTestRepo(t *testing.T) *repository.Repository {
+	t.Helper()
+	return repository.NewRepository(dbtest.Open(t, database.Migrate))
+}
+
+// recordRegistrations stores one registration event per user ID.
+func recordRegistrations(t *testing.T, repo *repository.Repository, userIDs ...string) {
+	t.Helper()
+	for _, id := range userIDs {
+		if err := Record(context.Background(), repo, events.TypeUserRegistered, id, events.UserRegistered{UserID: id}); err != nil {
+			t.Fatalf("Record: %v", err)
+		}
+	}
+}
+
+func flush(t *testing.T, relay *Relay) int {
+	t.Helper()
+	n, err := relay.Flush(context.Background())
+	if err != nil {
+		t.Fatalf("Flush: %v", err)
+	}
+	return n
+}
+
+func TestRelayPublishesInOrderOnce(t *testing.T) {
+	repo := newTestRepo(t)
+	bus := events.NewMemoryBus()
+	defer bus.Close()
+	var got []events.Event
+	stop, err := bus.Listen(func(ctx context.Context, e events.Event) error {
+		got = append(got, e)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Listen: %v", err)
+	}
+	defer stop()
+
+	recordRegistrations(t, repo, "1", "2", "3")
+	relay := NewRelay(repo, bus)
+	if n := flush(t, relay); n != 3 {
+		t.Fatalf("expected 3 events published, got %d", n)
+	}
+	if n := flush(t, relay); n != 0 {
+		t.Fatalf("expected nothing left to publish, got %d", n)
+	}
+
+	if len(got) != 3 {
+		t.Fatalf("expected 3 deliveries, got %d", len(got))
+	}
+	for i, e := range got {
+		var p events.UserRegistered
+		if err := e.Decode(&p); err != nil {
+			t.Fatalf("Decode: %v", err)
+		}
+		if want := []string{"1", "2", "3"}[i]; e.Key != want || p.UserID != want {
+			t.Errorf("delivery %d: got key %q user %q, want %q", i, e.Key, p.UserID, want)
+		}
+		if i > 0 && e.Seq <= got[i-1].Seq {
+			t.Errorf("delivery %d: seq %d does not follow %d", i, e.Seq, got[i-1].Seq)
+		}
+	}
+}
+
+func TestRelayRetriesFailedEventBeforeLaterOnes(t *testing.T) {
+	repo := newTestRepo(t)
+	bus := events.NewMemoryBus()
+	defer bus.Close()
+	var keys []string
+	failed := false
+	stop, err := bus.Listen(func(ctx context.Context, e events.Event) error {
+		keys = append(keys, e.Key)
+		if e.Key == "2" && !failed {
+			failed = true
+			return errors.New("broker unavailable")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Listen: %v", err)
+	}
+	defer stop()
+
+	recordRegistrations(t, repo, "1", "2", "3")
+	relay := NewRelay(repo, bus)
+	if n := flush(t, relay); n != 1 {
+		t.Fatalf("expected the batch to stop at the failed event, got %d published", n)
+	}
+	var stored models.OutboxEvent
+	repo.DB.Where("key = ?", "2").First(&stored)
+	if stored.Attempts != 1 || stored.PublishedAt != nil {
+		t.Fatalf("expected the failure to be recorded, got %+v", stored)
+	}
+
+	if n := flush(t, relay); n != 2 {
+		t.Fatalf("expected the remaining 2 events published, got %d", n)
+	}
+	want := []string{"1", "2", "2", "3"}
+	if len(keys) != len(want) {
+		t.Fatalf("got deliveries %v, want %v", keys, want)
+	}
+	for i := range want {
+		if keys[i] != want[i] {
+			t.Fatalf("got deliveries %v, want %v", keys, want)
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package password
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// RangeSource lists breached passwords the way the Pwned Passwords
+// k-anonymity API does: given the first five hex digits of a SHA-1 hash, it
+// returns the remaining 35 digits of every listed hash with that prefix.
+type RangeSource interface {
+	Range(ctx context.Context, prefix string) ([]string, error)
+}
+
+// Breached reports whether src lists password. Only the hash prefix is
+// passed to src, so a remote source never learns which password was checked.
+func Breached(ctx context.Context, src RangeSource, password string) (bool, error) {
+	sum := sha1.Sum([]byte(password))
+	hash := strings.ToUpper(hex.EncodeToString(sum[:]))
+	suffixes, err := src.Range(ctx, hash[:5])
+	if err != nil {
+		return false, err
+	}
+	for _, s := range suffixes {
+		if strings.EqualFold(s, hash[5:]) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// HashFile is a RangeSource held in memory, loaded from a file of SHA-1
+// hashes.
+type HashFile struct {
+	ranges map[string][]string
+	size   int
+}
+
+// LoadHashFile reads a breached-password list with one hex SHA-1 hash per
+// line, optionally followed by ":count" as in the Pwned Passwords downloads.
+// Blank lines and lines starting with # are ignored.
+func LoadHashFile(path string) (*HashFile, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	h := &HashFile{ranges: make(map[string][]string)}
+	sc := bufio.NewScanner(f)
+	for n := 1; sc.Scan(); n++ {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		hash, _, _ := strings.Cut(line, ":")
+		hash = strings.ToUpper(hash)
+		if _, err := hex.DecodeString(hash); err != nil || len(hash) != 2*sha1.Size {
+			return nil, fmt.Errorf("%s:%d: not a SHA-1 hash", path, n)
+		}
+		h.ranges[hash[:5]] = append(h.ranges[hash[:5]], hash[5:])
+		h.size++
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	return h, nil
+}
+
+// Len returns the number of hashes loaded.
+func (h *HashFile) Len() int {
+	return h.size
+}
+
+func (h *HashFile) Range(ctx context.Context, prefix string) ([]string, error) {
+	return h.ranges[strings.ToUpper(prefix)], nil
+}
@@ -0,0 +1,155 @@
+package password
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Hashing algorithms accepted in configuration.
+const (
+	AlgArgon2id = "argon2id"
+	AlgBcrypt   = "bcrypt"
+)
+
+// ErrUnknownHash is returned when no configured scheme recognizes a stored
+// hash.
+var ErrUnknownHash = errors.New("unrecognized password hash format")
+
+// Scheme is one password hashing algorithm with its current parameters.
+type Scheme interface {
+	// Hash returns a self-describing hash of password.
+	Hash(password string) (string, error)
+	// Recognizes reports whether hash was produced by this algorithm.
+	Recognizes(hash string) bool
+	// Verify reports whether password matches hash, and whether hash was
+	// produced with the scheme's current parameters.
+	Verify(password, hash string) (match, current bool, err error)
+}
+
+// Hasher hashes new passwords with a preferred scheme and verifies hashes
+// of any scheme it knows.
+type Hasher struct {
+	preferred Scheme
+	legacy    []Scheme
+}
+
+// NewHasher returns a Hasher that hashes with preferred and still verifies
+// hashes made by the legacy schemes.
+func NewHasher(preferred Scheme, legacy ...Scheme) *Hasher {
+	return &Hasher{preferred: preferred, legacy: legacy}
+}
+
+func (h *Hasher) Hash(password string) (string, error) {
+	return h.preferred.Hash(password)
+}
+
+// Verify reports whether password matches hash. rehash is set when the
+// password matched but hash used another algorithm or weaker parameters
+// than the preferred scheme, so the caller should store a fresh Hash.
+func (h *Hasher) Verify(password, hash string) (match, rehash bool, err error) {
+	if h.preferred.Recognizes(hash) {
+		match, current, err := h.preferred.Verify(password, hash)
+		return match, match && !current, err
+	}
+	for _, s := range h.legacy {
+		if s.Recognizes(hash) {
+			match, _, err := s.Verify(password, hash)
+			return match, match, err
+		}
+	}
+	return false, false, ErrUnknownHash
+}
+
+// Argon2id hashes with Argon2id (RFC 9106) into PHC strings such as
+// $argon2id$v=19$m=65536,t=3,p=4$<salt>$<hash>.
+type Argon2id struct {
+	// Memory is in KiB.
+	Memory      uint32
+	Iterations  uint32
+	Parallelism uint8
+	SaltLen     int
+	KeyLen      uint32
+}
+
+// DefaultArgon2id follows the second recommended option of RFC 9106.
+var DefaultArgon2id = Argon2id{Memory: 64 * 1024, Iterations: 3, Parallelism: 4, SaltLen: 16, KeyLen: 32}
+
+var phcEncoding = base64.RawStdEncoding
+
+func (a Argon2id) Hash(password string) (string, error) {
+	salt := make([]byte, a.SaltLen)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	key := argon2.IDKey([]byte(password), salt, a.Iterations, a.Memory, a.Parallelism, a.KeyLen)
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version, a.Memory, a.Iterations, a.Parallelism,
+		phcEncoding.EncodeToString(salt), phcEncoding.EncodeToString(key)), nil
+}
+
+func (a Argon2id) Recognizes(hash string) bool {
+	return strings.HasPrefix(hash, "$argon2id$")
+}
+
+func (a Argon2id) Verify(password, hash string) (bool, bool, error) {
+	// "", "argon2id", "v=19", "m=...,t=...,p=...", salt, key
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 {
+		return false, false, ErrUnknownHash
+	}
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return false, false, fmt.Errorf("unsupported argon2 version %q", parts[2])
+	}
+	var stored Argon2id
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &stored.Memory, &stored.Iterations, &stored.Parallelism); err != nil {
+		return false, false, fmt.Errorf("invalid argon2 parameters %q", parts[3])
+	}
+	salt, err := phcEncoding.DecodeString(parts[4])
+	if err != nil {
+		return false, false, fmt.Errorf("invalid argon2 salt: %w", err)
+	}
+	want, err := phcEncoding.DecodeString(parts[5])
+	if err != nil {
+		return false, false, fmt.Errorf("invalid argon2 hash: %w", err)
+	}
+	stored.SaltLen, stored.KeyLen = len(salt), uint32(len(want))
+
+	got := argon2.IDKey([]byte(password), salt, stored.Iterations, stored.Memory, stored.Parallelism, stored.KeyLen)
+	match := subtle.ConstantTimeCompare(got, want) == 1
+	return match, stored == a, nil
+}
+
+// Bcrypt hashes with bcrypt. Its hashes use bcrypt's own $2a$ format, which
+// records the cost.
+type Bcrypt struct {
+	Cost int
+}
+
+func (b Bcrypt) Hash(password string) (string, error) {
+	hashed, err := bcrypt.GenerateFromPassword([]byte(password), b.Cost)
+	return string(hashed), err
+}
+
+func (b Bcrypt) Recognizes(hash string) bool {
+	return strings.HasPrefix(hash, "$2a$") || strings.HasPrefix(hash, "$2b$") || strings.HasPrefix(hash, "$2y$")
+}
+
+func (b Bcrypt) Verify(password, hash string) (bool, bool, error) {
+	err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
+	if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+		return false, false, nil
+	}
+	if err != nil {
+		return false, false, err
+	}
+	cost, err := bcrypt.Cost([]byte(hash))
+	return true, err == nil && cost >= b.Cost, nil
+}
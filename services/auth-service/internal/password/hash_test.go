@@ -0,0 +1,103 @@
+package password
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// fastArgon2id keeps the tests quick; the parameters are far too weak for
+// real use.
+var fastArgon2id = Argon2id{Memory: 64, Iterations: 1, Parallelism: 1, SaltLen: 16, KeyLen: 32}
+
+func mustHash(t *testing.T, s Scheme, pw string) string {
+	t.Helper()
+	hash, err := s.Hash(pw)
+	if err != nil {
+		t.Fatalf("Hash: %v", err)
+	}
+	return hash
+}
+
+// withPart replaces the i-th $-separated field of a PHC string.
+func withPart(hash string, i int, part string) string {
+	parts := strings.Split(hash, "$")
+	parts[i] = part
+	return strings.Join(parts, "$")
+}
+
+func TestArgon2idVerify(t *testing.T) {
+	const pw = "correct horse battery staple"
+	hash := mustHash(t, fastArgon2id, pw)
+	stronger := fastArgon2id
+	stronger.Iterations = 2
+
+	tests := []struct {
+		name           string
+		scheme         Argon2id
+		password, hash string
+		match, current bool
+		wantErr        bool
+	}{
+		{name: "match", scheme: fastArgon2id, password: pw, hash: hash, match: true, current: true},
+		{name: "mismatch", scheme: fastArgon2id, password: "wrong", hash: hash, current: true},
+		{name: "weaker parameters", scheme: stronger, password: pw, hash: hash, match: true},
+		{name: "missing field", scheme: fastArgon2id, password: pw, hash: hash[:strings.LastIndex(hash, "$")], wantErr: true},
+		{name: "unsupported version", scheme: fastArgon2id, password: pw, hash: withPart(hash, 2, "v=16"), wantErr: true},
+		{name: "bad parameters", scheme: fastArgon2id, password: pw, hash: withPart(hash, 3, "m=64,t=one,p=1"), wantErr: true},
+		{name: "bad salt", scheme: fastArgon2id, password: pw, hash: withPart(hash, 4, "not base64!"), wantErr: true},
+		{name: "bad key", scheme: fastArgon2id, password: pw, hash: withPart(hash, 5, "not base64!"), wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			match, current, err := tt.scheme.Verify(tt.password, tt.hash)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Verify error = %v, want error %v", err, tt.wantErr)
+			}
+			if match != tt.match || current != tt.current {
+				t.Fatalf("Verify = match %v current %v, want %v %v", match, current, tt.match, tt.current)
+			}
+		})
+	}
+}
+
+func TestHasherVerify(t *testing.T) {
+	const pw = "correct horse battery staple"
+	legacy := Bcrypt{Cost: bcrypt.MinCost}
+	argonHash := mustHash(t, fastArgon2id, pw)
+	bcryptHash := mustHash(t, legacy, pw)
+	weaker := fastArgon2id
+	weaker.Memory = 32
+	weakerHash := mustHash(t, weaker, pw)
+
+	tests := []struct {
+		name           string
+		hasher         *Hasher
+		password, hash string
+		match, rehash  bool
+		err            error
+	}{
+		{name: "current hash", hasher: NewHasher(fastArgon2id, legacy), password: pw, hash: argonHash, match: true},
+		{name: "wrong password", hasher: NewHasher(fastArgon2id, legacy), password: "wrong", hash: argonHash},
+		{name: "weaker parameters", hasher: NewHasher(fastArgon2id, legacy), password: pw, hash: weakerHash, match: true, rehash: true},
+		{name: "legacy bcrypt", hasher: NewHasher(fastArgon2id, legacy), password: pw, hash: bcryptHash, match: true, rehash: true},
+		{name: "legacy bcrypt wrong password", hasher: NewHasher(fastArgon2id, legacy), password: "wrong", hash: bcryptHash},
+		{name: "bcrypt at current cost", hasher: NewHasher(legacy), password: pw, hash: bcryptHash, match: true},
+		{name: "bcrypt below current cost", hasher: NewHasher(Bcrypt{Cost: bcrypt.MinCost + 1}), password: pw, hash: bcryptHash, match: true, rehash: true},
+		{name: "unknown scheme", hasher: NewHasher(fastArgon2id), password: pw, hash: bcryptHash, err: ErrUnknownHash},
+		{name: "not a hash", hasher: NewHasher(fastArgon2id, legacy), password: pw, hash: pw, err: ErrUnknownHash},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			match, rehash, err := tt.hasher.Verify(tt.password, tt.hash)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("Verify error = %v, want %v", err, tt.err)
+			}
+			if match != tt.match || rehash != tt.rehash {
+				t.Fatalf("Verify = match %v rehash %v, want %v %v", match, rehash, tt.match, tt.rehash)
+			}
+		})
+	}
+}
@@ -0,0 +1,117 @@
+// Package password enforces the password policy and screens passwords
+// against lists of known breached passwords.
+package password
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Reasons identify the rule a password failed, for clients to act on.
+const (
+	ReasonTooShort     = "PASSWORD_TOO_SHORT"
+	ReasonTooLong      = "PASSWORD_TOO_LONG"
+	ReasonTooSimple    = "PASSWORD_TOO_SIMPLE"
+	ReasonPersonalInfo = "PASSWORD_CONTAINS_PERSONAL_INFO"
+	ReasonBreached     = "PASSWORD_BREACHED"
+)
+
+// minPersonalLen is the shortest username or email part a password is
+// checked against; shorter ones would match by coincidence.
+const minPersonalLen = 3
+
+// Violation is a policy rule a password failed.
+type Violation struct {
+	Reason      string
+	Description string
+}
+
+// Policy describes acceptable passwords. Zero fields disable their rule.
+type Policy struct {
+	// MinLength is the minimum number of characters.
+	MinLength int
+	// MaxBytes is the maximum length in bytes. bcrypt only uses the first
+	// 72, so longer passwords would silently be truncated; with argon2id it
+	// just bounds the cost of hashing.
+	MaxBytes int
+	// MinClasses is how many of lowercase letters, uppercase letters,
+	// digits and symbols a password must mix.
+	MinClasses int
+	// Breached, when set, rejects passwords it lists.
+	Breached RangeSource
+}
+
+// Check returns every rule password fails. personal lists the username and
+// email address, which the password must not contain. An error means the
+// breached-password source could not be consulted.
+func (p Policy) Check(ctx context.Context, password string, personal ...string) ([]Violation, error) {
+	var violations []Violation
+	if p.MinLength > 0 && utf8.RuneCountInString(password) < p.MinLength {
+		violations = append(violations, Violation{ReasonTooShort, fmt.Sprintf("must be at least %d characters", p.MinLength)})
+	}
+	if p.MaxBytes > 0 && len(password) > p.MaxBytes {
+		violations = append(violations, Violation{ReasonTooLong, fmt.Sprintf("must be at most %d bytes", p.MaxBytes)})
+	}
+	if p.MinClasses > 1 && classes(password) < p.MinClasses {
+		violations = append(violations, Violation{ReasonTooSimple, fmt.Sprintf("must mix at least %d of lowercase letters, uppercase letters, digits and symbols", p.MinClasses)})
+	}
+	if containsPersonal(password, personal) {
+		violations = append(violations, Violation{ReasonPersonalInfo, "must not contain your username or email address"})
+	}
+	if p.Breached != nil && password != "" {
+		breached, err := Breached(ctx, p.Breached, password)
+		if err != nil {
+			return nil, err
+		}
+		if breached {
+			violations = append(violations, Violation{ReasonBreached, "appears in a known data breach"})
+		}
+	}
+	return violations, nil
+}
+
+// classes counts the character classes used in s.
+func classes(s string) int {
+	var lower, upper, digit, symbol bool
+	for _, r := range s {
+		switch {
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsDigit(r):
+			digit = true
+		default:
+			symbol = true
+		}
+	}
+	n := 0
+	for _, used := range []bool{lower, upper, digit, symbol} {
+		if used {
+			n++
+		}
+	}
+	return n
+}
+
+// containsPersonal reports whether password contains any of the personal
+// values, or the local part of an email address among them, ignoring case.
+func containsPersonal(password string, personal []string) bool {
+	password = strings.ToLower(password)
+	for _, v := range personal {
+		v = strings.ToLower(strings.TrimSpace(v))
+		candidates := []string{v}
+		if local, _, ok := strings.Cut(v, "@"); ok {
+			candidates = append(candidates, local)
+		}
+		for _, c := range candidates {
+			if len(c) >= minPersonalLen && strings.Contains(password, c) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,159 @@
+// Package rbac defines the built-in roles and permissions and resolves the
+// effective permissions of an account.
+package rbac
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"go-microservices/services/auth-service/internal/models"
+	"go-microservices/services/auth-service/internal/repository"
+)
+
+// Built-in roles. Each one inherits every permission of the role before it.
+const (
+	RoleUser      = "user"
+	RoleModerator = "moderator"
+	RoleAdmin     = "admin"
+)
+
+// Built-in permissions checked by the gateway.
+const (
+	PermUsersRead        = "users:read"
+	PermUsersWrite       = "users:write"
+	PermPostsWrite       = "posts:write"
+	PermPostsDelete      = "posts:delete"
+	PermRolesManage      = "roles:manage"
+	PermAccountsUnlock   = "accounts:unlock"
+	PermClientsManage    = "clients:manage"
+	PermSessionsManage   = "sessions:manage"
+	PermUsersImpersonate = "users:impersonate"
+)
+
+var defaultRoles = []models.Role{
+	{
+		Name:        RoleUser,
+		Description: "Signed-up account",
+		Permissions: []models.Permission{
+			{Name: PermUsersRead, Description: "View user profiles"},
+			{Name: PermPostsWrite, Description: "Create posts and edit or delete your own"},
+		},
+	},
+	{
+		Name:        RoleModerator,
+		Description: "Moderates user content",
+		Parent:      RoleUser,
+		Permissions: []models.Permission{
+			{Name: PermPostsDelete, Description: "Delete other users' posts"},
+		},
+	},
+	{
+		Name:        RoleAdmin,
+		Description: "Full administrative access",
+		Parent:      RoleModerator,
+		RequireMFA:  true,
+		Permissions: []models.Permission{
+			{Name: PermUsersWrite, Description: "Create, edit and delete user profiles"},
+			{Name: PermRolesManage, Description: "Assign and revoke roles"},
+			{Name: PermAccountsUnlock, Description: "Unlock accounts locked after failed sign-ins"},
+			{Name: PermClientsManage, Description: "Register and delete OAuth clients"},
+			{Name: PermSessionsManage, Description: "List and terminate other users' sessions"},
+			{Name: PermUsersImpersonate, Description: "Sign in as another user for support"},
+		},
+	},
+}
+
+// Seed creates the built-in roles and permissions that are missing and moves
+// roles stored on accounts by earlier versions into the role table. When
+// adminEmail names an existing account it is made an admin, which is how the
+// first administrator is bootstrapped.
+func Seed(ctx context.Context, repo *repository.Repository, adminEmail string) error {
+	for i := range defaultRoles {
+		role := defaultRoles[i]
+		if err := repo.EnsureRole(ctx, &role); err != nil {
+			return fmt.Errorf("seed role %s: %w", role.Name, err)
+		}
+	}
+	if err := repo.MigrateLegacyRoles(ctx); err != nil {
+		return fmt.Errorf("migrate legacy roles: %w", err)
+	}
+	if adminEmail == "" {
+		return nil
+	}
+	auth, err := repo.GetAuthByEmail(ctx, adminEmail)
+	if err != nil {
+		// The account may not have signed up yet; SignUp grants the role then.
+		return nil
+	}
+	if _, err := repo.AssignRole(ctx, auth.ID, RoleAdmin); err != nil {
+		return fmt.Errorf("bootstrap admin: %w", err)
+	}
+	return nil
+}
+
+// Normalize returns the canonical form of a role name. Role names are
+// case-insensitive.
+func Normalize(name string) string {
+	return strings.ToLower(strings.TrimSpace(name))
+}
+
+// Effective returns the sorted permissions granted by the named roles,
+// including those inherited from parent roles.
+func Effective(roles []models.Role, names []string) []string {
+	byName := make(map[string]models.Role, len(roles))
+	for _, r := range roles {
+		byName[r.Name] = r
+	}
+
+	perms := map[string]struct{}{}
+	seen := map[string]bool{}
+	for _, name := range names {
+		// Walk up the hierarchy; seen guards against misconfigured cycles.
+		for name != "" && !seen[name] {
+			seen[name] = true
+			r, ok := byName[name]
+			if !ok {
+				break
+			}
+			for _, p := range r.Permissions {
+				perms[p.Name] = struct{}{}
+			}
+			name = r.Parent
+		}
+	}
+
+	out := make([]string, 0, len(perms))
+	for p := range perms {
+		out = append(out, p)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// Access returns the roles active for a session of the account and the
+// permissions they grant. Roles that require MFA are left out unless mfa is
+// set, so such a session keeps only the permissions of its other roles.
+func Access(ctx context.Context, repo *repository.Repository, authID uint, mfa bool) (roles, perms []string, err error) {
+	held, err := repo.ListUserRoles(ctx, authID)
+	if err != nil {
+		return nil, nil, err
+	}
+	defined, err := repo.ListRoles(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	requireMFA := make(map[string]bool, len(defined))
+	for _, r := range defined {
+		requireMFA[r.Name] = r.RequireMFA
+	}
+	roles = make([]string, 0, len(held))
+	for _, name := range held {
+		if mfa || !requireMFA[name] {
+			roles = append(roles, name)
+		}
+	}
+	return roles, Effective(defined, roles), nil
+}
@@ -1,11 +1,20 @@
 package repository
 
 import (
+	"context"
+	"errors"
+	"time"
+
 	"go-microservices/services/auth-service/internal/models"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
+// ErrDuplicate is returned when a write would violate a unique constraint,
+// such as a second account with the same email or username.
+var ErrDuplicate = gorm.ErrDuplicatedKey
+
 type Repository struct {
 	DB *gorm.DB
 }
@@ -14,34 +23,731 @@ func NewRepository(db *gorm.DB) *Repository {
 	return &Repository{DB: db}
 }
 
-func (r *Repository) CreateAuth(a *models.Auth) error {
-	return r.DB.Create(a).Error
+// Transaction runs fn with a repository whose queries all belong to one
+// database transaction, committed if fn returns nil and rolled back
+// otherwise.
+func (r *Repository) Transaction(ctx context.Context, fn func(tx *Repository) error) error {
+	return r.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return fn(&Repository{DB: tx})
+	})
+}
+
+func (r *Repository) CreateAuth(ctx context.Context, a *models.Auth) error {
+	return r.DB.WithContext(ctx).Create(a).Error
+}
+
+func (r *Repository) GetAuthByEmail(ctx context.Context, email string) (*models.Auth, error) {
+	var a models.Auth
+	if err := r.DB.WithContext(ctx).Where("email = ?", email).First(&a).Error; err != nil {
+		return nil, err
+	}
+	return &a, nil
+}
+
+func (r *Repository) GetAuthByID(ctx context.Context, id uint) (*models.Auth, error) {
+	var a models.Auth
+	if err := r.DB.WithContext(ctx).First(&a, id).Error; err != nil {
+		return nil, err
+	}
+	return &a, nil
+}
+
+func (r *Repository) GetAuthByVerificationToken(ctx context.Context, hash string) (*models.Auth, error) {
+	var a models.Auth
+	if err := r.DB.WithContext(ctx).Where("verification_token = ?", hash).First(&a).Error; err != nil {
+		return nil, err
+	}
+	return &a, nil
 }
 
-func (r *Repository) GetAuthByEmail(email string) (*models.Auth, error) {
+func (r *Repository) GetAuthByResetToken(ctx context.Context, hash string) (*models.Auth, error) {
 	var a models.Auth
-	if err := r.DB.Where("email = ?", email).First(&a).Error; err != nil {
+	if err := r.DB.WithContext(ctx).Where("reset_token = ?", hash).First(&a).Error; err != nil {
 		return nil, err
 	}
 	return &a, nil
 }
 
-func (r *Repository) GetAuthByID(id uint) (*models.Auth, error) {
+func (r *Repository) GetAuthByEmailChangeToken(ctx context.Context, hash string) (*models.Auth, error) {
 	var a models.Auth
-	if err := r.DB.First(&a, id).Error; err != nil {
+	if err := r.DB.WithContext(ctx).Where("email_change_token = ?", hash).First(&a).Error; err != nil {
 		return nil, err
 	}
 	return &a, nil
 }
 
-func (r *Repository) CreateTest(t *models.Test) error {
-	return r.DB.Create(t).Error
+// EmailInUse reports whether an account has email as its address.
+func (r *Repository) EmailInUse(ctx context.Context, email string) (bool, error) {
+	var n int64
+	err := r.DB.WithContext(ctx).Model(&models.Auth{}).Where("email = ?", email).Count(&n).Error
+	return n > 0, err
+}
+
+// UsernameInUse reports whether an account has the username.
+func (r *Repository) UsernameInUse(ctx context.Context, username string) (bool, error) {
+	var n int64
+	err := r.DB.WithContext(ctx).Model(&models.Auth{}).Where("username = ?", username).Count(&n).Error
+	return n > 0, err
+}
+
+// ConfirmEmailChange makes the pending email the account's confirmed
+// address if the change token still matches hash. It reports false when
+// another request consumed the token first.
+func (r *Repository) ConfirmEmailChange(ctx context.Context, authID uint, hash string) (bool, error) {
+	res := r.DB.WithContext(ctx).Model(&models.Auth{}).
+		Where("id = ? AND email_change_token = ?", authID, hash).
+		Updates(map[string]interface{}{
+			"email":                     gorm.Expr("pending_email"),
+			"email_verified":            true,
+			"pending_email":             "",
+			"email_change_token":        "",
+			"email_change_token_expiry": time.Time{},
+		})
+	if res.Error != nil {
+		return false, res.Error
+	}
+	return res.RowsAffected == 1, nil
+}
+
+// ConsumeVerificationToken marks the account's email confirmed if the
+// verification token still matches hash. It reports false when another
+// request consumed the token first.
+func (r *Repository) ConsumeVerificationToken(ctx context.Context, authID uint, hash string) (bool, error) {
+	res := r.DB.WithContext(ctx).Model(&models.Auth{}).
+		Where("id = ? AND verification_token = ?", authID, hash).
+		Updates(map[string]interface{}{
+			"email_verified":            true,
+			"verification_token":        "",
+			"verification_token_expiry": time.Time{},
+		})
+	if res.Error != nil {
+		return false, res.Error
+	}
+	return res.RowsAffected == 1, nil
+}
+
+// ConsumeResetToken clears the reset token if it still matches hash. It
+// reports false when another request consumed the token first.
+func (r *Repository) ConsumeResetToken(ctx context.Context, authID uint, hash string) (bool, error) {
+	res := r.DB.WithContext(ctx).Model(&models.Auth{}).
+		Where("id = ? AND reset_token = ?", authID, hash).
+		Updates(map[string]interface{}{"reset_token": "", "reset_token_expiry": time.Time{}})
+	if res.Error != nil {
+		return false, res.Error
+	}
+	return res.RowsAffected == 1, nil
+}
+
+// ReplacePasswordHash swaps the stored password hash for hash if it is still
+// old, so a password changed in the meantime is not overwritten. It reports
+// false when the stored hash had already changed.
+func (r *Repository) ReplacePasswordHash(ctx context.Context, authID uint, old, hash string) (bool, error) {
+	res := r.DB.WithContext(ctx).Model(&models.Auth{}).
+		Where("id = ? AND password = ?", authID, old).
+		Update("password", hash)
+	if res.Error != nil {
+		return false, res.Error
+	}
+	return res.RowsAffected == 1, nil
+}
+
+func (r *Repository) SaveAuth(ctx context.Context, a *models.Auth) error {
+	return r.DB.WithContext(ctx).Save(a).Error
+}
+
+// DeleteAuth permanently deletes the account and everything that lets it
+// sign in, freeing its email and username. Audit logs and token cutoffs are
+// kept: the former are history, the latter keep issued tokens rejected.
+func (r *Repository) DeleteAuth(ctx context.Context, authID uint) error {
+	return r.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		for _, m := range []interface{}{
+			&models.Session{}, &models.RefreshToken{}, &models.APIKey{}, &models.RecoveryCode{},
+			&models.MFAChallenge{}, &models.MagicLink{}, &models.AuthorizationCode{}, &models.UserRole{},
+		} {
+			if err := tx.Unscoped().Where("auth_id = ?", authID).Delete(m).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Unscoped().Delete(&models.Auth{}, authID).Error
+	})
+}
+
+func (r *Repository) CreateRefreshToken(ctx context.Context, t *models.RefreshToken) error {
+	return r.DB.WithContext(ctx).Create(t).Error
+}
+
+func (r *Repository) GetRefreshTokenByHash(ctx context.Context, hash string) (*models.RefreshToken, error) {
+	var t models.RefreshToken
+	if err := r.DB.WithContext(ctx).Where("token_hash = ?", hash).First(&t).Error; err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// MarkRefreshTokenUsed flags the token as exchanged. It reports false when the
+// token had already been used or revoked, which callers must treat as reuse.
+func (r *Repository) MarkRefreshTokenUsed(ctx context.Context, id uint) (bool, error) {
+	res := r.DB.WithContext(ctx).Model(&models.RefreshToken{}).
+		Where("id = ? AND used_at IS NULL AND revoked_at IS NULL", id).
+		Update("used_at", time.Now())
+	if res.Error != nil {
+		return false, res.Error
+	}
+	return res.RowsAffected == 1, nil
+}
+
+// RevokeTokenFamily revokes every refresh token descended from the same
+// sign-in and ends its session.
+func (r *Repository) RevokeTokenFamily(ctx context.Context, familyID string) error {
+	now := time.Now()
+	return r.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.RefreshToken{}).
+			Where("family_id = ? AND revoked_at IS NULL", familyID).
+			Update("revoked_at", now).Error; err != nil {
+			return err
+		}
+		return tx.Model(&models.Session{}).
+			Where("id = ? AND revoked_at IS NULL", familyID).
+			Update("revoked_at", now).Error
+	})
+}
+
+// RevokeTokenFamiliesForAuth revokes every outstanding refresh token of an
+// account and ends all of its sessions.
+func (r *Repository) RevokeTokenFamiliesForAuth(ctx context.Context, authID uint) error {
+	now := time.Now()
+	return r.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.RefreshToken{}).
+			Where("auth_id = ? AND revoked_at IS NULL", authID).
+			Update("revoked_at", now).Error; err != nil {
+			return err
+		}
+		return tx.Model(&models.Session{}).
+			Where("auth_id = ? AND revoked_at IS NULL", authID).
+			Update("revoked_at", now).Error
+	})
+}
+
+func (r *Repository) CreateSession(ctx context.Context, s *models.Session) error {
+	return r.DB.WithContext(ctx).Create(s).Error
+}
+
+// ListActiveSessions returns the account's sessions that have neither ended
+// nor expired, most recently seen first.
+func (r *Repository) ListActiveSessions(ctx context.Context, authID uint) ([]models.Session, error) {
+	var sessions []models.Session
+	err := r.DB.WithContext(ctx).
+		Where("auth_id = ? AND revoked_at IS NULL AND expires_at > ?", authID, time.Now()).
+		Order("last_seen_at desc").Find(&sessions).Error
+	if err != nil {
+		return nil, err
+	}
+	return sessions, nil
+}
+
+// IsSessionActive reports whether the session has neither ended nor expired.
+func (r *Repository) IsSessionActive(ctx context.Context, id string) (bool, error) {
+	var n int64
+	err := r.DB.WithContext(ctx).Model(&models.Session{}).
+		Where("id = ? AND revoked_at IS NULL AND expires_at > ?", id, time.Now()).
+		Count(&n).Error
+	return n > 0, err
+}
+
+// TouchSession records a refresh of the session from ip at t, extending it
+// until expiresAt.
+func (r *Repository) TouchSession(ctx context.Context, id, ip string, t, expiresAt time.Time) error {
+	updates := map[string]interface{}{"last_seen_at": t, "expires_at": expiresAt}
+	if ip != "" {
+		updates["ip"] = ip
+	}
+	return r.DB.WithContext(ctx).Model(&models.Session{}).Where("id = ?", id).Updates(updates).Error
+}
+
+// EndSession revokes one of the account's sessions and its refresh tokens.
+// It reports false when the account has no such active session.
+func (r *Repository) EndSession(ctx context.Context, authID uint, id string) (bool, error) {
+	var ended bool
+	err := r.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		res := tx.Model(&models.Session{}).
+			Where("id = ? AND auth_id = ? AND revoked_at IS NULL", id, authID).
+			Update("revoked_at", now)
+		if res.Error != nil || res.RowsAffected == 0 {
+			return res.Error
+		}
+		ended = true
+		return tx.Model(&models.RefreshToken{}).
+			Where("family_id = ? AND revoked_at IS NULL", id).
+			Update("revoked_at", now).Error
+	})
+	return ended, err
+}
+
+func (r *Repository) CreateAuditLog(ctx context.Context, l *models.AuditLog) error {
+	return r.DB.WithContext(ctx).Create(l).Error
+}
+
+func (r *Repository) RevokeToken(ctx context.Context, t *models.RevokedToken) error {
+	return r.DB.WithContext(ctx).Clauses(clause.OnConflict{DoNothing: true}).Create(t).Error
+}
+
+func (r *Repository) IsTokenRevoked(ctx context.Context, jti string) (bool, error) {
+	var count int64
+	if err := r.DB.WithContext(ctx).Model(&models.RevokedToken{}).Where("jti = ?", jti).Count(&count).Error; err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+func (r *Repository) SetTokenCutoff(ctx context.Context, c *models.TokenCutoff) error {
+	return r.DB.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "auth_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"revoked_before", "updated_at"}),
+	}).Create(c).Error
+}
+
+// GetTokenCutoff returns the account's cutoff, or nil if it never revoked all sessions.
+func (r *Repository) GetTokenCutoff(ctx context.Context, authID uint) (*models.TokenCutoff, error) {
+	var c models.TokenCutoff
+	err := r.DB.WithContext(ctx).Where("auth_id = ?", authID).First(&c).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// PurgeExpiredRevocations deletes revocation entries for tokens that have expired anyway.
+func (r *Repository) PurgeExpiredRevocations(ctx context.Context) error {
+	return r.DB.WithContext(ctx).Where("expires_at < ?", time.Now()).Delete(&models.RevokedToken{}).Error
+}
+
+// ListSigningKeys returns the JWT signing keys that have not retired yet, newest first.
+func (r *Repository) ListSigningKeys(ctx context.Context) ([]models.SigningKey, error) {
+	var keys []models.SigningKey
+	if err := r.DB.WithContext(ctx).Where("retires_at > ?", time.Now()).Order("created_at desc").Find(&keys).Error; err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+func (r *Repository) CreateSigningKey(ctx context.Context, k *models.SigningKey) error {
+	return r.DB.WithContext(ctx).Create(k).Error
+}
+
+// DeleteRetiredSigningKeys removes keys that can no longer verify any live token.
+func (r *Repository) DeleteRetiredSigningKeys(ctx context.Context) error {
+	return r.DB.WithContext(ctx).Where("retires_at <= ?", time.Now()).Delete(&models.SigningKey{}).Error
+}
+
+// RecordLoginFailure counts a failed sign-in for the account and returns the
+// number of failures since windowStart, including this one.
+func (r *Repository) RecordLoginFailure(ctx context.Context, authID uint, windowStart time.Time) (int, error) {
+	var a models.Auth
+	err := r.DB.WithContext(ctx).Model(&a).
+		Clauses(clause.Returning{Columns: []clause.Column{{Name: "failed_login_count"}}}).
+		Where("id = ?", authID).
+		Updates(map[string]interface{}{
+			"failed_login_count":   gorm.Expr("CASE WHEN last_failed_login_at IS NULL OR last_failed_login_at < ? THEN 1 ELSE failed_login_count + 1 END", windowStart),
+			"last_failed_login_at": time.Now(),
+		}).Error
+	return a.FailedLoginCount, err
+}
+
+// LockAuth locks the account until the given time and clears its failure
+// count. It reports false when the account was already locked, so callers
+// notify the owner only once.
+func (r *Repository) LockAuth(ctx context.Context, authID uint, until time.Time) (bool, error) {
+	res := r.DB.WithContext(ctx).Model(&models.Auth{}).
+		Where("id = ? AND (locked_until IS NULL OR locked_until < ?)", authID, time.Now()).
+		Updates(map[string]interface{}{"locked_until": until, "failed_login_count": 0})
+	if res.Error != nil {
+		return false, res.Error
+	}
+	return res.RowsAffected == 1, nil
+}
+
+// ResetLoginFailures unlocks the account and forgets its failed sign-ins.
+func (r *Repository) ResetLoginFailures(ctx context.Context, authID uint) error {
+	return r.DB.WithContext(ctx).Model(&models.Auth{}).Where("id = ?", authID).
+		Updates(map[string]interface{}{"failed_login_count": 0, "last_failed_login_at": nil, "locked_until": nil}).Error
+}
+
+// GetLoginIPFailure returns the failure record of ip, or nil if it has none.
+func (r *Repository) GetLoginIPFailure(ctx context.Context, ip string) (*models.LoginIPFailure, error) {
+	var f models.LoginIPFailure
+	err := r.DB.WithContext(ctx).Where("ip = ?", ip).First(&f).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &f, nil
 }
 
-func (r *Repository) ListTests() ([]models.Test, error) {
+// RecordLoginIPFailure counts a failed sign-in from ip and returns the number
+// of failures since windowStart, including this one.
+func (r *Repository) RecordLoginIPFailure(ctx context.Context, ip string, windowStart time.Time) (int, error) {
+	var failures int
+	err := r.DB.WithContext(ctx).Raw(`INSERT INTO login_ip_failures (ip, failures, last_failure_at) VALUES (?, 1, ?)
+		ON CONFLICT (ip) DO UPDATE SET
+			failures = CASE WHEN login_ip_failures.last_failure_at < ? THEN 1 ELSE login_ip_failures.failures + 1 END,
+			last_failure_at = EXCLUDED.last_failure_at
+		RETURNING failures`, ip, time.Now(), windowStart).Scan(&failures).Error
+	return failures, err
+}
+
+// BlockLoginIP refuses sign-ins from ip until the given time and clears its
+// failure count.
+func (r *Repository) BlockLoginIP(ctx context.Context, ip string, until time.Time) error {
+	return r.DB.WithContext(ctx).Model(&models.LoginIPFailure{}).Where("ip = ?", ip).
+		Updates(map[string]interface{}{"blocked_until": until, "failures": 0}).Error
+}
+
+// EnableTOTP switches the account to TOTP with the confirmed secret and
+// replaces its recovery codes with the given hashes.
+func (r *Repository) EnableTOTP(ctx context.Context, authID uint, secret string, step int64, codeHashes []string) error {
+	return r.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(&models.Auth{}).Where("id = ?", authID).Updates(map[string]interface{}{
+			"totp_enabled":        true,
+			"totp_secret":         secret,
+			"totp_pending_secret": "",
+			"totp_last_step":      step,
+		}).Error
+		if err != nil {
+			return err
+		}
+		if err := tx.Where("auth_id = ?", authID).Delete(&models.RecoveryCode{}).Error; err != nil {
+			return err
+		}
+		codes := make([]models.RecoveryCode, len(codeHashes))
+		for i, h := range codeHashes {
+			codes[i] = models.RecoveryCode{AuthID: authID, CodeHash: h}
+		}
+		return tx.Create(&codes).Error
+	})
+}
+
+// AcceptTOTPStep records step as the last TOTP step used by the account. It
+// reports false when that step or a later one was already used.
+func (r *Repository) AcceptTOTPStep(ctx context.Context, authID uint, step int64) (bool, error) {
+	res := r.DB.WithContext(ctx).Model(&models.Auth{}).
+		Where("id = ? AND totp_last_step < ?", authID, step).
+		Update("totp_last_step", step)
+	if res.Error != nil {
+		return false, res.Error
+	}
+	return res.RowsAffected == 1, nil
+}
+
+// UseRecoveryCode marks the account's recovery code as used. It reports false
+// when no unused code has that hash.
+func (r *Repository) UseRecoveryCode(ctx context.Context, authID uint, hash string) (bool, error) {
+	res := r.DB.WithContext(ctx).Model(&models.RecoveryCode{}).
+		Where("auth_id = ? AND code_hash = ? AND used_at IS NULL", authID, hash).
+		Update("used_at", time.Now())
+	if res.Error != nil {
+		return false, res.Error
+	}
+	return res.RowsAffected == 1, nil
+}
+
+// CreateMFAChallenge stores a challenge, dropping the account's expired ones.
+func (r *Repository) CreateMFAChallenge(ctx context.Context, c *models.MFAChallenge) error {
+	db := r.DB.WithContext(ctx)
+	if err := db.Where("auth_id = ? AND expires_at < ?", c.AuthID, time.Now()).Delete(&models.MFAChallenge{}).Error; err != nil {
+		return err
+	}
+	return db.Create(c).Error
+}
+
+func (r *Repository) GetMFAChallenge(ctx context.Context, hash string) (*models.MFAChallenge, error) {
+	var c models.MFAChallenge
+	if err := r.DB.WithContext(ctx).Where("token_hash = ?", hash).First(&c).Error; err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// ConsumeMFAChallenge deletes the challenge. It reports false when another
+// request consumed it first.
+func (r *Repository) ConsumeMFAChallenge(ctx context.Context, hash string) (bool, error) {
+	res := r.DB.WithContext(ctx).Where("token_hash = ?", hash).Delete(&models.MFAChallenge{})
+	if res.Error != nil {
+		return false, res.Error
+	}
+	return res.RowsAffected == 1, nil
+}
+
+// CreateMagicLink stores l unless limit links were already requested for
+// its email since the given time, in which case it reports false. Links
+// requested before then are dropped.
+func (r *Repository) CreateMagicLink(ctx context.Context, l *models.MagicLink, limit int, since time.Time) (bool, error) {
+	created := false
+	err := r.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("email = ? AND created_at < ?", l.Email, since).Delete(&models.MagicLink{}).Error; err != nil {
+			return err
+		}
+		var recent int64
+		if err := tx.Model(&models.MagicLink{}).Where("email = ?", l.Email).Count(&recent).Error; err != nil {
+			return err
+		}
+		if recent >= int64(limit) {
+			return nil
+		}
+		created = true
+		return tx.Create(l).Error
+	})
+	return created, err
+}
+
+func (r *Repository) GetMagicLink(ctx context.Context, hash string) (*models.MagicLink, error) {
+	var l models.MagicLink
+	if err := r.DB.WithContext(ctx).Where("token_hash = ?", hash).First(&l).Error; err != nil {
+		return nil, err
+	}
+	return &l, nil
+}
+
+// UseMagicLink marks the link used. It reports false when it was already
+// used.
+func (r *Repository) UseMagicLink(ctx context.Context, hash string) (bool, error) {
+	res := r.DB.WithContext(ctx).Model(&models.MagicLink{}).
+		Where("token_hash = ? AND used_at IS NULL", hash).
+		Update("used_at", time.Now())
+	if res.Error != nil {
+		return false, res.Error
+	}
+	return res.RowsAffected == 1, nil
+}
+
+func (r *Repository) CreateAPIKey(ctx context.Context, k *models.APIKey) error {
+	return r.DB.WithContext(ctx).Create(k).Error
+}
+
+func (r *Repository) GetAPIKeyByHash(ctx context.Context, hash string) (*models.APIKey, error) {
+	var k models.APIKey
+	if err := r.DB.WithContext(ctx).Where("key_hash = ?", hash).First(&k).Error; err != nil {
+		return nil, err
+	}
+	return &k, nil
+}
+
+// ListAPIKeys returns the account's keys, newest first.
+func (r *Repository) ListAPIKeys(ctx context.Context, authID uint) ([]models.APIKey, error) {
+	var keys []models.APIKey
+	if err := r.DB.WithContext(ctx).Where("auth_id = ?", authID).Order("id desc").Find(&keys).Error; err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+// RevokeAPIKey revokes one of the account's keys. It reports false when the
+// account has no such unrevoked key.
+func (r *Repository) RevokeAPIKey(ctx context.Context, authID, id uint) (bool, error) {
+	res := r.DB.WithContext(ctx).Model(&models.APIKey{}).
+		Where("id = ? AND auth_id = ? AND revoked_at IS NULL", id, authID).
+		Update("revoked_at", time.Now())
+	if res.Error != nil {
+		return false, res.Error
+	}
+	return res.RowsAffected == 1, nil
+}
+
+// TouchAPIKey records that the key was used at t.
+func (r *Repository) TouchAPIKey(ctx context.Context, id uint, t time.Time) error {
+	return r.DB.WithContext(ctx).Model(&models.APIKey{}).Where("id = ?", id).Update("last_used_at", t).Error
+}
+
+func (r *Repository) CreateOAuthClient(ctx context.Context, c *models.OAuthClient) error {
+	return r.DB.WithContext(ctx).Create(c).Error
+}
+
+func (r *Repository) GetOAuthClient(ctx context.Context, id string) (*models.OAuthClient, error) {
+	var c models.OAuthClient
+	if err := r.DB.WithContext(ctx).Where("id = ?", id).First(&c).Error; err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+func (r *Repository) ListOAuthClients(ctx context.Context) ([]models.OAuthClient, error) {
+	var clients []models.OAuthClient
+	if err := r.DB.WithContext(ctx).Order("created_at").Find(&clients).Error; err != nil {
+		return nil, err
+	}
+	return clients, nil
+}
+
+// DeleteOAuthClient removes the client and its outstanding authorization
+// codes. It reports false when there is no such client.
+func (r *Repository) DeleteOAuthClient(ctx context.Context, id string) (bool, error) {
+	var deleted bool
+	err := r.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("client_id = ?", id).Delete(&models.AuthorizationCode{}).Error; err != nil {
+			return err
+		}
+		res := tx.Where("id = ?", id).Delete(&models.OAuthClient{})
+		deleted = res.RowsAffected == 1
+		return res.Error
+	})
+	return deleted, err
+}
+
+// CreateAuthorizationCode stores the code, clearing the client's expired
+// ones.
+func (r *Repository) CreateAuthorizationCode(ctx context.Context, c *models.AuthorizationCode) error {
+	db := r.DB.WithContext(ctx)
+	if err := db.Where("client_id = ? AND expires_at < ?", c.ClientID, time.Now()).Delete(&models.AuthorizationCode{}).Error; err != nil {
+		return err
+	}
+	return db.Create(c).Error
+}
+
+func (r *Repository) GetAuthorizationCode(ctx context.Context, hash string) (*models.AuthorizationCode, error) {
+	var c models.AuthorizationCode
+	if err := r.DB.WithContext(ctx).Where("code_hash = ?", hash).First(&c).Error; err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// ConsumeAuthorizationCode deletes the code. It reports false when another
+// request redeemed it first.
+func (r *Repository) ConsumeAuthorizationCode(ctx context.Context, hash string) (bool, error) {
+	res := r.DB.WithContext(ctx).Where("code_hash = ?", hash).Delete(&models.AuthorizationCode{})
+	if res.Error != nil {
+		return false, res.Error
+	}
+	return res.RowsAffected == 1, nil
+}
+
+// EnsureRole creates the role if it does not exist yet and grants it the
+// given permissions, leaving existing grants in place.
+func (r *Repository) EnsureRole(ctx context.Context, role *models.Role) error {
+	return r.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Omit("Permissions").Create(role).Error; err != nil {
+			return err
+		}
+		if len(role.Permissions) == 0 {
+			return nil
+		}
+		return tx.Model(role).Association("Permissions").Append(role.Permissions)
+	})
+}
+
+// ListRoles returns every role with its directly granted permissions.
+func (r *Repository) ListRoles(ctx context.Context) ([]models.Role, error) {
+	var roles []models.Role
+	if err := r.DB.WithContext(ctx).Preload("Permissions").Order("name").Find(&roles).Error; err != nil {
+		return nil, err
+	}
+	return roles, nil
+}
+
+// ListUserRoles returns the names of the roles assigned to the account.
+func (r *Repository) ListUserRoles(ctx context.Context, authID uint) ([]string, error) {
+	var names []string
+	err := r.DB.WithContext(ctx).Model(&models.UserRole{}).
+		Where("auth_id = ?", authID).Order("role_name").Pluck("role_name", &names).Error
+	return names, err
+}
+
+// AssignRole grants the role to the account. It reports false when the
+// account already held it.
+func (r *Repository) AssignRole(ctx context.Context, authID uint, role string) (bool, error) {
+	res := r.DB.WithContext(ctx).Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&models.UserRole{AuthID: authID, RoleName: role})
+	if res.Error != nil {
+		return false, res.Error
+	}
+	return res.RowsAffected == 1, nil
+}
+
+// RevokeRole takes the role away from the account. It reports false when the
+// account did not hold it.
+func (r *Repository) RevokeRole(ctx context.Context, authID uint, role string) (bool, error) {
+	res := r.DB.WithContext(ctx).Where("auth_id = ? AND role_name = ?", authID, role).Delete(&models.UserRole{})
+	if res.Error != nil {
+		return false, res.Error
+	}
+	return res.RowsAffected == 1, nil
+}
+
+// MigrateLegacyRoles copies the single role stored on accounts before roles
+// had their own table into user_roles. It only runs while user_roles is
+// empty, so roles revoked later are not restored on restart.
+func (r *Repository) MigrateLegacyRoles(ctx context.Context) error {
+	db := r.DB.WithContext(ctx)
+	if !db.Migrator().HasColumn(&models.Auth{}, "role") {
+		return nil
+	}
+	var count int64
+	if err := db.Model(&models.UserRole{}).Count(&count).Error; err != nil || count > 0 {
+		return err
+	}
+	return db.Exec(`INSERT INTO user_roles (auth_id, role_name, created_at)
+		SELECT a.id, lower(a.role), now() FROM auths a
+		JOIN roles ON roles.name = lower(a.role)
+		WHERE a.deleted_at IS NULL`).Error
+}
+
+func (r *Repository) CreateTest(ctx context.Context, t *models.Test) error {
+	return r.DB.WithContext(ctx).Create(t).Error
+}
+
+func (r *Repository) ListTests(ctx context.Context) ([]models.Test, error) {
 	var tests []models.Test
-	if err := r.DB.Order("id desc").Find(&tests).Error; err != nil {
+	if err := r.DB.WithContext(ctx).Order("id desc").Find(&tests).Error; err != nil {
 		return nil, err
 	}
 	return tests, nil
 }
+
+func (r *Repository) AddOutboxEvent(ctx context.Context, e *models.OutboxEvent) error {
+	return r.DB.WithContext(ctx).Create(e).Error
+}
+
+// outboxLockKey identifies the advisory lock held by the relay publishing
+// the outbox.
+const outboxLockKey = 0x6f7574626f78
+
+// LockOutbox takes the outbox lock for the rest of the transaction, so only
+// one relay publishes at a time and events keep their order. It reports
+// false when another relay holds it. It must be called inside Transaction.
+// Databases without advisory locks, such as the SQLite used in tests, only
+// ever have one relay and report the lock as taken.
+func (r *Repository) LockOutbox(ctx context.Context) (bool, error) {
+	if r.DB.Dialector.Name() != "postgres" {
+		return true, nil
+	}
+	var locked bool
+	err := r.DB.WithContext(ctx).Raw("SELECT pg_try_advisory_xact_lock(?)", outboxLockKey).Scan(&locked).Error
+	return locked, err
+}
+
+// PendingOutboxEvents returns up to limit unpublished events, oldest first.
+func (r *Repository) PendingOutboxEvents(ctx context.Context, limit int) ([]models.OutboxEvent, error) {
+	var evts []models.OutboxEvent
+	err := r.DB.WithContext(ctx).Where("published_at IS NULL").Order("id asc").Limit(limit).Find(&evts).Error
+	return evts, err
+}
+
+func (r *Repository) MarkOutboxEventPublished(ctx context.Context, id uint, t time.Time) error {
+	return r.DB.WithContext(ctx).Model(&models.OutboxEvent{}).Where("id = ?", id).Update("published_at", t).Error
+}
+
+// RecordOutboxFailure counts a failed attempt to publish the event.
+func (r *Repository) RecordOutboxFailure(ctx context.Context, id uint, msg string) error {
+	return r.DB.WithContext(ctx).Model(&models.OutboxEvent{}).Where("id = ?", id).
+		Updates(map[string]interface{}{"attempts": gorm.Expr("attempts + 1"), "last_error": msg}).Error
+}
+
+// PurgePublishedOutboxEvents deletes events published before t.
+func (r *Repository) PurgePublishedOutboxEvents(ctx context.Context, t time.Time) error {
+	return r.DB.WithContext(ctx).Where("published_at < ?", t).Delete(&models.OutboxEvent{}).Error
+}
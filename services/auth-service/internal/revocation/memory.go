@@ -0,0 +1,49 @@
+package revocation
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// MemoryStore keeps revocations in process memory. It is meant for tests and
+// single-instance development; entries are lost on restart.
+type MemoryStore struct {
+	mu      sync.RWMutex
+	tokens  map[string]time.Time
+	cutoffs map[string]time.Time
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		tokens:  make(map[string]time.Time),
+		cutoffs: make(map[string]time.Time),
+	}
+}
+
+func (m *MemoryStore) Revoke(ctx context.Context, jti string, expiresAt time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.tokens[jti] = expiresAt
+	return nil
+}
+
+func (m *MemoryStore) IsRevoked(ctx context.Context, jti string) (bool, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	_, ok := m.tokens[jti]
+	return ok, nil
+}
+
+func (m *MemoryStore) RevokeAllForUser(ctx context.Context, userID string, before time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.cutoffs[userID] = before
+	return nil
+}
+
+func (m *MemoryStore) RevokedBefore(ctx context.Context, userID string) (time.Time, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.cutoffs[userID], nil
+}
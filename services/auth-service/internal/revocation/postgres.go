@@ -0,0 +1,59 @@
+package revocation
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"go-microservices/services/auth-service/internal/models"
+	"go-microservices/services/auth-service/internal/repository"
+)
+
+// PostgresStore persists revocations through the auth repository so they
+// survive restarts and are shared by every auth-service replica.
+type PostgresStore struct {
+	repo *repository.Repository
+}
+
+func NewPostgresStore(repo *repository.Repository) *PostgresStore {
+	return &PostgresStore{repo: repo}
+}
+
+func (p *PostgresStore) Revoke(ctx context.Context, jti string, expiresAt time.Time) error {
+	return p.repo.RevokeToken(ctx, &models.RevokedToken{JTI: jti, ExpiresAt: expiresAt})
+}
+
+func (p *PostgresStore) IsRevoked(ctx context.Context, jti string) (bool, error) {
+	return p.repo.IsTokenRevoked(ctx, jti)
+}
+
+func (p *PostgresStore) RevokeAllForUser(ctx context.Context, userID string, before time.Time) error {
+	authID, err := parseUserID(userID)
+	if err != nil {
+		return err
+	}
+	return p.repo.SetTokenCutoff(ctx, &models.TokenCutoff{AuthID: authID, RevokedBefore: before})
+}
+
+// RevokedBefore returns the zero time for subjects that are not accounts,
+// such as OAuth clients, since cutoffs are only ever set for accounts.
+func (p *PostgresStore) RevokedBefore(ctx context.Context, userID string) (time.Time, error) {
+	authID, err := parseUserID(userID)
+	if err != nil {
+		return time.Time{}, nil
+	}
+	c, err := p.repo.GetTokenCutoff(ctx, authID)
+	if err != nil || c == nil {
+		return time.Time{}, err
+	}
+	return c.RevokedBefore, nil
+}
+
+func parseUserID(userID string) (uint, error) {
+	id, err := strconv.ParseUint(userID, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid user id %q: %w", userID, err)
+	}
+	return uint(id), nil
+}
@@ -0,0 +1,94 @@
+package revocation
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// negativeTTL bounds how long a "not revoked" answer is cached. Revocations
+// made through any replica are written through to Redis immediately, so this
+// only matters if Redis and the backing store diverge.
+const negativeTTL = time.Minute
+
+// RedisCache fronts another Store with Redis so hot-path validation does not
+// hit Postgres on every request. Writes go to both.
+type RedisCache struct {
+	client *redis.Client
+	next   Store
+}
+
+func NewRedisCache(client *redis.Client, next Store) *RedisCache {
+	return &RedisCache{client: client, next: next}
+}
+
+func (r *RedisCache) Revoke(ctx context.Context, jti string, expiresAt time.Time) error {
+	if err := r.next.Revoke(ctx, jti, expiresAt); err != nil {
+		return err
+	}
+	ttl := time.Until(expiresAt)
+	if ttl <= 0 {
+		return nil
+	}
+	return r.client.Set(ctx, tokenKey(jti), "1", ttl).Err()
+}
+
+func (r *RedisCache) IsRevoked(ctx context.Context, jti string) (bool, error) {
+	v, err := r.client.Get(ctx, tokenKey(jti)).Result()
+	if err == nil {
+		return v == "1", nil
+	}
+	if !errors.Is(err, redis.Nil) {
+		return r.next.IsRevoked(ctx, jti)
+	}
+
+	revoked, err := r.next.IsRevoked(ctx, jti)
+	if err != nil {
+		return false, err
+	}
+	if !revoked {
+		r.client.Set(ctx, tokenKey(jti), "0", negativeTTL)
+	}
+	return revoked, nil
+}
+
+func (r *RedisCache) RevokeAllForUser(ctx context.Context, userID string, before time.Time) error {
+	if err := r.next.RevokeAllForUser(ctx, userID, before); err != nil {
+		return err
+	}
+	return r.client.Set(ctx, cutoffKey(userID), strconv.FormatInt(before.Unix(), 10), 0).Err()
+}
+
+func (r *RedisCache) RevokedBefore(ctx context.Context, userID string) (time.Time, error) {
+	v, err := r.client.Get(ctx, cutoffKey(userID)).Result()
+	if err == nil {
+		if sec, perr := strconv.ParseInt(v, 10, 64); perr == nil {
+			if sec == 0 {
+				return time.Time{}, nil
+			}
+			return time.Unix(sec, 0), nil
+		}
+	}
+
+	before, err := r.next.RevokedBefore(ctx, userID)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if before.IsZero() {
+		r.client.Set(ctx, cutoffKey(userID), "0", negativeTTL)
+	} else {
+		r.client.Set(ctx, cutoffKey(userID), strconv.FormatInt(before.Unix(), 10), 0)
+	}
+	return before, nil
+}
+
+func tokenKey(jti string) string {
+	return "auth:revoked:" + jti
+}
+
+func cutoffKey(userID string) string {
+	return "auth:revoked-before:" + userID
+}
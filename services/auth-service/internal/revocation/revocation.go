@@ -0,0 +1,17 @@
+package revocation
+
+import (
+	"context"
+	"time"
+)
+
+// Store records revoked tokens. Individual tokens are revoked by jti until
+// they would have expired anyway; RevokeAllForUser invalidates every token a
+// user was issued before the given instant.
+type Store interface {
+	Revoke(ctx context.Context, jti string, expiresAt time.Time) error
+	IsRevoked(ctx context.Context, jti string) (bool, error)
+	RevokeAllForUser(ctx context.Context, userID string, before time.Time) error
+	// RevokedBefore returns the user's cutoff, or the zero time if none is set.
+	RevokedBefore(ctx context.Context, userID string) (time.Time, error)
+}
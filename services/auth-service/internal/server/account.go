@@ -0,0 +1,291 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/mail"
+	"strings"
+	"time"
+
+	"go-microservices/pkg/events"
+	pb "go-microservices/proto/auth"
+	"go-microservices/services/auth-service/internal/outbox"
+	"go-microservices/services/auth-service/internal/repository"
+	"go-microservices/services/auth-service/internal/utils"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// emailChangeTokenTTL is how long the link confirming a new email address
+// stays valid.
+const emailChangeTokenTTL = 24 * time.Hour
+
+// ChangePassword sets a new password for a signed-in user who proves they
+// know the current one. Wrong current passwords count as failed sign-ins.
+// Every session except the caller's is signed out.
+func (s *AuthServer) ChangePassword(ctx context.Context, req *pb.ChangePasswordRequest) (*pb.ChangePasswordResponse, error) {
+	if err := rejectImpersonation(ctx); err != nil {
+		return nil, err
+	}
+	if req.CurrentPassword == "" {
+		return nil, status.Errorf(codes.InvalidArgument, "current password required")
+	}
+	if req.NewPassword == "" {
+		return nil, status.Errorf(codes.InvalidArgument, "new password required")
+	}
+	auth, err := s.authByUserID(ctx, req.UserId)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.checkAccountThrottle(auth); err != nil {
+		return nil, err
+	}
+
+	match, _, err := s.hasher.Verify(req.CurrentPassword, auth.Password)
+	if err != nil {
+		log.Printf("failed to verify password of account %d: %v", auth.ID, err)
+	}
+	if !match {
+		s.recordFailedSignIn(ctx, auth, clientIP(ctx))
+		return nil, status.Errorf(codes.InvalidArgument, "current password is incorrect")
+	}
+	if err := s.checkPassword(ctx, "new_password", req.NewPassword, auth.Username, auth.Email); err != nil {
+		return nil, err
+	}
+
+	hashed, err := s.hasher.Hash(req.NewPassword)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to hash password: %v", err)
+	}
+	replaced, err := s.repo.ReplacePasswordHash(ctx, auth.ID, auth.Password, hashed)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to change password: %v", err)
+	}
+	if !replaced {
+		// The stored hash changed since it was verified, e.g. by a sign-in
+		// upgrading it; the caller can simply try again.
+		return nil, status.Errorf(codes.Aborted, "password changed concurrently, please retry")
+	}
+	if err := s.endOtherSessions(ctx, auth.ID, req.SessionId); err != nil {
+		return nil, err
+	}
+
+	s.sendMail(ctx, passwordChangedEmail(auth.Email, auth.Username, "your other sessions were"))
+	return &pb.ChangePasswordResponse{Success: true, Message: "password changed"}, nil
+}
+
+// ChangeEmail starts moving the account to a new address by emailing a
+// confirmation link to it. The current address stays in use until
+// ConfirmEmailChange; a newer request replaces a pending one.
+func (s *AuthServer) ChangeEmail(ctx context.Context, req *pb.ChangeEmailRequest) (*pb.ChangeEmailResponse, error) {
+	if err := rejectImpersonation(ctx); err != nil {
+		return nil, err
+	}
+	email := strings.TrimSpace(req.NewEmail)
+	if email == "" {
+		return nil, status.Errorf(codes.InvalidArgument, "new email required")
+	}
+	if addr, err := mail.ParseAddress(email); err != nil || addr.Address != email {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid email address")
+	}
+	auth, err := s.authByUserID(ctx, req.UserId)
+	if err != nil {
+		return nil, err
+	}
+	if strings.EqualFold(email, auth.Email) {
+		return nil, status.Errorf(codes.InvalidArgument, "new email is the current email")
+	}
+	if err := s.checkEmailAvailable(ctx, email); err != nil {
+		return nil, err
+	}
+
+	token := utils.GenerateRandomToken()
+	auth.PendingEmail = email
+	auth.EmailChangeToken = utils.HashToken(token)
+	auth.EmailChangeTokenExpiry = time.Now().Add(emailChangeTokenTTL)
+	if err := s.repo.SaveAuth(ctx, auth); err != nil {
+		return nil, accountError(err, "start email change")
+	}
+
+	s.sendMail(ctx, emailChangeEmail(email, auth.Username, s.frontendLink("/confirm-email-change", token)))
+	return &pb.ChangeEmailResponse{Success: true, Message: "check your new address to confirm the change"}, nil
+}
+
+// ConfirmEmailChange switches the account to the address a ChangeEmail link
+// was sent to and notifies the old address. An EmailChanged event is
+// published so the user's profile follows.
+func (s *AuthServer) ConfirmEmailChange(ctx context.Context, req *pb.ConfirmEmailChangeRequest) (*pb.ConfirmEmailChangeResponse, error) {
+	if req.Token == "" {
+		return nil, status.Errorf(codes.InvalidArgument, "token required")
+	}
+	hash := utils.HashToken(req.Token)
+	auth, err := s.repo.GetAuthByEmailChangeToken(ctx, hash)
+	if err != nil || auth == nil || auth.PendingEmail == "" || time.Now().After(auth.EmailChangeTokenExpiry) {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid or expired token")
+	}
+	if err := s.checkEmailAvailable(ctx, auth.PendingEmail); err != nil {
+		return nil, err
+	}
+
+	oldEmail, newEmail := auth.Email, auth.PendingEmail
+	userID := fmt.Sprintf("%d", auth.ID)
+	err = s.repo.Transaction(ctx, func(tx *repository.Repository) error {
+		changed, err := tx.ConfirmEmailChange(ctx, auth.ID, hash)
+		if err != nil {
+			return accountError(err, "change email")
+		}
+		if !changed {
+			return status.Errorf(codes.InvalidArgument, "invalid or expired token")
+		}
+		if err := outbox.Record(ctx, tx, events.TypeEmailChanged, userID, events.EmailChanged{
+			UserID:   userID,
+			Username: auth.Username,
+			OldEmail: oldEmail,
+			NewEmail: newEmail,
+		}); err != nil {
+			return status.Errorf(codes.Internal, "failed to record email change: %v", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.sendMail(ctx, emailChangedEmail(oldEmail, auth.Username, newEmail))
+	return &pb.ConfirmEmailChangeResponse{Success: true, Message: "email changed"}, nil
+}
+
+// ChangeUsername renames the account if the name is free and the previous
+// change is older than the configured cooldown. A UsernameChanged event is
+// published so the user's profile follows.
+func (s *AuthServer) ChangeUsername(ctx context.Context, req *pb.ChangeUsernameRequest) (*pb.ChangeUsernameResponse, error) {
+	if err := rejectImpersonation(ctx); err != nil {
+		return nil, err
+	}
+	username := strings.TrimSpace(req.NewUsername)
+	if username == "" {
+		return nil, status.Errorf(codes.InvalidArgument, "new username required")
+	}
+	auth, err := s.authByUserID(ctx, req.UserId)
+	if err != nil {
+		return nil, err
+	}
+	if username == auth.Username {
+		return nil, status.Errorf(codes.InvalidArgument, "new username is the current username")
+	}
+	if auth.UsernameChangedAt != nil {
+		if next := auth.UsernameChangedAt.Add(s.env.UsernameChangeCooldown); time.Now().Before(next) {
+			return nil, status.Errorf(codes.FailedPrecondition, "username was changed recently; it can be changed again after %s",
+				next.UTC().Format(time.RFC3339))
+		}
+	}
+	taken, err := s.repo.UsernameInUse(ctx, username)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to check username: %v", err)
+	}
+	if taken {
+		return nil, status.Errorf(codes.AlreadyExists, "username already taken")
+	}
+
+	now := time.Now()
+	oldUsername := auth.Username
+	auth.Username = username
+	auth.UsernameChangedAt = &now
+	userID := fmt.Sprintf("%d", auth.ID)
+	err = s.repo.Transaction(ctx, func(tx *repository.Repository) error {
+		if err := tx.SaveAuth(ctx, auth); err != nil {
+			return accountError(err, "change username")
+		}
+		if err := outbox.Record(ctx, tx, events.TypeUsernameChanged, userID, events.UsernameChanged{
+			UserID:      userID,
+			Email:       auth.Email,
+			OldUsername: oldUsername,
+			NewUsername: username,
+		}); err != nil {
+			return status.Errorf(codes.Internal, "failed to record username change: %v", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &pb.ChangeUsernameResponse{Success: true, Message: "username changed", Username: username}, nil
+}
+
+func (s *AuthServer) checkEmailAvailable(ctx context.Context, email string) error {
+	taken, err := s.repo.EmailInUse(ctx, email)
+	if err != nil {
+		return status.Errorf(codes.Internal, "failed to check email: %v", err)
+	}
+	if taken {
+		return status.Errorf(codes.AlreadyExists, "email already in use")
+	}
+	return nil
+}
+
+// accountError maps a failed write to an account: a clash with another
+// account's email or username is AlreadyExists, anything else is logged and
+// reported as Internal without the database's wording.
+func accountError(err error, op string) error {
+	if errors.Is(err, repository.ErrDuplicate) {
+		return status.Errorf(codes.AlreadyExists, "username or email already taken")
+	}
+	log.Printf("failed to %s: %v", op, err)
+	return status.Errorf(codes.Internal, "failed to %s", op)
+}
+
+// DeleteAccount permanently deletes a signed-in user's account once they
+// confirm their password. Every token issued to the account stops working,
+// and an AccountDeleted event is published so the user's profile is removed
+// too.
+func (s *AuthServer) DeleteAccount(ctx context.Context, req *pb.DeleteAccountRequest) (*pb.DeleteAccountResponse, error) {
+	if err := rejectImpersonation(ctx); err != nil {
+		return nil, err
+	}
+	if req.Password == "" {
+		return nil, status.Errorf(codes.InvalidArgument, "password required")
+	}
+	auth, err := s.authByUserID(ctx, req.UserId)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.checkAccountThrottle(auth); err != nil {
+		return nil, err
+	}
+
+	match, _, err := s.hasher.Verify(req.Password, auth.Password)
+	if err != nil {
+		log.Printf("failed to verify password of account %d: %v", auth.ID, err)
+	}
+	if !match {
+		s.recordFailedSignIn(ctx, auth, clientIP(ctx))
+		return nil, status.Errorf(codes.InvalidArgument, "password is incorrect")
+	}
+
+	userID := fmt.Sprintf("%d", auth.ID)
+	if err := s.revoked.RevokeAllForUser(ctx, userID, time.Now()); err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to revoke access tokens: %v", err)
+	}
+	err = s.repo.Transaction(ctx, func(tx *repository.Repository) error {
+		if err := tx.DeleteAuth(ctx, auth.ID); err != nil {
+			return status.Errorf(codes.Internal, "failed to delete account: %v", err)
+		}
+		if err := outbox.Record(ctx, tx, events.TypeAccountDeleted, userID, events.AccountDeleted{
+			UserID: userID,
+			Email:  auth.Email,
+		}); err != nil {
+			return status.Errorf(codes.Internal, "failed to record account deletion: %v", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.sendMail(ctx, accountDeletedEmail(auth.Email, auth.Username))
+	return &pb.DeleteAccountResponse{Success: true, Message: "account deleted"}, nil
+}
@@ -0,0 +1,96 @@
+package server
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	pb "go-microservices/proto/auth"
+	"go-microservices/services/auth-service/internal/password"
+
+	"golang.org/x/crypto/bcrypt"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestSignUpRejectsDuplicateAccount(t *testing.T) {
+	srv, _, _ := newDBTestServer(t)
+	ctx := context.Background()
+	const pw = "correct horse battery staple"
+
+	if _, err := srv.SignUp(ctx, &pb.SignUpRequest{Username: "jane", Email: "jane@example.com", Password: pw}); err != nil {
+		t.Fatalf("SignUp: %v", err)
+	}
+	for _, req := range []*pb.SignUpRequest{
+		{Username: "jane2", Email: "jane@example.com", Password: pw},
+		{Username: "jane", Email: "other@example.com", Password: pw},
+	} {
+		_, err := srv.SignUp(ctx, req)
+		if status.Code(err) != codes.AlreadyExists {
+			t.Fatalf("SignUp(%s, %s): expected AlreadyExists, got %v", req.Username, req.Email, err)
+		}
+		if strings.Contains(status.Convert(err).Message(), "UNIQUE") {
+			t.Fatalf("database error leaked: %v", err)
+		}
+	}
+}
+
+// racingScheme is bcrypt with a hook run before each hash, standing in for
+// a concurrent request changing the stored hash.
+type racingScheme struct {
+	password.Bcrypt
+	before func()
+}
+
+func (r racingScheme) Hash(pw string) (string, error) {
+	if r.before != nil {
+		r.before()
+	}
+	return r.Bcrypt.Hash(pw)
+}
+
+func TestChangePasswordFailsWhenHashChangedConcurrently(t *testing.T) {
+	var race func()
+	scheme := racingScheme{Bcrypt: password.Bcrypt{Cost: bcrypt.MinCost}, before: func() {
+		if race != nil {
+			race()
+		}
+	}}
+	srv, mail, _ := newDBTestServer(t, WithPasswordHasher(password.NewHasher(scheme)))
+	ctx := context.Background()
+	const email, pw = "jane@example.com", "correct horse battery staple"
+
+	user, err := srv.SignUp(ctx, &pb.SignUpRequest{Username: "jane", Email: email, Password: pw})
+	if err != nil {
+		t.Fatalf("SignUp: %v", err)
+	}
+	auth, err := srv.repo.GetAuthByEmail(ctx, email)
+	if err != nil {
+		t.Fatalf("GetAuthByEmail: %v", err)
+	}
+	// A sign-in re-hashes the same password while the change is running.
+	race = func() {
+		race = nil
+		rehashed, _ := scheme.Bcrypt.Hash(pw)
+		if ok, err := srv.repo.ReplacePasswordHash(ctx, auth.ID, auth.Password, rehashed); err != nil || !ok {
+			t.Fatalf("concurrent rehash: %v %v", ok, err)
+		}
+	}
+	sent := len(mail.Sent())
+
+	_, err = srv.ChangePassword(ctx, &pb.ChangePasswordRequest{UserId: user.UserId, CurrentPassword: pw, NewPassword: "a brand new passphrase"})
+	if status.Code(err) != codes.Aborted {
+		t.Fatalf("expected Aborted, got %v", err)
+	}
+	if len(mail.Sent()) != sent {
+		t.Fatal("expected no password-changed email")
+	}
+	if _, err := srv.SignIn(ctx, &pb.SignInRequest{Email: email, Password: pw}); err != nil {
+		t.Fatalf("expected the old password to still work: %v", err)
+	}
+
+	// Retrying against the new hash succeeds.
+	if _, err := srv.ChangePassword(ctx, &pb.ChangePasswordRequest{UserId: user.UserId, CurrentPassword: pw, NewPassword: "a brand new passphrase"}); err != nil {
+		t.Fatalf("ChangePassword retry: %v", err)
+	}
+}
@@ -0,0 +1,208 @@
+package server
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	pb "go-microservices/proto/auth"
+	"go-microservices/services/auth-service/internal/models"
+	"go-microservices/services/auth-service/internal/rbac"
+	"go-microservices/services/auth-service/internal/utils"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	// apiKeyPrefix marks our keys so secret scanners and humans can spot them.
+	apiKeyPrefix = "gms_"
+	// apiKeyDisplayLen is how much of a key is kept in clear to identify it.
+	apiKeyDisplayLen = len(apiKeyPrefix) + 8
+	// apiKeyTouchInterval limits how often last-used tracking writes to the
+	// database for a busy key.
+	apiKeyTouchInterval = time.Minute
+)
+
+// CreateAPIKey issues a key for machine clients. Its scopes must be
+// permissions the user holds without a second factor, so a key can never do
+// more than a password sign-in could. The key is returned only once.
+func (s *AuthServer) CreateAPIKey(ctx context.Context, req *pb.CreateAPIKeyRequest) (*pb.CreateAPIKeyResponse, error) {
+	if err := rejectImpersonation(ctx); err != nil {
+		return nil, err
+	}
+	auth, err := s.authByUserID(ctx, req.UserId)
+	if err != nil {
+		return nil, err
+	}
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		return nil, status.Errorf(codes.InvalidArgument, "name required")
+	}
+	if len(req.Scopes) == 0 {
+		return nil, status.Errorf(codes.InvalidArgument, "at least one scope required")
+	}
+	var expiresAt *time.Time
+	if req.ExpiresAt != 0 {
+		t := time.Unix(req.ExpiresAt, 0)
+		if !t.After(time.Now()) {
+			return nil, status.Errorf(codes.InvalidArgument, "expiry must be in the future")
+		}
+		expiresAt = &t
+	}
+
+	_, perms, err := rbac.Access(ctx, s.repo, auth.ID, false)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to load permissions: %v", err)
+	}
+	for _, scope := range req.Scopes {
+		if !containsString(perms, scope) {
+			return nil, status.Errorf(codes.PermissionDenied, "scope %q is not a permission you hold", scope)
+		}
+	}
+
+	key, err := newSecret(apiKeyPrefix)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to generate key: %v", err)
+	}
+	record := &models.APIKey{
+		AuthID:    auth.ID,
+		Name:      name,
+		Prefix:    key[:apiKeyDisplayLen],
+		KeyHash:   utils.HashToken(key),
+		Scopes:    req.Scopes,
+		ExpiresAt: expiresAt,
+	}
+	if err := s.repo.CreateAPIKey(ctx, record); err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to store key: %v", err)
+	}
+	return &pb.CreateAPIKeyResponse{Key: key, ApiKey: apiKeyToProto(record)}, nil
+}
+
+func (s *AuthServer) ListAPIKeys(ctx context.Context, req *pb.ListAPIKeysRequest) (*pb.ListAPIKeysResponse, error) {
+	auth, err := s.authByUserID(ctx, req.UserId)
+	if err != nil {
+		return nil, err
+	}
+	keys, err := s.repo.ListAPIKeys(ctx, auth.ID)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to list keys: %v", err)
+	}
+	resp := &pb.ListAPIKeysResponse{ApiKeys: make([]*pb.APIKey, 0, len(keys))}
+	for i := range keys {
+		resp.ApiKeys = append(resp.ApiKeys, apiKeyToProto(&keys[i]))
+	}
+	return resp, nil
+}
+
+// RevokeAPIKey revokes one of the user's keys. Gateways may keep accepting it
+// until their validation cache expires.
+func (s *AuthServer) RevokeAPIKey(ctx context.Context, req *pb.RevokeAPIKeyRequest) (*pb.RevokeAPIKeyResponse, error) {
+	if err := rejectImpersonation(ctx); err != nil {
+		return nil, err
+	}
+	auth, err := s.authByUserID(ctx, req.UserId)
+	if err != nil {
+		return nil, err
+	}
+	id, err := strconv.ParseUint(req.Id, 10, 64)
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid key id: %v", err)
+	}
+	revoked, err := s.repo.RevokeAPIKey(ctx, auth.ID, uint(id))
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to revoke key: %v", err)
+	}
+	if !revoked {
+		return nil, status.Errorf(codes.NotFound, "api key not found")
+	}
+	return &pb.RevokeAPIKeyResponse{Success: true, Message: "api key revoked"}, nil
+}
+
+// ValidateAPIKey resolves a key to its owner and scopes. Scopes the owner no
+// longer holds are dropped, so revoking a role also narrows the user's keys.
+func (s *AuthServer) ValidateAPIKey(ctx context.Context, req *pb.ValidateAPIKeyRequest) (*pb.ValidateAPIKeyResponse, error) {
+	invalid := &pb.ValidateAPIKeyResponse{Valid: false, Message: "invalid api key"}
+	if !strings.HasPrefix(req.Key, apiKeyPrefix) {
+		return invalid, nil
+	}
+	key, err := s.repo.GetAPIKeyByHash(ctx, utils.HashToken(req.Key))
+	if err != nil {
+		return invalid, nil
+	}
+	now := time.Now()
+	if key.RevokedAt != nil || (key.ExpiresAt != nil && now.After(*key.ExpiresAt)) {
+		return invalid, nil
+	}
+
+	_, perms, err := rbac.Access(ctx, s.repo, key.AuthID, false)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to load permissions: %v", err)
+	}
+	scopes := make([]string, 0, len(key.Scopes))
+	for _, scope := range key.Scopes {
+		if containsString(perms, scope) {
+			scopes = append(scopes, scope)
+		}
+	}
+
+	if key.LastUsedAt == nil || now.Sub(*key.LastUsedAt) > apiKeyTouchInterval {
+		if err := s.repo.TouchAPIKey(ctx, key.ID, now); err != nil {
+			log.Printf("failed to record use of api key %d: %v", key.ID, err)
+		}
+	}
+
+	resp := &pb.ValidateAPIKeyResponse{
+		Valid:   true,
+		UserId:  fmt.Sprintf("%d", key.AuthID),
+		Scopes:  scopes,
+		Message: "valid",
+	}
+	if key.ExpiresAt != nil {
+		resp.ExpiresAt = key.ExpiresAt.Unix()
+	}
+	return resp, nil
+}
+
+// newSecret returns prefix followed by 192 random bits in hex.
+func newSecret(prefix string) (string, error) {
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return prefix + hex.EncodeToString(b), nil
+}
+
+func apiKeyToProto(k *models.APIKey) *pb.APIKey {
+	return &pb.APIKey{
+		Id:         fmt.Sprintf("%d", k.ID),
+		Name:       k.Name,
+		Prefix:     k.Prefix,
+		Scopes:     k.Scopes,
+		CreatedAt:  k.CreatedAt.Unix(),
+		ExpiresAt:  unixOrZero(k.ExpiresAt),
+		LastUsedAt: unixOrZero(k.LastUsedAt),
+		Revoked:    k.RevokedAt != nil,
+	}
+}
+
+func unixOrZero(t *time.Time) int64 {
+	if t == nil {
+		return 0
+	}
+	return t.Unix()
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
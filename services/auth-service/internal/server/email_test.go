@@ -0,0 +1,61 @@
+package server
+
+import (
+	"context"
+	"regexp"
+	"testing"
+
+	pb "go-microservices/proto/auth"
+	"go-microservices/services/auth-service/internal/mailer"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+var linkToken = regexp.MustCompile(`token=([0-9a-f]+)`)
+
+// emailedToken returns the token in the link of the last email sent to to.
+func emailedToken(t *testing.T, mail *mailer.MemoryMailer, to string) string {
+	t.Helper()
+	msg, ok := mail.Last(to)
+	if !ok {
+		t.Fatalf("no email sent to %s", to)
+	}
+	m := linkToken.FindStringSubmatch(msg.Body)
+	if m == nil {
+		t.Fatalf("no token in email %q", msg.Body)
+	}
+	return m[1]
+}
+
+func TestSignUpRequiresConfirmedEmailBeforeSignIn(t *testing.T) {
+	srv, mail, env := newDBTestServer(t)
+	env.RequireVerifiedEmail = true
+	ctx := context.Background()
+
+	const email = "jane@example.com"
+	const pw = "correct horse battery staple"
+	if _, err := srv.SignUp(ctx, &pb.SignUpRequest{Username: "jane", Email: email, Password: pw}); err != nil {
+		t.Fatalf("SignUp: %v", err)
+	}
+
+	if _, err := srv.SignIn(ctx, &pb.SignInRequest{Email: email, Password: pw}); status.Code(err) != codes.FailedPrecondition {
+		t.Fatalf("expected FailedPrecondition before confirming, got %v", err)
+	}
+
+	token := emailedToken(t, mail, email)
+	if _, err := srv.ConfirmEmail(ctx, &pb.ConfirmEmailRequest{Token: token}); err != nil {
+		t.Fatalf("ConfirmEmail: %v", err)
+	}
+	if _, err := srv.ConfirmEmail(ctx, &pb.ConfirmEmailRequest{Token: token}); status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("expected a used token to be rejected, got %v", err)
+	}
+
+	resp, err := srv.SignIn(ctx, &pb.SignInRequest{Email: email, Password: pw})
+	if err != nil {
+		t.Fatalf("SignIn after confirming: %v", err)
+	}
+	if resp.AccessToken == "" {
+		t.Fatal("expected an access token")
+	}
+}
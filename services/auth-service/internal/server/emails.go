@@ -0,0 +1,105 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/url"
+	"strings"
+	"time"
+
+	"go-microservices/services/auth-service/internal/mailer"
+)
+
+// frontendLink builds a link into the frontend carrying a one-time token.
+func (s *AuthServer) frontendLink(path, token string) string {
+	return strings.TrimRight(s.env.FrontendURL, "/") + path + "?token=" + url.QueryEscape(token)
+}
+
+// sendMail delivers msg, logging rather than failing the calling RPC: the
+// user can always ask for the email again.
+func (s *AuthServer) sendMail(ctx context.Context, msg mailer.Message) {
+	if err := s.mailer.Send(ctx, msg); err != nil {
+		log.Printf("failed to send %q email: %v", msg.Subject, err)
+	}
+}
+
+func confirmationEmail(to, username, link string) mailer.Message {
+	return mailer.Message{
+		To:      to,
+		Subject: "Confirm your email address",
+		Body: fmt.Sprintf("Hi %s,\n\nPlease confirm your email address by opening the link below:\n\n%s\n\n"+
+			"The link expires in %d hours. If you did not create an account, you can ignore this email.\n",
+			username, link, int(verificationTokenTTL.Hours())),
+	}
+}
+
+func passwordResetEmail(to, username, link string) mailer.Message {
+	return mailer.Message{
+		To:      to,
+		Subject: "Reset your password",
+		Body: fmt.Sprintf("Hi %s,\n\nWe received a request to reset your password. Open the link below to choose a new one:\n\n%s\n\n"+
+			"The link can be used once and expires in %d minutes. If you did not ask for a reset, you can ignore this email.\n",
+			username, link, int(resetTokenTTL.Minutes())),
+	}
+}
+
+func magicLinkEmail(to, username, link string) mailer.Message {
+	return mailer.Message{
+		To:      to,
+		Subject: "Your sign-in link",
+		Body: fmt.Sprintf("Hi %s,\n\nOpen the link below to sign in:\n\n%s\n\n"+
+			"The link can be used once and expires in %d minutes. If you did not ask to sign in, you can ignore this email.\n",
+			username, link, int(magicLinkTTL.Minutes())),
+	}
+}
+
+// passwordChangedEmail notifies the owner of a password change. signedOut
+// says which sessions were signed out, e.g. "all of your sessions were".
+func passwordChangedEmail(to, username, signedOut string) mailer.Message {
+	return mailer.Message{
+		To:      to,
+		Subject: "Your password was changed",
+		Body: fmt.Sprintf("Hi %s,\n\nThe password for your account was just changed and %s signed out.\n\n"+
+			"If this wasn't you, reset your password immediately and contact support.\n", username, signedOut),
+	}
+}
+
+func emailChangeEmail(to, username, link string) mailer.Message {
+	return mailer.Message{
+		To:      to,
+		Subject: "Confirm your new email address",
+		Body: fmt.Sprintf("Hi %s,\n\nPlease confirm that you want to use this address for your account by opening the link below:\n\n%s\n\n"+
+			"The link expires in %d hours. Until then your current address stays in use. If you did not ask for this, you can ignore this email.\n",
+			username, link, int(emailChangeTokenTTL.Hours())),
+	}
+}
+
+func emailChangedEmail(to, username, newEmail string) mailer.Message {
+	return mailer.Message{
+		To:      to,
+		Subject: "Your email address was changed",
+		Body: fmt.Sprintf("Hi %s,\n\nThe email address for your account was just changed to %s, and account emails will go there from now on.\n\n"+
+			"If this wasn't you, contact support immediately.\n", username, newEmail),
+	}
+}
+
+func accountDeletedEmail(to, username string) mailer.Message {
+	return mailer.Message{
+		To:      to,
+		Subject: "Your account was deleted",
+		Body: fmt.Sprintf("Hi %s,\n\nYour account and profile were deleted as you asked. You have been signed out everywhere.\n\n"+
+			"If this wasn't you, contact support immediately.\n", username),
+	}
+}
+
+func accountLockedEmail(to, username string, until time.Time) mailer.Message {
+	return mailer.Message{
+		To:      to,
+		Subject: "Your account was locked",
+		Body: fmt.Sprintf("Hi %s,\n\nYour account was locked after too many failed sign-in attempts. "+
+			"You can sign in again after %s.\n\n"+
+			"If these attempts weren't you, someone may be guessing your password; consider resetting it.\n",
+			username, until.UTC().Format("2006-01-02 15:04 MST")),
+	}
+}
@@ -0,0 +1,173 @@
+package server
+
+import (
+	"context"
+	"log"
+	"net"
+	"strconv"
+	"time"
+
+	"go-microservices/pkg/grpcx"
+	pb "go-microservices/proto/auth"
+	"go-microservices/services/auth-service/internal/models"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+const (
+	// loginDelayBase is the wait imposed after the first failed sign-in; it
+	// doubles with every further failure up to loginDelayMax.
+	loginDelayBase = time.Second
+	loginDelayMax  = 30 * time.Second
+)
+
+// UnlockAccount lifts a sign-in lockout early and forgets the account's
+// failed attempts. Callers are expected to have checked accounts:unlock.
+func (s *AuthServer) UnlockAccount(ctx context.Context, req *pb.UnlockAccountRequest) (*pb.UnlockAccountResponse, error) {
+	u64, err := strconv.ParseUint(req.UserId, 10, 64)
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid user id: %v", err)
+	}
+	if _, err := s.repo.GetAuthByID(ctx, uint(u64)); err != nil {
+		return nil, status.Errorf(codes.NotFound, "user not found")
+	}
+	if err := s.repo.ResetLoginFailures(ctx, uint(u64)); err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to unlock account: %v", err)
+	}
+	return &pb.UnlockAccountResponse{Success: true, Message: "account unlocked"}, nil
+}
+
+// resetLoginFailures forgets the account's failed sign-ins after a
+// successful one.
+func (s *AuthServer) resetLoginFailures(ctx context.Context, auth *models.Auth) error {
+	if auth.FailedLoginCount == 0 && auth.LockedUntil == nil {
+		return nil
+	}
+	if err := s.repo.ResetLoginFailures(ctx, auth.ID); err != nil {
+		return status.Errorf(codes.Internal, "failed to reset sign-in attempts: %v", err)
+	}
+	return nil
+}
+
+// clientIP returns the end user's IP as forwarded by the gateway. The
+// forwarded address is only believed on calls authenticated as coming from
+// the gateway; for any other call it is the address of the peer itself.
+func clientIP(ctx context.Context) string {
+	if grpcx.FromGateway(ctx) {
+		md, _ := metadata.FromIncomingContext(ctx)
+		if ips := md.Get("x-client-ip"); len(ips) > 0 && ips[0] != "" {
+			return ips[0]
+		}
+	}
+	p, ok := peer.FromContext(ctx)
+	if !ok || p.Addr == nil {
+		return ""
+	}
+	if host, _, err := net.SplitHostPort(p.Addr.String()); err == nil {
+		return host
+	}
+	return p.Addr.String()
+}
+
+// loginDelay is how long an account must wait after its failures-th
+// consecutive failed sign-in.
+func loginDelay(failures int) time.Duration {
+	if failures <= 0 {
+		return 0
+	}
+	d := loginDelayBase
+	for i := 1; i < failures && d < loginDelayMax; i++ {
+		d *= 2
+	}
+	if d > loginDelayMax {
+		d = loginDelayMax
+	}
+	return d
+}
+
+// tooManyAttempts reports a throttled sign-in with a RetryInfo detail so the
+// gateway can set Retry-After.
+func tooManyAttempts(msg string, retry time.Duration) error {
+	st := status.New(codes.ResourceExhausted, msg)
+	if withRetry, err := st.WithDetails(&errdetails.RetryInfo{RetryDelay: durationpb.New(retry.Round(time.Second))}); err == nil {
+		st = withRetry
+	}
+	return st.Err()
+}
+
+// checkIPThrottle refuses sign-ins from an IP blocked for too many failures.
+func (s *AuthServer) checkIPThrottle(ctx context.Context, ip string) error {
+	if ip == "" {
+		return nil
+	}
+	f, err := s.repo.GetLoginIPFailure(ctx, ip)
+	if err != nil {
+		return status.Errorf(codes.Internal, "failed to check sign-in attempts: %v", err)
+	}
+	if f != nil && f.BlockedUntil != nil && time.Now().Before(*f.BlockedUntil) {
+		return tooManyAttempts("too many failed sign-in attempts", time.Until(*f.BlockedUntil))
+	}
+	return nil
+}
+
+// checkAccountThrottle refuses sign-ins to a locked account, or one whose
+// last failure is more recent than its progressive delay.
+func (s *AuthServer) checkAccountThrottle(auth *models.Auth) error {
+	now := time.Now()
+	if auth.LockedUntil != nil && now.Before(*auth.LockedUntil) {
+		return tooManyAttempts("account temporarily locked", auth.LockedUntil.Sub(now))
+	}
+	if auth.LastFailedLoginAt == nil || now.Sub(*auth.LastFailedLoginAt) > s.env.LockoutDuration {
+		return nil
+	}
+	if next := auth.LastFailedLoginAt.Add(loginDelay(auth.FailedLoginCount)); now.Before(next) {
+		return tooManyAttempts("too many failed sign-in attempts", next.Sub(now))
+	}
+	return nil
+}
+
+// recordFailedSignIn counts a failed sign-in against the client IP and, when
+// the email matched an account, against that account, locking either once it
+// reaches its threshold. Tracking errors are logged rather than returned so
+// the caller still gets "invalid credentials".
+func (s *AuthServer) recordFailedSignIn(ctx context.Context, auth *models.Auth, ip string) {
+	now := time.Now()
+	windowStart := now.Add(-s.env.LockoutDuration)
+	until := now.Add(s.env.LockoutDuration)
+
+	if ip != "" {
+		failures, err := s.repo.RecordLoginIPFailure(ctx, ip, windowStart)
+		if err != nil {
+			log.Printf("failed to record sign-in failure for %s: %v", ip, err)
+		} else if s.env.IPLockoutThreshold > 0 && failures >= s.env.IPLockoutThreshold {
+			if err := s.repo.BlockLoginIP(ctx, ip, until); err != nil {
+				log.Printf("failed to block %s: %v", ip, err)
+			}
+		}
+	}
+
+	if auth == nil {
+		return
+	}
+	failures, err := s.repo.RecordLoginFailure(ctx, auth.ID, windowStart)
+	if err != nil {
+		log.Printf("failed to record sign-in failure for account %d: %v", auth.ID, err)
+		return
+	}
+	if s.env.LockoutThreshold <= 0 || failures < s.env.LockoutThreshold {
+		return
+	}
+	locked, err := s.repo.LockAuth(ctx, auth.ID, until)
+	if err != nil {
+		log.Printf("failed to lock account %d: %v", auth.ID, err)
+		return
+	}
+	if locked {
+		s.sendMail(ctx, accountLockedEmail(auth.Email, auth.Username, until))
+	}
+}
@@ -0,0 +1,37 @@
+package server
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"go-microservices/pkg/grpcx/grpcxtest"
+
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+)
+
+func TestClientIPOnlyTrustsTheGateway(t *testing.T) {
+	withPeer := func(ctx context.Context) context.Context {
+		return peer.NewContext(ctx, &peer.Peer{Addr: &net.TCPAddr{IP: net.ParseIP("10.0.0.5"), Port: 41234}})
+	}
+	forwarded := metadata.Pairs("x-client-ip", "203.0.113.7")
+
+	tests := []struct {
+		name string
+		ctx  context.Context
+		want string
+	}{
+		{"forwarded by the gateway", withPeer(grpcxtest.FromGateway(forwarded)), "203.0.113.7"},
+		{"gateway without a forwarded address", withPeer(grpcxtest.FromGateway(metadata.MD{})), "10.0.0.5"},
+		{"forged by another caller", withPeer(metadata.NewIncomingContext(context.Background(), forwarded)), "10.0.0.5"},
+		{"no peer", context.Background(), ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := clientIP(tt.ctx); got != tt.want {
+				t.Fatalf("clientIP = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,129 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	pb "go-microservices/proto/auth"
+	"go-microservices/services/auth-service/internal/models"
+	"go-microservices/services/auth-service/internal/utils"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// magicLinkTTL is how long an emailed sign-in link stays valid.
+const magicLinkTTL = 15 * time.Minute
+
+// RequestMagicLink emails a single-use sign-in link. It always reports
+// success so the response does not reveal whether the email is registered,
+// or whether the address has hit its request limit.
+func (s *AuthServer) RequestMagicLink(ctx context.Context, req *pb.RequestMagicLinkRequest) (*pb.RequestMagicLinkResponse, error) {
+	if req.Email == "" {
+		return nil, status.Errorf(codes.InvalidArgument, "email required")
+	}
+	resp := &pb.RequestMagicLinkResponse{
+		Success: true,
+		Message: "if the address is registered, a sign-in link has been sent",
+	}
+
+	auth, err := s.repo.GetAuthByEmail(ctx, req.Email)
+	if err != nil || auth == nil {
+		return resp, nil
+	}
+
+	token := utils.GenerateRandomToken()
+	now := time.Now()
+	created, err := s.repo.CreateMagicLink(ctx, &models.MagicLink{
+		TokenHash: utils.HashToken(token),
+		AuthID:    auth.ID,
+		Email:     auth.Email,
+		ExpiresAt: now.Add(magicLinkTTL),
+	}, s.env.MagicLinkLimit, now.Add(-s.env.MagicLinkWindow))
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to issue sign-in link: %v", err)
+	}
+	if !created {
+		log.Printf("sign-in link limit reached for account %d", auth.ID)
+		return resp, nil
+	}
+
+	s.sendMail(ctx, magicLinkEmail(auth.Email, auth.Username, s.frontendLink("/magic-link", token)))
+	return resp, nil
+}
+
+// ConsumeMagicLink signs in with a link from RequestMagicLink. The link is
+// only honoured while the account still has the address it was sent to, and
+// opening it confirms that address. Accounts with a second factor must still
+// complete VerifyMFA.
+func (s *AuthServer) ConsumeMagicLink(ctx context.Context, req *pb.ConsumeMagicLinkRequest) (*pb.ConsumeMagicLinkResponse, error) {
+	if req.Token == "" {
+		return nil, status.Errorf(codes.InvalidArgument, "token required")
+	}
+
+	ip := clientIP(ctx)
+	if err := s.checkIPThrottle(ctx, ip); err != nil {
+		return nil, err
+	}
+
+	hash := utils.HashToken(req.Token)
+	link, err := s.repo.GetMagicLink(ctx, hash)
+	if err != nil || link.UsedAt != nil || time.Now().After(link.ExpiresAt) {
+		s.recordFailedSignIn(ctx, nil, ip)
+		return nil, status.Errorf(codes.Unauthenticated, "invalid or expired link")
+	}
+	auth, err := s.repo.GetAuthByID(ctx, link.AuthID)
+	if err != nil || auth.Email != link.Email {
+		return nil, status.Errorf(codes.Unauthenticated, "invalid or expired link")
+	}
+	if err := s.checkAccountThrottle(auth); err != nil {
+		return nil, err
+	}
+
+	used, err := s.repo.UseMagicLink(ctx, hash)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to consume sign-in link: %v", err)
+	}
+	if !used {
+		return nil, status.Errorf(codes.Unauthenticated, "invalid or expired link")
+	}
+
+	if !auth.EmailVerified {
+		auth.EmailVerified = true
+		auth.VerificationToken = ""
+		auth.VerificationTokenExpiry = time.Time{}
+		if err := s.repo.SaveAuth(ctx, auth); err != nil {
+			return nil, status.Errorf(codes.Internal, "failed to confirm email: %v", err)
+		}
+	}
+
+	userID := fmt.Sprintf("%d", auth.ID)
+	if auth.TOTPEnabled {
+		token, err := s.createMFAChallenge(ctx, auth.ID, amrOTP)
+		if err != nil {
+			return nil, err
+		}
+		return &pb.ConsumeMagicLinkResponse{
+			UserId:      userID,
+			Message:     "second factor required",
+			MfaRequired: true,
+			MfaToken:    token,
+		}, nil
+	}
+
+	if err := s.resetLoginFailures(ctx, auth); err != nil {
+		return nil, err
+	}
+	accessToken, refreshToken, err := s.startSession(ctx, auth, amrOTP, false)
+	if err != nil {
+		return nil, err
+	}
+	return &pb.ConsumeMagicLinkResponse{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+		UserId:       userID,
+		Message:      "logged in",
+	}, nil
+}
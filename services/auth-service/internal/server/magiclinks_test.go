@@ -0,0 +1,88 @@
+package server
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	pb "go-microservices/proto/auth"
+	"go-microservices/services/auth-service/internal/totp"
+)
+
+// amrOf returns the amr claim of an access token, space-separated.
+func amrOf(t *testing.T, srv *AuthServer, token string) string {
+	t.Helper()
+	resp, err := srv.ValidateToken(context.Background(), &pb.ValidateTokenRequest{Token: token})
+	if err != nil || !resp.Valid {
+		t.Fatalf("ValidateToken: %v %+v", err, resp)
+	}
+	return strings.Join(resp.Amr, " ")
+}
+
+// refreshedAMR exchanges refreshToken and returns the new access token's amr.
+func refreshedAMR(t *testing.T, srv *AuthServer, refreshToken string) string {
+	t.Helper()
+	resp, err := srv.RefreshToken(context.Background(), &pb.RefreshTokenRequest{RefreshToken: refreshToken})
+	if err != nil {
+		t.Fatalf("RefreshToken: %v", err)
+	}
+	return amrOf(t, srv, resp.AccessToken)
+}
+
+func TestMagicLinkSignInReportsOTP(t *testing.T) {
+	srv, mail, _ := newDBTestServer(t)
+	ctx := context.Background()
+	if _, err := srv.SignUp(ctx, &pb.SignUpRequest{Username: "jane", Email: mfaEmail, Password: mfaPassword}); err != nil {
+		t.Fatalf("SignUp: %v", err)
+	}
+
+	if _, err := srv.RequestMagicLink(ctx, &pb.RequestMagicLinkRequest{Email: mfaEmail}); err != nil {
+		t.Fatalf("RequestMagicLink: %v", err)
+	}
+	resp, err := srv.ConsumeMagicLink(ctx, &pb.ConsumeMagicLinkRequest{Token: emailedToken(t, mail, mfaEmail)})
+	if err != nil {
+		t.Fatalf("ConsumeMagicLink: %v", err)
+	}
+	if got := amrOf(t, srv, resp.AccessToken); got != "otp" {
+		t.Fatalf("expected amr otp, got %q", got)
+	}
+	if got := refreshedAMR(t, srv, resp.RefreshToken); got != "otp" {
+		t.Fatalf("expected a refreshed token to keep amr otp, got %q", got)
+	}
+}
+
+func TestSecondFactorKeepsFirstFactorInAMR(t *testing.T) {
+	srv, mail, _ := newDBTestServer(t)
+	ctx := context.Background()
+	secret, _ := enrollTOTP(t, srv)
+	code, _ := totp.Code(secret, totp.Step(time.Now()))
+	next, _ := totp.Code(secret, totp.Step(time.Now())+1)
+
+	resp, err := srv.VerifyMFA(ctx, &pb.VerifyMFARequest{MfaToken: mfaToken(t, srv), Code: code})
+	if err != nil {
+		t.Fatalf("VerifyMFA: %v", err)
+	}
+	if got := refreshedAMR(t, srv, resp.RefreshToken); got != "pwd mfa" {
+		t.Fatalf("expected amr pwd mfa after a password sign-in, got %q", got)
+	}
+
+	if _, err := srv.RequestMagicLink(ctx, &pb.RequestMagicLinkRequest{Email: mfaEmail}); err != nil {
+		t.Fatalf("RequestMagicLink: %v", err)
+	}
+	link, err := srv.ConsumeMagicLink(ctx, &pb.ConsumeMagicLinkRequest{Token: emailedToken(t, mail, mfaEmail)})
+	if err != nil {
+		t.Fatalf("ConsumeMagicLink: %v", err)
+	}
+	if !link.MfaRequired {
+		t.Fatal("expected the magic link to require a second factor")
+	}
+	// The next step's code, since the current one was used above.
+	resp, err = srv.VerifyMFA(ctx, &pb.VerifyMFARequest{MfaToken: link.MfaToken, Code: next})
+	if err != nil {
+		t.Fatalf("VerifyMFA after magic link: %v", err)
+	}
+	if got := refreshedAMR(t, srv, resp.RefreshToken); got != "otp mfa" {
+		t.Fatalf("expected amr otp mfa after a magic link sign-in, got %q", got)
+	}
+}
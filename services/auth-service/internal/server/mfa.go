@@ -0,0 +1,227 @@
+package server
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	pb "go-microservices/proto/auth"
+	"go-microservices/services/auth-service/internal/models"
+	"go-microservices/services/auth-service/internal/totp"
+	"go-microservices/services/auth-service/internal/utils"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	// mfaChallengeTTL is how long the second step of a sign-in may take.
+	mfaChallengeTTL = 5 * time.Minute
+	// recoveryCodeCount is how many recovery codes an enrollment hands out.
+	recoveryCodeCount = 10
+)
+
+// BeginTOTPEnrollment generates a new TOTP secret for the account. It only
+// takes effect once ConfirmTOTPEnrollment proves the authenticator app holds
+// it, so an abandoned enrollment never locks the user out.
+func (s *AuthServer) BeginTOTPEnrollment(ctx context.Context, req *pb.BeginTOTPEnrollmentRequest) (*pb.BeginTOTPEnrollmentResponse, error) {
+	if err := rejectImpersonation(ctx); err != nil {
+		return nil, err
+	}
+	auth, err := s.authByUserID(ctx, req.UserId)
+	if err != nil {
+		return nil, err
+	}
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to generate secret: %v", err)
+	}
+	auth.TOTPPendingSecret = secret
+	if err := s.repo.SaveAuth(ctx, auth); err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to save enrollment: %v", err)
+	}
+
+	return &pb.BeginTOTPEnrollmentResponse{
+		Secret:     secret,
+		OtpauthUri: totp.URI(s.env.TOTPIssuer, auth.Email, secret),
+	}, nil
+}
+
+// ConfirmTOTPEnrollment enables TOTP once the user proves their app
+// generates valid codes, and returns a fresh set of recovery codes.
+// Re-enrolling replaces the previous secret and recovery codes.
+func (s *AuthServer) ConfirmTOTPEnrollment(ctx context.Context, req *pb.ConfirmTOTPEnrollmentRequest) (*pb.ConfirmTOTPEnrollmentResponse, error) {
+	if err := rejectImpersonation(ctx); err != nil {
+		return nil, err
+	}
+	auth, err := s.authByUserID(ctx, req.UserId)
+	if err != nil {
+		return nil, err
+	}
+	if auth.TOTPPendingSecret == "" {
+		return nil, status.Errorf(codes.FailedPrecondition, "no TOTP enrollment in progress")
+	}
+
+	step, ok := totp.Validate(auth.TOTPPendingSecret, req.Code, time.Now())
+	if !ok {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid code")
+	}
+
+	recoveryCodes := make([]string, recoveryCodeCount)
+	hashes := make([]string, recoveryCodeCount)
+	for i := range recoveryCodes {
+		code, err := newRecoveryCode()
+		if err != nil {
+			return nil, status.Errorf(codes.Internal, "failed to generate recovery codes: %v", err)
+		}
+		recoveryCodes[i] = code
+		hashes[i] = utils.HashToken(normalizeRecoveryCode(code))
+	}
+
+	if err := s.repo.EnableTOTP(ctx, auth.ID, auth.TOTPPendingSecret, step, hashes); err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to enable TOTP: %v", err)
+	}
+	return &pb.ConfirmTOTPEnrollmentResponse{
+		Success:       true,
+		Message:       "two-factor authentication enabled",
+		RecoveryCodes: recoveryCodes,
+	}, nil
+}
+
+// VerifyMFA completes a sign-in started by SignIn with a TOTP code or an
+// unused recovery code. Wrong codes count as failed sign-ins, so guessing is
+// throttled and eventually locks the account.
+func (s *AuthServer) VerifyMFA(ctx context.Context, req *pb.VerifyMFARequest) (*pb.VerifyMFAResponse, error) {
+	if req.MfaToken == "" {
+		return nil, status.Errorf(codes.InvalidArgument, "mfa token required")
+	}
+	if req.Code == "" && req.RecoveryCode == "" {
+		return nil, status.Errorf(codes.InvalidArgument, "code or recovery code required")
+	}
+
+	ip := clientIP(ctx)
+	if err := s.checkIPThrottle(ctx, ip); err != nil {
+		return nil, err
+	}
+
+	hash := utils.HashToken(req.MfaToken)
+	challenge, err := s.repo.GetMFAChallenge(ctx, hash)
+	if err != nil || time.Now().After(challenge.ExpiresAt) {
+		return nil, status.Errorf(codes.Unauthenticated, "invalid or expired mfa token")
+	}
+	auth, err := s.repo.GetAuthByID(ctx, challenge.AuthID)
+	if err != nil {
+		return nil, status.Errorf(codes.Unauthenticated, "invalid or expired mfa token")
+	}
+	if err := s.checkAccountThrottle(auth); err != nil {
+		return nil, err
+	}
+	if !auth.TOTPEnabled || auth.TOTPSecret == "" {
+		return nil, status.Errorf(codes.FailedPrecondition, "two-factor authentication is not enabled")
+	}
+
+	ok, err := s.checkSecondFactor(ctx, auth, req)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		s.recordFailedSignIn(ctx, auth, ip)
+		return nil, status.Errorf(codes.Unauthenticated, "invalid code")
+	}
+
+	consumed, err := s.repo.ConsumeMFAChallenge(ctx, hash)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to consume mfa token: %v", err)
+	}
+	if !consumed {
+		return nil, status.Errorf(codes.Unauthenticated, "invalid or expired mfa token")
+	}
+	if err := s.resetLoginFailures(ctx, auth); err != nil {
+		return nil, err
+	}
+
+	accessToken, refreshToken, err := s.startSession(ctx, auth, challenge.Method, true)
+	if err != nil {
+		return nil, err
+	}
+	return &pb.VerifyMFAResponse{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+		UserId:       fmt.Sprintf("%d", auth.ID),
+		Message:      "logged in",
+	}, nil
+}
+
+// checkSecondFactor verifies the TOTP or recovery code in req, consuming it
+// so it cannot be used again.
+func (s *AuthServer) checkSecondFactor(ctx context.Context, auth *models.Auth, req *pb.VerifyMFARequest) (bool, error) {
+	if req.RecoveryCode != "" {
+		used, err := s.repo.UseRecoveryCode(ctx, auth.ID, utils.HashToken(normalizeRecoveryCode(req.RecoveryCode)))
+		if err != nil {
+			return false, status.Errorf(codes.Internal, "failed to check recovery code: %v", err)
+		}
+		return used, nil
+	}
+
+	step, ok := totp.Validate(auth.TOTPSecret, req.Code, time.Now())
+	if !ok {
+		return false, nil
+	}
+	accepted, err := s.repo.AcceptTOTPStep(ctx, auth.ID, step)
+	if err != nil {
+		return false, status.Errorf(codes.Internal, "failed to check code: %v", err)
+	}
+	return accepted, nil
+}
+
+// createMFAChallenge returns the token SignIn and ConsumeMagicLink hand out
+// when a second factor is required; method is the first factor already
+// passed. Only its hash is stored.
+func (s *AuthServer) createMFAChallenge(ctx context.Context, authID uint, method string) (string, error) {
+	token := utils.GenerateRandomToken()
+	challenge := &models.MFAChallenge{
+		TokenHash: utils.HashToken(token),
+		AuthID:    authID,
+		Method:    method,
+		ExpiresAt: time.Now().Add(mfaChallengeTTL),
+	}
+	if err := s.repo.CreateMFAChallenge(ctx, challenge); err != nil {
+		return "", status.Errorf(codes.Internal, "failed to create mfa challenge: %v", err)
+	}
+	return token, nil
+}
+
+// authByUserID loads the account named by a user ID taken from a request.
+func (s *AuthServer) authByUserID(ctx context.Context, userID string) (*models.Auth, error) {
+	u64, err := strconv.ParseUint(userID, 10, 64)
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid user id: %v", err)
+	}
+	auth, err := s.repo.GetAuthByID(ctx, uint(u64))
+	if err != nil {
+		return nil, status.Errorf(codes.NotFound, "user not found")
+	}
+	return auth, nil
+}
+
+// newRecoveryCode returns a random code formatted as two groups of five hex
+// digits for readability.
+func newRecoveryCode() (string, error) {
+	b := make([]byte, 5)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	h := hex.EncodeToString(b)
+	return h[:5] + "-" + h[5:], nil
+}
+
+// normalizeRecoveryCode makes entry forgiving of case, dashes and spaces.
+func normalizeRecoveryCode(code string) string {
+	code = strings.ToLower(code)
+	return strings.NewReplacer("-", "", " ", "").Replace(code)
+}
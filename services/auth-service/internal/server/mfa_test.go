@@ -0,0 +1,105 @@
+package server
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+
+	pb "go-microservices/proto/auth"
+	"go-microservices/services/auth-service/internal/totp"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	mfaEmail    = "jane@example.com"
+	mfaPassword = "correct horse battery staple"
+)
+
+// enrollTOTP signs up an account and enables TOTP on it, confirming with the
+// previous step's code so the current one is still unused. It returns the
+// secret and recovery codes.
+func enrollTOTP(t *testing.T, srv *AuthServer) (string, []string) {
+	t.Helper()
+	ctx := context.Background()
+	user, err := srv.SignUp(ctx, &pb.SignUpRequest{Username: "jane", Email: mfaEmail, Password: mfaPassword})
+	if err != nil {
+		t.Fatalf("SignUp: %v", err)
+	}
+	begin, err := srv.BeginTOTPEnrollment(ctx, &pb.BeginTOTPEnrollmentRequest{UserId: user.UserId})
+	if err != nil {
+		t.Fatalf("BeginTOTPEnrollment: %v", err)
+	}
+	code, _ := totp.Code(begin.Secret, totp.Step(time.Now())-1)
+	confirm, err := srv.ConfirmTOTPEnrollment(ctx, &pb.ConfirmTOTPEnrollmentRequest{UserId: user.UserId, Code: code})
+	if err != nil {
+		t.Fatalf("ConfirmTOTPEnrollment: %v", err)
+	}
+	return begin.Secret, confirm.RecoveryCodes
+}
+
+// mfaToken signs in with the password and returns the MFA challenge token.
+func mfaToken(t *testing.T, srv *AuthServer) string {
+	t.Helper()
+	resp, err := srv.SignIn(context.Background(), &pb.SignInRequest{Email: mfaEmail, Password: mfaPassword})
+	if err != nil {
+		t.Fatalf("SignIn: %v", err)
+	}
+	if !resp.MfaRequired || resp.MfaToken == "" {
+		t.Fatal("expected SignIn to require a second factor")
+	}
+	return resp.MfaToken
+}
+
+func TestVerifyMFARejectsReplayedCode(t *testing.T) {
+	srv, _, _ := newDBTestServer(t)
+	ctx := context.Background()
+	secret, _ := enrollTOTP(t, srv)
+
+	code, _ := totp.Code(secret, totp.Step(time.Now()))
+	if _, err := srv.VerifyMFA(ctx, &pb.VerifyMFARequest{MfaToken: mfaToken(t, srv), Code: code}); err != nil {
+		t.Fatalf("VerifyMFA: %v", err)
+	}
+	if _, err := srv.VerifyMFA(ctx, &pb.VerifyMFARequest{MfaToken: mfaToken(t, srv), Code: code}); status.Code(err) != codes.Unauthenticated {
+		t.Fatalf("expected a replayed code to be rejected, got %v", err)
+	}
+}
+
+func TestVerifyMFARecoveryCodesWorkOnce(t *testing.T) {
+	srv, _, _ := newDBTestServer(t)
+	ctx := context.Background()
+	_, recovery := enrollTOTP(t, srv)
+
+	if _, err := srv.VerifyMFA(ctx, &pb.VerifyMFARequest{MfaToken: mfaToken(t, srv), RecoveryCode: recovery[0]}); err != nil {
+		t.Fatalf("VerifyMFA with recovery code: %v", err)
+	}
+	if _, err := srv.VerifyMFA(ctx, &pb.VerifyMFARequest{MfaToken: mfaToken(t, srv), RecoveryCode: recovery[1]}); err != nil {
+		t.Fatalf("VerifyMFA with another recovery code: %v", err)
+	}
+	// Checked last: the failure delays the account's next sign-in.
+	if _, err := srv.VerifyMFA(ctx, &pb.VerifyMFARequest{MfaToken: mfaToken(t, srv), RecoveryCode: recovery[0]}); status.Code(err) != codes.Unauthenticated {
+		t.Fatalf("expected a used recovery code to be rejected, got %v", err)
+	}
+}
+
+func TestVerifyMFARequiresEnabledTOTP(t *testing.T) {
+	srv, _, _ := newDBTestServer(t)
+	ctx := context.Background()
+	user, err := srv.SignUp(ctx, &pb.SignUpRequest{Username: "jane", Email: mfaEmail, Password: mfaPassword})
+	if err != nil {
+		t.Fatalf("SignUp: %v", err)
+	}
+	id, _ := strconv.ParseUint(user.UserId, 10, 64)
+	token, err := srv.createMFAChallenge(ctx, uint(id), amrPassword)
+	if err != nil {
+		t.Fatalf("createMFAChallenge: %v", err)
+	}
+
+	// The code an empty secret would produce must not get anyone in.
+	code, _ := totp.Code("", totp.Step(time.Now()))
+	if _, err := srv.VerifyMFA(ctx, &pb.VerifyMFARequest{MfaToken: token, Code: code}); status.Code(err) != codes.FailedPrecondition {
+		t.Fatalf("expected FailedPrecondition without TOTP enabled, got %v", err)
+	}
+}
@@ -0,0 +1,584 @@
+package server
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	pb "go-microservices/proto/auth"
+	"go-microservices/services/auth-service/internal/models"
+	"go-microservices/services/auth-service/internal/rbac"
+	"go-microservices/services/auth-service/internal/utils"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	// authorizationCodeTTL is how long a client has to redeem a code.
+	authorizationCodeTTL = time.Minute
+	// clientSecretPrefix marks OAuth client secrets for secret scanners.
+	clientSecretPrefix = "gmsc_"
+)
+
+// Supported OAuth grant types.
+const (
+	grantAuthorizationCode = "authorization_code"
+	grantClientCredentials = "client_credentials"
+)
+
+// OpenID Connect scopes. Every other scope names a permission.
+const (
+	scopeOpenID  = "openid"
+	scopeProfile = "profile"
+	scopeEmail   = "email"
+)
+
+var oidcScopes = []string{scopeOpenID, scopeProfile, scopeEmail}
+
+// CreateOAuthClient registers an application. Registered clients are trusted
+// first-party apps: users who are signed in are not asked for consent.
+// Callers are expected to have checked clients:manage.
+func (s *AuthServer) CreateOAuthClient(ctx context.Context, req *pb.CreateOAuthClientRequest) (*pb.CreateOAuthClientResponse, error) {
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		return nil, status.Errorf(codes.InvalidArgument, "name required")
+	}
+	if len(req.GrantTypes) == 0 {
+		return nil, status.Errorf(codes.InvalidArgument, "at least one grant type required")
+	}
+	for _, grant := range req.GrantTypes {
+		switch grant {
+		case grantAuthorizationCode:
+			if len(req.RedirectUris) == 0 {
+				return nil, status.Errorf(codes.InvalidArgument, "authorization_code clients need a redirect URI")
+			}
+		case grantClientCredentials:
+			if req.Public {
+				return nil, status.Errorf(codes.InvalidArgument, "public clients cannot use client_credentials")
+			}
+		default:
+			return nil, status.Errorf(codes.InvalidArgument, "unsupported grant type %q", grant)
+		}
+	}
+	for _, uri := range req.RedirectUris {
+		if !validRedirectURI(uri) {
+			return nil, status.Errorf(codes.InvalidArgument, "invalid redirect URI %q: use https, or http on localhost", uri)
+		}
+	}
+	if len(req.Scopes) == 0 {
+		return nil, status.Errorf(codes.InvalidArgument, "at least one scope required")
+	}
+	known, err := s.knownScopes(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, scope := range req.Scopes {
+		if !containsString(known, scope) {
+			return nil, status.Errorf(codes.InvalidArgument, "unknown scope %q", scope)
+		}
+	}
+
+	client := &models.OAuthClient{
+		ID:           utils.GenerateRandomToken(),
+		Name:         name,
+		RedirectURIs: req.RedirectUris,
+		GrantTypes:   req.GrantTypes,
+		Scopes:       req.Scopes,
+	}
+	var secret string
+	if !req.Public {
+		if secret, err = newSecret(clientSecretPrefix); err != nil {
+			return nil, status.Errorf(codes.Internal, "failed to generate client secret: %v", err)
+		}
+		client.SecretHash = utils.HashToken(secret)
+	}
+	if err := s.repo.CreateOAuthClient(ctx, client); err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to store client: %v", err)
+	}
+	return &pb.CreateOAuthClientResponse{ClientSecret: secret, Client: oauthClientToProto(client)}, nil
+}
+
+func (s *AuthServer) ListOAuthClients(ctx context.Context, req *pb.ListOAuthClientsRequest) (*pb.ListOAuthClientsResponse, error) {
+	clients, err := s.repo.ListOAuthClients(ctx)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to list clients: %v", err)
+	}
+	resp := &pb.ListOAuthClientsResponse{Clients: make([]*pb.OAuthClient, 0, len(clients))}
+	for i := range clients {
+		resp.Clients = append(resp.Clients, oauthClientToProto(&clients[i]))
+	}
+	return resp, nil
+}
+
+// DeleteOAuthClient removes a client. Access tokens already issued to it stay
+// valid until they expire.
+func (s *AuthServer) DeleteOAuthClient(ctx context.Context, req *pb.DeleteOAuthClientRequest) (*pb.DeleteOAuthClientResponse, error) {
+	deleted, err := s.repo.DeleteOAuthClient(ctx, req.ClientId)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to delete client: %v", err)
+	}
+	if !deleted {
+		return nil, status.Errorf(codes.NotFound, "client not found")
+	}
+	return &pb.DeleteOAuthClientResponse{Success: true, Message: "client deleted"}, nil
+}
+
+// GetOpenIDConfiguration returns the discovery document. The endpoints are
+// served by the gateway under the configured issuer.
+func (s *AuthServer) GetOpenIDConfiguration(ctx context.Context, req *pb.GetOpenIDConfigurationRequest) (*pb.GetOpenIDConfigurationResponse, error) {
+	scopes, err := s.knownScopes(ctx)
+	if err != nil {
+		return nil, err
+	}
+	issuer := s.issuer()
+	resp := &pb.GetOpenIDConfigurationResponse{
+		Issuer:                            issuer,
+		AuthorizationEndpoint:             issuer + "/authorize",
+		TokenEndpoint:                     issuer + "/token",
+		UserinfoEndpoint:                  issuer + "/userinfo",
+		JwksUri:                           issuer + "/.well-known/jwks.json",
+		ResponseTypesSupported:            []string{"code"},
+		GrantTypesSupported:               []string{grantAuthorizationCode, grantClientCredentials},
+		SubjectTypesSupported:             []string{"public"},
+		ScopesSupported:                   scopes,
+		TokenEndpointAuthMethodsSupported: []string{"client_secret_basic", "client_secret_post", "none"},
+		CodeChallengeMethodsSupported:     []string{"S256"},
+		ClaimsSupported:                   []string{"iss", "sub", "aud", "exp", "iat", "nonce", "amr", "email", "email_verified", "preferred_username"},
+		IntrospectionEndpoint:             issuer + "/introspect",
+		IntrospectionEndpointAuthMethodsSupported: []string{"client_secret_basic", "client_secret_post"},
+	}
+	if utils.KeyRing() != nil {
+		resp.IdTokenSigningAlgValuesSupported = []string{s.env.JWTSigningAlg}
+	}
+	return resp, nil
+}
+
+// Authorize issues an authorization code to a client on behalf of the user
+// signed in with req.AccessToken. PKCE with S256 is required of every
+// client. Once the client and redirect URI check out, errors are returned to
+// the client through the redirect as the OAuth spec requires.
+func (s *AuthServer) Authorize(ctx context.Context, req *pb.AuthorizeRequest) (*pb.AuthorizeResponse, error) {
+	client, err := s.repo.GetOAuthClient(ctx, req.ClientId)
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "unknown client")
+	}
+	if !containsString(client.RedirectURIs, req.RedirectUri) {
+		return nil, status.Errorf(codes.InvalidArgument, "redirect_uri is not registered for this client")
+	}
+
+	claims, err := s.sessionClaims(ctx, req.AccessToken)
+	if err != nil {
+		return nil, err
+	}
+	authID, err := strconv.ParseUint(claimString(claims, "sub"), 10, 64)
+	sessionID := claimString(claims, "sid")
+	if err != nil || sessionID == "" {
+		return nil, status.Errorf(codes.Unauthenticated, "sign-in required")
+	}
+
+	fail := func(code, description string) (*pb.AuthorizeResponse, error) {
+		return &pb.AuthorizeResponse{RedirectUri: redirectWith(req.RedirectUri, url.Values{
+			"error":             {code},
+			"error_description": {description},
+			"state":             {req.State},
+		})}, nil
+	}
+	if req.ResponseType != "code" {
+		return fail("unsupported_response_type", "only the code response type is supported")
+	}
+	if !containsString(client.GrantTypes, grantAuthorizationCode) {
+		return fail("unauthorized_client", "client may not use the authorization_code grant")
+	}
+	if req.CodeChallenge == "" || req.CodeChallengeMethod != "S256" {
+		return fail("invalid_request", "PKCE with code_challenge_method S256 is required")
+	}
+	scopes := strings.Fields(req.Scope)
+	if len(scopes) == 0 {
+		return fail("invalid_scope", "scope required")
+	}
+	for _, scope := range scopes {
+		if !containsString(client.Scopes, scope) {
+			return fail("invalid_scope", "scope "+scope+" is not allowed for this client")
+		}
+	}
+	if containsString(scopes, scopeOpenID) && utils.KeyRing() == nil {
+		return fail("invalid_scope", "openid requires asymmetric token signing")
+	}
+
+	code := utils.GenerateRandomToken()
+	record := &models.AuthorizationCode{
+		CodeHash:      utils.HashToken(code),
+		ClientID:      client.ID,
+		AuthID:        uint(authID),
+		SessionID:     sessionID,
+		RedirectURI:   req.RedirectUri,
+		Scope:         strings.Join(scopes, " "),
+		Nonce:         req.Nonce,
+		CodeChallenge: req.CodeChallenge,
+		MFA:           containsString(claimStrings(claims, "amr"), amrMFA),
+		AMR:           strings.Join(claimStrings(claims, "amr"), " "),
+		ExpiresAt:     time.Now().Add(authorizationCodeTTL),
+	}
+	if err := s.repo.CreateAuthorizationCode(ctx, record); err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to store authorization code: %v", err)
+	}
+	return &pb.AuthorizeResponse{RedirectUri: redirectWith(req.RedirectUri, url.Values{
+		"code":  {code},
+		"state": {req.State},
+	})}, nil
+}
+
+// Token implements the token endpoint for the authorization_code and
+// client_credentials grants. OAuth errors are reported in the response;
+// gRPC errors mean the request could not be processed.
+func (s *AuthServer) Token(ctx context.Context, req *pb.TokenRequest) (*pb.TokenResponse, error) {
+	client, err := s.repo.GetOAuthClient(ctx, req.ClientId)
+	if err != nil || !clientSecretMatches(client, req.ClientSecret) {
+		return oauthError("invalid_client", "client authentication failed"), nil
+	}
+
+	switch req.GrantType {
+	case grantAuthorizationCode, grantClientCredentials:
+		if !containsString(client.GrantTypes, req.GrantType) {
+			return oauthError("unauthorized_client", "client may not use the "+req.GrantType+" grant"), nil
+		}
+	default:
+		return oauthError("unsupported_grant_type", "grant_type must be authorization_code or client_credentials"), nil
+	}
+	if req.GrantType == grantClientCredentials {
+		return s.clientCredentialsGrant(client, req)
+	}
+	return s.authorizationCodeGrant(ctx, client, req)
+}
+
+// authorizationCodeGrant redeems a code for an access token and, when the
+// openid scope was granted, an ID token. Permission scopes the user no
+// longer holds are dropped.
+func (s *AuthServer) authorizationCodeGrant(ctx context.Context, client *models.OAuthClient, req *pb.TokenRequest) (*pb.TokenResponse, error) {
+	hash := utils.HashToken(req.Code)
+	code, err := s.repo.GetAuthorizationCode(ctx, hash)
+	if err != nil || code.ClientID != client.ID || time.Now().After(code.ExpiresAt) {
+		return oauthError("invalid_grant", "invalid or expired code"), nil
+	}
+	if req.RedirectUri != code.RedirectURI {
+		return oauthError("invalid_grant", "redirect_uri does not match the authorization request"), nil
+	}
+	if !pkceMatches(req.CodeVerifier, code.CodeChallenge) {
+		return oauthError("invalid_grant", "code_verifier does not match the code challenge"), nil
+	}
+	consumed, err := s.repo.ConsumeAuthorizationCode(ctx, hash)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to redeem code: %v", err)
+	}
+	if !consumed {
+		return oauthError("invalid_grant", "invalid or expired code"), nil
+	}
+
+	// A code outlives neither the session that authorized it nor a
+	// sign-out everywhere, which ends every session.
+	active, err := s.repo.IsSessionActive(ctx, code.SessionID)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to check session: %v", err)
+	}
+	auth, err := s.repo.GetAuthByID(ctx, code.AuthID)
+	if !active || err != nil {
+		return oauthError("invalid_grant", "invalid or expired code"), nil
+	}
+	_, perms, err := rbac.Access(ctx, s.repo, auth.ID, code.MFA)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to load permissions: %v", err)
+	}
+	var granted, grantedPerms []string
+	for _, scope := range strings.Fields(code.Scope) {
+		switch {
+		case containsString(oidcScopes, scope):
+			granted = append(granted, scope)
+		case containsString(perms, scope):
+			granted = append(granted, scope)
+			grantedPerms = append(grantedPerms, scope)
+		}
+	}
+	amr := strings.Fields(code.AMR)
+
+	accessToken, err := utils.GenerateAccessToken(*auth, utils.Grant{
+		Permissions: grantedPerms,
+		AMR:         amr,
+		SessionID:   code.SessionID,
+		ClientID:    client.ID,
+		Scope:       granted,
+	})
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to generate access token: %v", err)
+	}
+	resp := &pb.TokenResponse{
+		AccessToken: accessToken,
+		TokenType:   "Bearer",
+		ExpiresIn:   int64(utils.AccessTokenTTL.Seconds()),
+		Scope:       strings.Join(granted, " "),
+	}
+	if containsString(granted, scopeOpenID) {
+		resp.IdToken, err = utils.GenerateIDToken(*auth, utils.IDToken{
+			Issuer:   s.issuer(),
+			Audience: client.ID,
+			Nonce:    code.Nonce,
+			AMR:      amr,
+			Email:    containsString(granted, scopeEmail),
+			Profile:  containsString(granted, scopeProfile),
+		})
+		if err != nil {
+			return nil, status.Errorf(codes.Internal, "failed to generate ID token: %v", err)
+		}
+	}
+	return resp, nil
+}
+
+// clientCredentialsGrant issues a client an access token for itself. Without
+// a scope parameter it gets every permission scope it is registered for.
+func (s *AuthServer) clientCredentialsGrant(client *models.OAuthClient, req *pb.TokenRequest) (*pb.TokenResponse, error) {
+	if client.SecretHash == "" {
+		return oauthError("unauthorized_client", "public clients cannot use client_credentials"), nil
+	}
+	scopes := strings.Fields(req.Scope)
+	if len(scopes) == 0 {
+		for _, scope := range client.Scopes {
+			if !containsString(oidcScopes, scope) {
+				scopes = append(scopes, scope)
+			}
+		}
+	}
+	for _, scope := range scopes {
+		if containsString(oidcScopes, scope) || !containsString(client.Scopes, scope) {
+			return oauthError("invalid_scope", "scope "+scope+" is not allowed for this client"), nil
+		}
+	}
+
+	accessToken, err := utils.GenerateClientToken(client.ID, utils.Grant{
+		Permissions: scopes,
+		ClientID:    client.ID,
+		Scope:       scopes,
+	})
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to generate access token: %v", err)
+	}
+	return &pb.TokenResponse{
+		AccessToken: accessToken,
+		TokenType:   "Bearer",
+		ExpiresIn:   int64(utils.AccessTokenTTL.Seconds()),
+		Scope:       strings.Join(scopes, " "),
+	}, nil
+}
+
+// GetOpenIDUserInfo returns the claims about the user that the access
+// token's scopes allow. The token must have been granted openid.
+func (s *AuthServer) GetOpenIDUserInfo(ctx context.Context, req *pb.GetOpenIDUserInfoRequest) (*pb.GetOpenIDUserInfoResponse, error) {
+	claims, err := utils.ValidateJWT(req.AccessToken, false)
+	if err != nil {
+		return nil, status.Errorf(codes.Unauthenticated, "invalid token")
+	}
+	revoked, err := s.isRevoked(ctx, claims)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to check revocation: %v", err)
+	}
+	if revoked {
+		return nil, status.Errorf(codes.Unauthenticated, "token revoked")
+	}
+	scopes := strings.Fields(claimString(claims, "scope"))
+	if !containsString(scopes, scopeOpenID) {
+		return nil, status.Errorf(codes.PermissionDenied, "token was not granted the openid scope")
+	}
+	auth, err := s.authByUserID(ctx, claimString(claims, "sub"))
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &pb.GetOpenIDUserInfoResponse{Sub: strconv.FormatUint(uint64(auth.ID), 10)}
+	if containsString(scopes, scopeEmail) {
+		resp.Email = auth.Email
+		resp.EmailVerified = auth.EmailVerified
+	}
+	if containsString(scopes, scopeProfile) {
+		resp.PreferredUsername = auth.Username
+	}
+	return resp, nil
+}
+
+// IntrospectToken reports whether an access or refresh token is active and
+// what it carries (RFC 7662). The caller must authenticate as a confidential
+// client. token_type_hint only decides which kind is tried first.
+func (s *AuthServer) IntrospectToken(ctx context.Context, req *pb.IntrospectTokenRequest) (*pb.IntrospectTokenResponse, error) {
+	client, err := s.repo.GetOAuthClient(ctx, req.ClientId)
+	if err != nil || client.SecretHash == "" || !clientSecretMatches(client, req.ClientSecret) {
+		return &pb.IntrospectTokenResponse{Error: "invalid_client", ErrorDescription: "client authentication failed"}, nil
+	}
+
+	kinds := []bool{false, true}
+	if req.TokenTypeHint == "refresh_token" {
+		kinds = []bool{true, false}
+	}
+	for _, refresh := range kinds {
+		claims, err := s.activeClaims(ctx, req.Token, refresh)
+		if err != nil {
+			return nil, err
+		}
+		if claims == nil {
+			continue
+		}
+		resp := &pb.IntrospectTokenResponse{
+			Active:      true,
+			Scope:       claimString(claims, "scope"),
+			ClientId:    claimString(claims, "client_id"),
+			Exp:         claimUnix(claims, "exp"),
+			Iat:         claimUnix(claims, "iat"),
+			Sub:         claimString(claims, "sub"),
+			Iss:         s.issuer(),
+			Jti:         claimString(claims, "jti"),
+			Sid:         claimString(claims, "sid"),
+			Email:       claimString(claims, "email"),
+			Roles:       claimStrings(claims, "roles"),
+			Permissions: claimStrings(claims, "perms"),
+		}
+		if !refresh {
+			resp.TokenType = "Bearer"
+		}
+		return resp, nil
+	}
+	return &pb.IntrospectTokenResponse{Active: false}, nil
+}
+
+// activeClaims returns the claims of a valid, unrevoked token of the given
+// kind, or nil when it is not one. Refresh tokens must also be the current
+// member of their family.
+func (s *AuthServer) activeClaims(ctx context.Context, token string, refresh bool) (map[string]interface{}, error) {
+	claims, err := utils.ValidateJWT(token, refresh)
+	if err != nil {
+		return nil, nil
+	}
+	revoked, err := s.isRevoked(ctx, claims)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to check revocation: %v", err)
+	}
+	if revoked {
+		return nil, nil
+	}
+	if refresh {
+		stored, err := s.repo.GetRefreshTokenByHash(ctx, utils.HashToken(token))
+		if err != nil || stored.UsedAt != nil || stored.RevokedAt != nil || time.Now().After(stored.ExpiresAt) {
+			return nil, nil
+		}
+	}
+	return claims, nil
+}
+
+// sessionClaims validates the access token of a user signed in directly.
+// Tokens issued to OAuth clients are refused so a client cannot authorize
+// other clients, and impersonation tokens so an admin acting as a user can
+// neither grant consent for them nor impersonate someone else.
+func (s *AuthServer) sessionClaims(ctx context.Context, accessToken string) (map[string]interface{}, error) {
+	claims, err := utils.ValidateJWT(accessToken, false)
+	if err != nil || claimString(claims, "client_id") != "" || claimActor(claims) != "" {
+		return nil, status.Errorf(codes.Unauthenticated, "sign-in required")
+	}
+	revoked, err := s.isRevoked(ctx, claims)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to check revocation: %v", err)
+	}
+	if revoked {
+		return nil, status.Errorf(codes.Unauthenticated, "sign-in required")
+	}
+	return claims, nil
+}
+
+// knownScopes returns the OpenID Connect scopes followed by every permission
+// defined by a role.
+func (s *AuthServer) knownScopes(ctx context.Context) ([]string, error) {
+	defined, err := s.repo.ListRoles(ctx)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to list roles: %v", err)
+	}
+	names := make([]string, len(defined))
+	for i, r := range defined {
+		names[i] = r.Name
+	}
+	return append(append([]string{}, oidcScopes...), rbac.Effective(defined, names)...), nil
+}
+
+func (s *AuthServer) issuer() string {
+	return strings.TrimRight(s.env.OIDCIssuer, "/")
+}
+
+// clientSecretMatches authenticates a client. Public clients have no secret
+// and are identified by their ID alone.
+func clientSecretMatches(client *models.OAuthClient, secret string) bool {
+	if client.SecretHash == "" {
+		return true
+	}
+	return subtle.ConstantTimeCompare([]byte(utils.HashToken(secret)), []byte(client.SecretHash)) == 1
+}
+
+// pkceMatches checks a code verifier against its S256 challenge (RFC 7636).
+func pkceMatches(verifier, challenge string) bool {
+	if len(verifier) < 43 || len(verifier) > 128 {
+		return false
+	}
+	sum := sha256.Sum256([]byte(verifier))
+	computed := base64.RawURLEncoding.EncodeToString(sum[:])
+	return subtle.ConstantTimeCompare([]byte(computed), []byte(challenge)) == 1
+}
+
+// validRedirectURI accepts absolute https URIs without a fragment, and http
+// ones on loopback hosts for development and native apps.
+func validRedirectURI(raw string) bool {
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" || u.Fragment != "" {
+		return false
+	}
+	switch u.Scheme {
+	case "https":
+		return true
+	case "http":
+		host := u.Hostname()
+		return host == "localhost" || host == "127.0.0.1" || host == "::1"
+	}
+	return false
+}
+
+// redirectWith adds the non-empty params to the query of a registered
+// redirect URI.
+func redirectWith(uri string, params url.Values) string {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return uri
+	}
+	q := u.Query()
+	for k, vs := range params {
+		for _, v := range vs {
+			if v != "" {
+				q.Set(k, v)
+			}
+		}
+	}
+	u.RawQuery = q.Encode()
+	return u.String()
+}
+
+func oauthError(code, description string) *pb.TokenResponse {
+	return &pb.TokenResponse{Error: code, ErrorDescription: description}
+}
+
+func oauthClientToProto(c *models.OAuthClient) *pb.OAuthClient {
+	return &pb.OAuthClient{
+		ClientId:     c.ID,
+		Name:         c.Name,
+		RedirectUris: c.RedirectURIs,
+		GrantTypes:   c.GrantTypes,
+		Scopes:       c.Scopes,
+		Public:       c.SecretHash == "",
+		CreatedAt:    c.CreatedAt.Unix(),
+	}
+}
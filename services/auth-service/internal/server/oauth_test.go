@@ -0,0 +1,179 @@
+package server
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"net/url"
+	"strings"
+	"testing"
+
+	pb "go-microservices/proto/auth"
+)
+
+const (
+	testRedirectURI  = "http://localhost:3000/callback"
+	testCodeVerifier = "dBjftJeZ4CVP-mJ92K9fRzL1eG3yY8bH0sJ2aQxW7kEo"
+)
+
+// oauthSetup signs up a user and registers a public authorization_code
+// client. It returns the client ID and the user's sign-in.
+func oauthSetup(t *testing.T, srv *AuthServer) (string, *pb.SignInResponse) {
+	t.Helper()
+	ctx := context.Background()
+	if _, err := srv.SignUp(ctx, &pb.SignUpRequest{Username: "jane", Email: mfaEmail, Password: mfaPassword}); err != nil {
+		t.Fatalf("SignUp: %v", err)
+	}
+	signIn, err := srv.SignIn(ctx, &pb.SignInRequest{Email: mfaEmail, Password: mfaPassword})
+	if err != nil {
+		t.Fatalf("SignIn: %v", err)
+	}
+	client, err := srv.CreateOAuthClient(ctx, &pb.CreateOAuthClientRequest{
+		Name:         "app",
+		RedirectUris: []string{testRedirectURI},
+		GrantTypes:   []string{grantAuthorizationCode},
+		Scopes:       []string{"posts:write"},
+		Public:       true,
+	})
+	if err != nil {
+		t.Fatalf("CreateOAuthClient: %v", err)
+	}
+	return client.Client.ClientId, signIn
+}
+
+// authorizeCode has the user behind accessToken authorize clientID and
+// returns the code, challenged with testCodeVerifier.
+func authorizeCode(t *testing.T, srv *AuthServer, clientID, accessToken string) string {
+	t.Helper()
+	sum := sha256.Sum256([]byte(testCodeVerifier))
+	resp, err := srv.Authorize(context.Background(), &pb.AuthorizeRequest{
+		AccessToken:         accessToken,
+		ResponseType:        "code",
+		ClientId:            clientID,
+		RedirectUri:         testRedirectURI,
+		Scope:               "posts:write",
+		CodeChallenge:       base64.RawURLEncoding.EncodeToString(sum[:]),
+		CodeChallengeMethod: "S256",
+	})
+	if err != nil {
+		t.Fatalf("Authorize: %v", err)
+	}
+	redirect, err := url.Parse(resp.RedirectUri)
+	if err != nil {
+		t.Fatalf("parse redirect: %v", err)
+	}
+	code := redirect.Query().Get("code")
+	if code == "" {
+		t.Fatalf("expected a code, got redirect %s", resp.RedirectUri)
+	}
+	return code
+}
+
+func redeemCode(t *testing.T, srv *AuthServer, clientID, code, redirectURI, verifier string) *pb.TokenResponse {
+	t.Helper()
+	resp, err := srv.Token(context.Background(), &pb.TokenRequest{
+		GrantType:    grantAuthorizationCode,
+		ClientId:     clientID,
+		Code:         code,
+		RedirectUri:  redirectURI,
+		CodeVerifier: verifier,
+	})
+	if err != nil {
+		t.Fatalf("Token: %v", err)
+	}
+	return resp
+}
+
+func TestAuthorizationCodeChecksPKCEAndRedirectURI(t *testing.T) {
+	srv, _, _ := newDBTestServer(t)
+	clientID, signIn := oauthSetup(t, srv)
+	code := authorizeCode(t, srv, clientID, signIn.AccessToken)
+
+	for _, tc := range []struct {
+		name, redirectURI, verifier string
+	}{
+		{"wrong verifier", testRedirectURI, strings.Repeat("a", 43)},
+		{"short verifier", testRedirectURI, "abc"},
+		{"missing verifier", testRedirectURI, ""},
+		{"other redirect URI", "http://localhost:3000/other", testCodeVerifier},
+		{"missing redirect URI", "", testCodeVerifier},
+	} {
+		if resp := redeemCode(t, srv, clientID, code, tc.redirectURI, tc.verifier); resp.Error != "invalid_grant" {
+			t.Fatalf("%s: expected invalid_grant, got %+v", tc.name, resp)
+		}
+	}
+
+	// Failed attempts do not use the code up.
+	resp := redeemCode(t, srv, clientID, code, testRedirectURI, testCodeVerifier)
+	if resp.Error != "" || resp.AccessToken == "" {
+		t.Fatalf("expected an access token, got %+v", resp)
+	}
+}
+
+func TestAuthorizationCodeIsSingleUse(t *testing.T) {
+	srv, _, _ := newDBTestServer(t)
+	clientID, signIn := oauthSetup(t, srv)
+	code := authorizeCode(t, srv, clientID, signIn.AccessToken)
+
+	if resp := redeemCode(t, srv, clientID, code, testRedirectURI, testCodeVerifier); resp.Error != "" {
+		t.Fatalf("Token: %+v", resp)
+	}
+	if resp := redeemCode(t, srv, clientID, code, testRedirectURI, testCodeVerifier); resp.Error != "invalid_grant" {
+		t.Fatalf("expected a redeemed code to be rejected, got %+v", resp)
+	}
+}
+
+func TestAuthorizationCodeTokensEndWithTheSession(t *testing.T) {
+	ctx := context.Background()
+	valid := func(t *testing.T, srv *AuthServer, token string) bool {
+		t.Helper()
+		resp, err := srv.ValidateToken(ctx, &pb.ValidateTokenRequest{Token: token})
+		if err != nil {
+			t.Fatalf("ValidateToken: %v", err)
+		}
+		return resp.Valid
+	}
+
+	t.Run("sign out", func(t *testing.T) {
+		srv, _, _ := newDBTestServer(t)
+		clientID, signIn := oauthSetup(t, srv)
+		token := redeemCode(t, srv, clientID, authorizeCode(t, srv, clientID, signIn.AccessToken), testRedirectURI, testCodeVerifier).AccessToken
+		if !valid(t, srv, token) {
+			t.Fatal("expected the client's token to be valid")
+		}
+		if _, err := srv.SignOut(ctx, &pb.SignOutRequest{RefreshToken: signIn.RefreshToken}); err != nil {
+			t.Fatalf("SignOut: %v", err)
+		}
+		if valid(t, srv, token) {
+			t.Fatal("expected signing out to revoke the client's token")
+		}
+	})
+
+	t.Run("terminate session", func(t *testing.T) {
+		srv, _, _ := newDBTestServer(t)
+		clientID, signIn := oauthSetup(t, srv)
+		token := redeemCode(t, srv, clientID, authorizeCode(t, srv, clientID, signIn.AccessToken), testRedirectURI, testCodeVerifier).AccessToken
+		info, err := srv.ValidateToken(ctx, &pb.ValidateTokenRequest{Token: signIn.AccessToken})
+		if err != nil {
+			t.Fatalf("ValidateToken: %v", err)
+		}
+		if _, err := srv.TerminateSession(ctx, &pb.TerminateSessionRequest{UserId: signIn.UserId, SessionId: info.SessionId}); err != nil {
+			t.Fatalf("TerminateSession: %v", err)
+		}
+		if valid(t, srv, token) {
+			t.Fatal("expected ending the session to revoke the client's token")
+		}
+	})
+
+	t.Run("revoke all sessions", func(t *testing.T) {
+		srv, _, _ := newDBTestServer(t)
+		clientID, signIn := oauthSetup(t, srv)
+		code := authorizeCode(t, srv, clientID, signIn.AccessToken)
+		if _, err := srv.RevokeAllSessions(ctx, &pb.RevokeAllSessionsRequest{UserId: signIn.UserId}); err != nil {
+			t.Fatalf("RevokeAllSessions: %v", err)
+		}
+		if resp := redeemCode(t, srv, clientID, code, testRedirectURI, testCodeVerifier); resp.Error != "invalid_grant" {
+			t.Fatalf("expected a code from an ended session to be rejected, got %+v", resp)
+		}
+	})
+}
@@ -0,0 +1,50 @@
+package server
+
+import (
+	"go-microservices/services/auth-service/config"
+	"go-microservices/services/auth-service/internal/mailer"
+	"go-microservices/services/auth-service/internal/password"
+	"go-microservices/services/auth-service/internal/revocation"
+)
+
+// Option customises an AuthServer built by NewAuthServer.
+type Option func(*AuthServer)
+
+// WithRevocationStore overrides where revoked tokens are recorded. By default
+// revocations are persisted through the repository.
+func WithRevocationStore(store revocation.Store) Option {
+	return func(s *AuthServer) {
+		s.revoked = store
+	}
+}
+
+// WithMailer sets how confirmation and notification emails are delivered.
+// By default messages are kept in memory.
+func WithMailer(m mailer.Mailer) Option {
+	return func(s *AuthServer) {
+		s.mailer = m
+	}
+}
+
+// WithConfig sets the service configuration. By default it is read from the
+// environment.
+func WithConfig(env *config.Env) Option {
+	return func(s *AuthServer) {
+		s.env = env
+	}
+}
+
+// WithBreachedPasswords rejects new passwords that src lists as breached.
+func WithBreachedPasswords(src password.RangeSource) Option {
+	return func(s *AuthServer) {
+		s.breached = src
+	}
+}
+
+// WithPasswordHasher sets how passwords are hashed. By default the algorithm
+// and parameters come from the configuration.
+func WithPasswordHasher(h *password.Hasher) Option {
+	return func(s *AuthServer) {
+		s.hasher = h
+	}
+}
@@ -0,0 +1,80 @@
+package server
+
+import (
+	"context"
+	"log"
+
+	"go-microservices/services/auth-service/config"
+	"go-microservices/services/auth-service/internal/models"
+	"go-microservices/services/auth-service/internal/password"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// checkPassword enforces the password policy on the request field named
+// field. A rejection is InvalidArgument with a BadRequest detail listing
+// every failed rule, its reason code and a description.
+func (s *AuthServer) checkPassword(ctx context.Context, field, pw string, personal ...string) error {
+	violations, err := s.passwords.Check(ctx, pw, personal...)
+	if err != nil {
+		return status.Errorf(codes.Internal, "failed to screen password: %v", err)
+	}
+	if len(violations) == 0 {
+		return nil
+	}
+
+	details := &errdetails.BadRequest{}
+	for _, v := range violations {
+		details.FieldViolations = append(details.FieldViolations, &errdetails.BadRequest_FieldViolation{
+			Field:       field,
+			Description: v.Description,
+			Reason:      v.Reason,
+		})
+	}
+	st := status.New(codes.InvalidArgument, "password does not meet the requirements")
+	if withDetails, err := st.WithDetails(details); err == nil {
+		st = withDetails
+	}
+	return st.Err()
+}
+
+// newPasswordHasher builds the hasher configured in env. Both algorithms stay
+// verifiable, so switching PASSWORD_HASH never locks anyone out.
+func newPasswordHasher(env *config.Env) *password.Hasher {
+	argon := password.DefaultArgon2id
+	argon.Memory = uint32(env.Argon2Memory)
+	argon.Iterations = uint32(env.Argon2Iterations)
+	argon.Parallelism = uint8(env.Argon2Parallelism)
+	bcrypt := password.Bcrypt{Cost: env.BcryptCost}
+
+	if env.PasswordHash == password.AlgBcrypt {
+		return password.NewHasher(bcrypt, argon)
+	}
+	if env.PasswordHash != password.AlgArgon2id {
+		log.Printf("unknown PASSWORD_HASH %q; using %s", env.PasswordHash, password.AlgArgon2id)
+	}
+	return password.NewHasher(argon, bcrypt)
+}
+
+// upgradePasswordHash re-hashes a just-verified password with the current
+// algorithm and parameters. Failures are logged; they must not fail the
+// sign-in.
+func (s *AuthServer) upgradePasswordHash(ctx context.Context, auth *models.Auth, pw string) {
+	hashed, err := s.hasher.Hash(pw)
+	if err != nil {
+		log.Printf("failed to re-hash password of account %d: %v", auth.ID, err)
+		return
+	}
+	replaced, err := s.repo.ReplacePasswordHash(ctx, auth.ID, auth.Password, hashed)
+	if err != nil {
+		log.Printf("failed to upgrade password hash of account %d: %v", auth.ID, err)
+		return
+	}
+	if !replaced {
+		// The hash changed in the meantime; the newer one stays.
+		return
+	}
+	auth.Password = hashed
+}
@@ -0,0 +1,132 @@
+package server
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	pb "go-microservices/proto/auth"
+	"go-microservices/services/auth-service/internal/models"
+	"go-microservices/services/auth-service/internal/rbac"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// AssignRole grants a role to an account. The new permissions appear in the
+// account's access tokens from its next sign-in or refresh. Callers are
+// expected to have checked the roles:manage permission.
+func (s *AuthServer) AssignRole(ctx context.Context, req *pb.AssignRoleRequest) (*pb.AssignRoleResponse, error) {
+	authID, role, err := s.roleTarget(ctx, req.UserId, req.Role)
+	if err != nil {
+		return nil, err
+	}
+
+	assigned, err := s.repo.AssignRole(ctx, authID, role)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to assign role: %v", err)
+	}
+	if !assigned {
+		return &pb.AssignRoleResponse{Success: true, Message: "role already assigned"}, nil
+	}
+	return &pb.AssignRoleResponse{Success: true, Message: "role assigned"}, nil
+}
+
+// RevokeRole takes a role away from an account. The account's outstanding
+// access tokens are invalidated so the lost permissions stop working at
+// once; refresh tokens stay valid and mint tokens with the reduced set.
+func (s *AuthServer) RevokeRole(ctx context.Context, req *pb.RevokeRoleRequest) (*pb.RevokeRoleResponse, error) {
+	authID, role, err := s.roleTarget(ctx, req.UserId, req.Role)
+	if err != nil {
+		return nil, err
+	}
+
+	revoked, err := s.repo.RevokeRole(ctx, authID, role)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to revoke role: %v", err)
+	}
+	if !revoked {
+		return &pb.RevokeRoleResponse{Success: true, Message: "role not assigned"}, nil
+	}
+	if err := s.revoked.RevokeAllForUser(ctx, req.UserId, time.Now()); err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to revoke access tokens: %v", err)
+	}
+	return &pb.RevokeRoleResponse{Success: true, Message: "role revoked"}, nil
+}
+
+// ListRoles returns every defined role, or the roles held by req.UserId when
+// it is set, each with its effective permissions.
+func (s *AuthServer) ListRoles(ctx context.Context, req *pb.ListRolesRequest) (*pb.ListRolesResponse, error) {
+	defined, err := s.repo.ListRoles(ctx)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to list roles: %v", err)
+	}
+
+	selected := defined
+	if req.UserId != "" {
+		u64, err := strconv.ParseUint(req.UserId, 10, 64)
+		if err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "invalid user id: %v", err)
+		}
+		names, err := s.repo.ListUserRoles(ctx, uint(u64))
+		if err != nil {
+			return nil, status.Errorf(codes.Internal, "failed to list roles: %v", err)
+		}
+		held := make(map[string]bool, len(names))
+		for _, n := range names {
+			held[n] = true
+		}
+		selected = nil
+		for _, r := range defined {
+			if held[r.Name] {
+				selected = append(selected, r)
+			}
+		}
+	}
+
+	resp := &pb.ListRolesResponse{Roles: make([]*pb.Role, 0, len(selected))}
+	for _, r := range selected {
+		resp.Roles = append(resp.Roles, &pb.Role{
+			Name:        r.Name,
+			Description: r.Description,
+			Parent:      r.Parent,
+			Permissions: rbac.Effective(defined, []string{r.Name}),
+			RequireMfa:  r.RequireMFA,
+		})
+	}
+	return resp, nil
+}
+
+// roleTarget validates the account and role named in an AssignRole or
+// RevokeRole request.
+func (s *AuthServer) roleTarget(ctx context.Context, userID, role string) (uint, string, error) {
+	u64, err := strconv.ParseUint(userID, 10, 64)
+	if err != nil {
+		return 0, "", status.Errorf(codes.InvalidArgument, "invalid user id: %v", err)
+	}
+	role = rbac.Normalize(role)
+	if role == "" {
+		return 0, "", status.Errorf(codes.InvalidArgument, "role required")
+	}
+
+	defined, err := s.repo.ListRoles(ctx)
+	if err != nil {
+		return 0, "", status.Errorf(codes.Internal, "failed to list roles: %v", err)
+	}
+	if !hasRole(defined, role) {
+		return 0, "", status.Errorf(codes.NotFound, "role %q does not exist", role)
+	}
+	if _, err := s.repo.GetAuthByID(ctx, uint(u64)); err != nil {
+		return 0, "", status.Errorf(codes.NotFound, "user not found")
+	}
+	return uint(u64), role, nil
+}
+
+func hasRole(roles []models.Role, name string) bool {
+	for _, r := range roles {
+		if r.Name == name {
+			return true
+		}
+	}
+	return false
+}
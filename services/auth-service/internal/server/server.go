@@ -3,65 +3,181 @@ package server
 import (
 	"context"
 	"fmt"
+	"log"
 	"strconv"
+	"strings"
 	"time"
 
+	"go-microservices/pkg/events"
 	pb "go-microservices/proto/auth"
+	"go-microservices/services/auth-service/config"
+	"go-microservices/services/auth-service/internal/mailer"
 	"go-microservices/services/auth-service/internal/models"
+	"go-microservices/services/auth-service/internal/outbox"
+	"go-microservices/services/auth-service/internal/password"
+	"go-microservices/services/auth-service/internal/rbac"
 	"go-microservices/services/auth-service/internal/repository"
+	"go-microservices/services/auth-service/internal/revocation"
 	"go-microservices/services/auth-service/internal/utils"
 
-	"golang.org/x/crypto/bcrypt"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
 )
 
+const (
+	// verificationTokenTTL is how long an email confirmation link stays valid.
+	verificationTokenTTL = 24 * time.Hour
+	// resetTokenTTL is how long a password reset link stays valid.
+	resetTokenTTL = time.Hour
+)
+
 type AuthServer struct {
 	pb.UnimplementedAuthServiceServer
-	repo *repository.Repository
+	repo    *repository.Repository
+	revoked revocation.Store
+	mailer  mailer.Mailer
+	env     *config.Env
+
+	breached  password.RangeSource
+	passwords password.Policy
+	hasher    *password.Hasher
 }
 
-func NewAuthServer(repo *repository.Repository) *AuthServer {
-	return &AuthServer{repo: repo}
+func NewAuthServer(repo *repository.Repository, opts ...Option) *AuthServer {
+	s := &AuthServer{repo: repo}
+	for _, opt := range opts {
+		opt(s)
+	}
+	if s.revoked == nil {
+		s.revoked = revocation.NewPostgresStore(repo)
+	}
+	if s.mailer == nil {
+		s.mailer = mailer.NewMemoryMailer()
+	}
+	if s.env == nil {
+		s.env = config.LoadEnv()
+	}
+	s.passwords = password.Policy{
+		MinLength:  s.env.PasswordMinLength,
+		MaxBytes:   s.env.PasswordMaxBytes,
+		MinClasses: s.env.PasswordMinClasses,
+		Breached:   s.breached,
+	}
+	if s.hasher == nil {
+		s.hasher = newPasswordHasher(s.env)
+	}
+	return s
 }
 
 func (s *AuthServer) SignUp(ctx context.Context, req *pb.SignUpRequest) (*pb.SignUpResponse, error) {
-	hashed, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+	if err := s.checkPassword(ctx, "password", req.Password, req.Username, req.Email); err != nil {
+		return nil, err
+	}
+
+	hashed, err := s.hasher.Hash(req.Password)
 	if err != nil {
 		return nil, status.Errorf(codes.Internal, "failed to hash password: %v", err)
 	}
 
+	token := utils.GenerateRandomToken()
 	auth := &models.Auth{
-		Username: req.Username,
-		Email:    req.Email,
-		Password: string(hashed),
-		Role:     "user", // default role (proto SignUpRequest doesn't include role)
+		Username:                req.Username,
+		Email:                   req.Email,
+		Password:                hashed,
+		VerificationToken:       utils.HashToken(token),
+		VerificationTokenExpiry: time.Now().Add(verificationTokenTTL),
 	}
 
-	if err := s.repo.CreateAuth(auth); err != nil {
-		return nil, status.Errorf(codes.Internal, "failed to create user: %v", err)
+	err = s.repo.Transaction(ctx, func(tx *repository.Repository) error {
+		if err := tx.CreateAuth(ctx, auth); err != nil {
+			return accountError(err, "create user")
+		}
+		if _, err := tx.AssignRole(ctx, auth.ID, rbac.RoleUser); err != nil {
+			return status.Errorf(codes.Internal, "failed to assign default role: %v", err)
+		}
+		if s.env.AdminEmail != "" && strings.EqualFold(auth.Email, s.env.AdminEmail) {
+			if _, err := tx.AssignRole(ctx, auth.ID, rbac.RoleAdmin); err != nil {
+				return status.Errorf(codes.Internal, "failed to assign admin role: %v", err)
+			}
+		}
+		userID := fmt.Sprintf("%d", auth.ID)
+		if err := outbox.Record(ctx, tx, events.TypeUserRegistered, userID, events.UserRegistered{
+			UserID:   userID,
+			Username: auth.Username,
+			Email:    auth.Email,
+		}); err != nil {
+			return status.Errorf(codes.Internal, "failed to record registration: %v", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
 
+	s.sendMail(ctx, confirmationEmail(auth.Email, auth.Username, s.frontendLink("/confirm-email", token)))
+
 	userID := fmt.Sprintf("%d", auth.ID)
-	return &pb.SignUpResponse{UserId: userID, Message: "registered"}, nil
+	return &pb.SignUpResponse{UserId: userID, Message: "registered; check your email to confirm your address"}, nil
 }
 
+// SignIn exchanges credentials for a token pair. Failed attempts are counted
+// per account and per client IP: each failure makes the account wait longer
+// before the next attempt, and reaching the threshold locks it temporarily.
 func (s *AuthServer) SignIn(ctx context.Context, req *pb.SignInRequest) (*pb.SignInResponse, error) {
-	auth, err := s.repo.GetAuthByEmail(req.Email)
+	ip := clientIP(ctx)
+	if err := s.checkIPThrottle(ctx, ip); err != nil {
+		return nil, err
+	}
+
+	auth, err := s.repo.GetAuthByEmail(ctx, req.Email)
 	if err != nil || auth == nil {
+		s.recordFailedSignIn(ctx, nil, ip)
 		return nil, status.Errorf(codes.Unauthenticated, "invalid credentials")
 	}
+	if err := s.checkAccountThrottle(auth); err != nil {
+		return nil, err
+	}
 
-	if err := bcrypt.CompareHashAndPassword([]byte(auth.Password), []byte(req.Password)); err != nil {
+	match, rehash, err := s.hasher.Verify(req.Password, auth.Password)
+	if err != nil {
+		log.Printf("failed to verify password of account %d: %v", auth.ID, err)
+	}
+	if !match {
+		s.recordFailedSignIn(ctx, auth, ip)
 		return nil, status.Errorf(codes.Unauthenticated, "invalid credentials")
 	}
+	if rehash {
+		s.upgradePasswordHash(ctx, auth, req.Password)
+	}
 
-	accessToken, refreshToken, err := utils.GenerateJWT(*auth)
-	if err != nil {
-		return nil, status.Errorf(codes.Internal, "failed to generate tokens: %v", err)
+	if s.env.RequireVerifiedEmail && !auth.EmailVerified {
+		return nil, status.Errorf(codes.FailedPrecondition, "email address not confirmed")
 	}
 
 	userID := fmt.Sprintf("%d", auth.ID)
+	if auth.TOTPEnabled {
+		token, err := s.createMFAChallenge(ctx, auth.ID, amrPassword)
+		if err != nil {
+			return nil, err
+		}
+		return &pb.SignInResponse{
+			UserId:      userID,
+			Message:     "second factor required",
+			MfaRequired: true,
+			MfaToken:    token,
+		}, nil
+	}
+
+	// Failures are only forgotten once every factor passed; otherwise a
+	// known password would reset the count of wrong second-factor codes.
+	if err := s.resetLoginFailures(ctx, auth); err != nil {
+		return nil, err
+	}
+	accessToken, refreshToken, err := s.startSession(ctx, auth, amrPassword, false)
+	if err != nil {
+		return nil, err
+	}
+
 	return &pb.SignInResponse{
 		AccessToken:  accessToken,
 		RefreshToken: refreshToken,
@@ -70,29 +186,290 @@ func (s *AuthServer) SignIn(ctx context.Context, req *pb.SignInRequest) (*pb.Sig
 	}, nil
 }
 
+// RefreshToken exchanges a refresh token for a new token pair. Each refresh
+// token may be exchanged once; presenting one that was already rotated is
+// treated as theft and revokes the whole family.
+func (s *AuthServer) RefreshToken(ctx context.Context, req *pb.RefreshTokenRequest) (*pb.RefreshTokenResponse, error) {
+	if req.RefreshToken == "" {
+		return nil, status.Errorf(codes.InvalidArgument, "refresh token required")
+	}
+	if _, err := utils.ValidateJWT(req.RefreshToken, true); err != nil {
+		return nil, status.Errorf(codes.Unauthenticated, "invalid refresh token")
+	}
+
+	stored, err := s.repo.GetRefreshTokenByHash(ctx, utils.HashToken(req.RefreshToken))
+	if err != nil || stored == nil {
+		return nil, status.Errorf(codes.Unauthenticated, "invalid refresh token")
+	}
+	if stored.RevokedAt != nil || time.Now().After(stored.ExpiresAt) {
+		return nil, status.Errorf(codes.Unauthenticated, "invalid refresh token")
+	}
+
+	fresh, err := s.repo.MarkRefreshTokenUsed(ctx, stored.ID)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to rotate refresh token: %v", err)
+	}
+	if !fresh {
+		if err := s.repo.RevokeTokenFamily(ctx, stored.FamilyID); err != nil {
+			return nil, status.Errorf(codes.Internal, "failed to revoke token family: %v", err)
+		}
+		return nil, status.Errorf(codes.Unauthenticated, "refresh token reuse detected")
+	}
+
+	auth, err := s.repo.GetAuthByID(ctx, stored.AuthID)
+	if err != nil || auth == nil {
+		return nil, status.Errorf(codes.Unauthenticated, "invalid refresh token")
+	}
+
+	accessToken, refreshToken, err := s.issueTokens(ctx, auth, stored.FamilyID, stored.Method, stored.MFA)
+	if err != nil {
+		return nil, err
+	}
+	s.touchSession(ctx, stored.FamilyID)
+
+	return &pb.RefreshTokenResponse{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+		UserId:       fmt.Sprintf("%d", auth.ID),
+		Message:      "refreshed",
+	}, nil
+}
+
+// issueTokens mints an access/refresh pair and records the refresh token as
+// the newest member of familyID. method is the first factor of the sign-in
+// that started the family and mfa tells whether it passed a second one.
+func (s *AuthServer) issueTokens(ctx context.Context, auth *models.Auth, familyID, method string, mfa bool) (string, string, error) {
+	roles, perms, err := rbac.Access(ctx, s.repo, auth.ID, mfa)
+	if err != nil {
+		return "", "", status.Errorf(codes.Internal, "failed to load roles: %v", err)
+	}
+	grant := utils.Grant{Roles: roles, Permissions: perms, AMR: sessionAMR(method, mfa), SessionID: familyID}
+	accessToken, refreshToken, err := utils.GenerateJWT(*auth, grant)
+	if err != nil {
+		return "", "", status.Errorf(codes.Internal, "failed to generate tokens: %v", err)
+	}
+
+	record := &models.RefreshToken{
+		AuthID:    auth.ID,
+		FamilyID:  familyID,
+		TokenHash: utils.HashToken(refreshToken),
+		ExpiresAt: time.Now().Add(utils.RefreshTokenTTL),
+		MFA:       mfa,
+		Method:    method,
+	}
+	if err := s.repo.CreateRefreshToken(ctx, record); err != nil {
+		return "", "", status.Errorf(codes.Internal, "failed to store refresh token: %v", err)
+	}
+
+	return accessToken, refreshToken, nil
+}
+
+// SignOut revokes the presented access token and ends the session the
+// refresh token belongs to, revoking its refresh-token family and every
+// access token issued for it. Tokens that no longer validate are ignored so
+// the call is idempotent.
+func (s *AuthServer) SignOut(ctx context.Context, req *pb.SignOutRequest) (*pb.SignOutResponse, error) {
+	if req.AccessToken == "" && req.RefreshToken == "" {
+		return nil, status.Errorf(codes.InvalidArgument, "access or refresh token required")
+	}
+
+	if req.AccessToken != "" {
+		if claims, err := utils.ValidateJWT(req.AccessToken, false); err == nil {
+			if err := s.revokeClaims(ctx, claims); err != nil {
+				return nil, status.Errorf(codes.Internal, "failed to revoke access token: %v", err)
+			}
+		}
+	}
+
+	if req.RefreshToken != "" {
+		if claims, err := utils.ValidateJWT(req.RefreshToken, true); err == nil {
+			if err := s.revokeClaims(ctx, claims); err != nil {
+				return nil, status.Errorf(codes.Internal, "failed to revoke refresh token: %v", err)
+			}
+			stored, err := s.repo.GetRefreshTokenByHash(ctx, utils.HashToken(req.RefreshToken))
+			if err == nil && stored != nil {
+				if err := s.repo.RevokeTokenFamily(ctx, stored.FamilyID); err != nil {
+					return nil, status.Errorf(codes.Internal, "failed to revoke token family: %v", err)
+				}
+				if err := s.revoked.Revoke(ctx, sessionRevocationKey(stored.FamilyID), time.Now().Add(utils.AccessTokenTTL)); err != nil {
+					return nil, status.Errorf(codes.Internal, "failed to revoke access tokens: %v", err)
+				}
+			}
+		}
+	}
+
+	return &pb.SignOutResponse{Success: true, Message: "signed out"}, nil
+}
+
+// RevokeAllSessions invalidates every token issued to the user so far,
+// signing them out everywhere.
+func (s *AuthServer) RevokeAllSessions(ctx context.Context, req *pb.RevokeAllSessionsRequest) (*pb.RevokeAllSessionsResponse, error) {
+	if err := rejectImpersonation(ctx); err != nil {
+		return nil, err
+	}
+	if req.UserId == "" {
+		return nil, status.Errorf(codes.InvalidArgument, "user id required")
+	}
+	u64, err := strconv.ParseUint(req.UserId, 10, 64)
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid user id: %v", err)
+	}
+
+	if err := s.revokeAllSessions(ctx, uint(u64)); err != nil {
+		return nil, err
+	}
+
+	return &pb.RevokeAllSessionsResponse{Success: true, Message: "all sessions revoked"}, nil
+}
+
+// revokeAllSessions invalidates every access token issued to the account so
+// far and revokes all of its refresh-token families.
+func (s *AuthServer) revokeAllSessions(ctx context.Context, authID uint) error {
+	if err := s.revoked.RevokeAllForUser(ctx, fmt.Sprintf("%d", authID), time.Now()); err != nil {
+		return status.Errorf(codes.Internal, "failed to revoke sessions: %v", err)
+	}
+	if err := s.repo.RevokeTokenFamiliesForAuth(ctx, authID); err != nil {
+		return status.Errorf(codes.Internal, "failed to revoke refresh tokens: %v", err)
+	}
+	return nil
+}
+
 func (s *AuthServer) ValidateToken(ctx context.Context, req *pb.ValidateTokenRequest) (*pb.ValidateTokenResponse, error) {
 	claims, err := utils.ValidateJWT(req.Token, false)
 	if err != nil {
 		return &pb.ValidateTokenResponse{Valid: false, UserId: "", Message: "invalid token"}, nil
 	}
 
-	var userID string
-	if sub, ok := claims["sub"]; ok {
-		switch v := sub.(type) {
-		case string:
-			userID = v
-		case float64:
-			userID = strconv.FormatFloat(v, 'f', 0, 64)
-		default:
-			userID = fmt.Sprintf("%v", v)
+	userID := claimString(claims, "sub")
+	if userID == "" {
+		return &pb.ValidateTokenResponse{Valid: false, UserId: "", Message: "invalid token"}, nil
+	}
+
+	revoked, err := s.isRevoked(ctx, claims)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to check revocation: %v", err)
+	}
+	if revoked {
+		return &pb.ValidateTokenResponse{Valid: false, UserId: "", Message: "token revoked"}, nil
+	}
+
+	return &pb.ValidateTokenResponse{
+		Valid:       true,
+		UserId:      userID,
+		Message:     "valid",
+		Email:       claimString(claims, "email"),
+		Roles:       claimStrings(claims, "roles"),
+		Permissions: claimStrings(claims, "perms"),
+		Scopes:      strings.Fields(claimString(claims, "scope")),
+		ExpiresAt:   claimUnix(claims, "exp"),
+		IssuedAt:    claimUnix(claims, "iat"),
+		TokenType:   "access",
+		SessionId:   claimString(claims, "sid"),
+		ClientId:    claimString(claims, "client_id"),
+		Amr:         claimStrings(claims, "amr"),
+		ActorId:     claimActor(claims),
+	}, nil
+}
+
+// GetJWKS publishes the public keys that verify asymmetrically signed tokens.
+// In HS256 mode there are no public keys and the set is empty.
+func (s *AuthServer) GetJWKS(ctx context.Context, req *pb.GetJWKSRequest) (*pb.GetJWKSResponse, error) {
+	resp := &pb.GetJWKSResponse{}
+	ring := utils.KeyRing()
+	if ring == nil {
+		return resp, nil
+	}
+	for _, k := range ring.PublicKeys() {
+		resp.Keys = append(resp.Keys, &pb.JWK{
+			Kty: k.Kty,
+			Kid: k.Kid,
+			Use: k.Use,
+			Alg: k.Alg,
+			N:   k.N,
+			E:   k.E,
+			Crv: k.Crv,
+			X:   k.X,
+		})
+	}
+	return resp, nil
+}
+
+// revokeClaims records the token's jti as revoked until the token expires.
+func (s *AuthServer) revokeClaims(ctx context.Context, claims map[string]interface{}) error {
+	jti := claimString(claims, "jti")
+	if jti == "" {
+		return nil
+	}
+	return s.revoked.Revoke(ctx, jti, claimTime(claims, "exp"))
+}
+
+// isRevoked reports whether the token was revoked individually, belongs to a
+// terminated session or was issued before the user's sign-out-everywhere
+// cutoff. iat has second precision, so a token minted in the same second as
+// the cutoff is still accepted. Client credentials tokens name the client
+// rather than an account as their subject, so no account cutoff applies.
+func (s *AuthServer) isRevoked(ctx context.Context, claims map[string]interface{}) (bool, error) {
+	if jti := claimString(claims, "jti"); jti != "" {
+		revoked, err := s.revoked.IsRevoked(ctx, jti)
+		if err != nil || revoked {
+			return revoked, err
+		}
+	}
+	if sid := claimString(claims, "sid"); sid != "" {
+		revoked, err := s.revoked.IsRevoked(ctx, sessionRevocationKey(sid))
+		if err != nil || revoked {
+			return revoked, err
 		}
 	}
 
-	if userID == "" {
-		return &pb.ValidateTokenResponse{Valid: false, UserId: "", Message: "invalid token"}, nil
+	sub := claimString(claims, "sub")
+	if _, err := strconv.ParseUint(sub, 10, 64); err != nil {
+		return false, nil
+	}
+	before, err := s.revoked.RevokedBefore(ctx, sub)
+	if err != nil || before.IsZero() {
+		return false, err
+	}
+	return claimTime(claims, "iat").Unix() < before.Unix(), nil
+}
+
+func claimString(claims map[string]interface{}, key string) string {
+	switch v := claims[key].(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', 0, 64)
+	default:
+		return fmt.Sprintf("%v", v)
+	}
+}
+
+func claimStrings(claims map[string]interface{}, key string) []string {
+	list, _ := claims[key].([]interface{})
+	out := make([]string, 0, len(list))
+	for _, v := range list {
+		if s, ok := v.(string); ok {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+func claimTime(claims map[string]interface{}, key string) time.Time {
+	if v, ok := claims[key].(float64); ok {
+		return time.Unix(int64(v), 0)
 	}
+	return time.Time{}
+}
 
-	return &pb.ValidateTokenResponse{Valid: true, UserId: userID, Message: "valid"}, nil
+// claimUnix returns a time claim in Unix seconds, or 0 when it is missing.
+func claimUnix(claims map[string]interface{}, key string) int64 {
+	if v, ok := claims[key].(float64); ok {
+		return int64(v)
+	}
+	return 0
 }
 
 func (s *AuthServer) GetUserInfo(ctx context.Context, req *pb.GetUserInfoRequest) (*pb.GetUserInfoResponse, error) {
@@ -104,26 +481,155 @@ func (s *AuthServer) GetUserInfo(ctx context.Context, req *pb.GetUserInfoRequest
 	if err != nil {
 		return nil, status.Errorf(codes.InvalidArgument, "invalid user id: %v", err)
 	}
-	user, err := s.repo.GetAuthByID(uint(u64))
+	user, err := s.repo.GetAuthByID(ctx, uint(u64))
 	if err != nil || user == nil {
 		return nil, status.Errorf(codes.NotFound, "user not found")
 	}
+	roles, err := s.repo.ListUserRoles(ctx, user.ID)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to load roles: %v", err)
+	}
 
 	return &pb.GetUserInfoResponse{
 		UserId:   fmt.Sprintf("%d", user.ID),
 		Username: user.Username,
 		Email:    user.Email,
-		Roles:    []string{user.Role},
+		Roles:    roles,
 	}, nil
 }
 
+// ConfirmEmail marks the account owning the confirmation token as verified.
+// Tokens are single-use and expire after verificationTokenTTL.
+func (s *AuthServer) ConfirmEmail(ctx context.Context, req *pb.ConfirmEmailRequest) (*pb.ConfirmEmailResponse, error) {
+	if req.Token == "" {
+		return nil, status.Errorf(codes.InvalidArgument, "token required")
+	}
+	hash := utils.HashToken(req.Token)
+	auth, err := s.repo.GetAuthByVerificationToken(ctx, hash)
+	if err != nil || auth == nil || time.Now().After(auth.VerificationTokenExpiry) {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid or expired token")
+	}
+
+	confirmed, err := s.repo.ConsumeVerificationToken(ctx, auth.ID, hash)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to confirm email: %v", err)
+	}
+	if !confirmed {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid or expired token")
+	}
+
+	return &pb.ConfirmEmailResponse{Success: true}, nil
+}
+
+// ResendConfirmation issues a fresh confirmation link. It reports success
+// whether or not the address belongs to an unconfirmed account so it cannot
+// be used to discover registered emails.
+func (s *AuthServer) ResendConfirmation(ctx context.Context, req *pb.ResendConfirmationRequest) (*pb.ResendConfirmationResponse, error) {
+	if req.Email == "" {
+		return nil, status.Errorf(codes.InvalidArgument, "email required")
+	}
+	resp := &pb.ResendConfirmationResponse{
+		Success: true,
+		Message: "if the address belongs to an unconfirmed account, a confirmation email has been sent",
+	}
+
+	auth, err := s.repo.GetAuthByEmail(ctx, req.Email)
+	if err != nil || auth == nil || auth.EmailVerified {
+		return resp, nil
+	}
+
+	token := utils.GenerateRandomToken()
+	auth.VerificationToken = utils.HashToken(token)
+	auth.VerificationTokenExpiry = time.Now().Add(verificationTokenTTL)
+	if err := s.repo.SaveAuth(ctx, auth); err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to issue confirmation token: %v", err)
+	}
+
+	s.sendMail(ctx, confirmationEmail(auth.Email, auth.Username, s.frontendLink("/confirm-email", token)))
+	return resp, nil
+}
+
+// RequestPasswordReset emails a single-use reset link. It always reports
+// success so the response does not reveal whether the email is registered.
+func (s *AuthServer) RequestPasswordReset(ctx context.Context, req *pb.RequestPasswordResetRequest) (*pb.RequestPasswordResetResponse, error) {
+	if req.Email == "" {
+		return nil, status.Errorf(codes.InvalidArgument, "email required")
+	}
+	resp := &pb.RequestPasswordResetResponse{
+		Success: true,
+		Message: "if the address is registered, a password reset email has been sent",
+	}
+
+	auth, err := s.repo.GetAuthByEmail(ctx, req.Email)
+	if err != nil || auth == nil {
+		return resp, nil
+	}
+
+	token := utils.GenerateRandomToken()
+	auth.ResetToken = utils.HashToken(token)
+	auth.ResetTokenExpiry = time.Now().Add(resetTokenTTL)
+	if err := s.repo.SaveAuth(ctx, auth); err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to issue reset token: %v", err)
+	}
+
+	s.sendMail(ctx, passwordResetEmail(auth.Email, auth.Username, s.frontendLink("/reset-password", token)))
+	return resp, nil
+}
+
+// ResetPassword sets a new password using a token from RequestPasswordReset.
+// The token is consumed and every existing session is revoked.
+func (s *AuthServer) ResetPassword(ctx context.Context, req *pb.ResetPasswordRequest) (*pb.ResetPasswordResponse, error) {
+	if req.Token == "" {
+		return nil, status.Errorf(codes.InvalidArgument, "token required")
+	}
+	if req.NewPassword == "" {
+		return nil, status.Errorf(codes.InvalidArgument, "new password required")
+	}
+
+	hash := utils.HashToken(req.Token)
+	auth, err := s.repo.GetAuthByResetToken(ctx, hash)
+	if err != nil || auth == nil || time.Now().After(auth.ResetTokenExpiry) {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid or expired token")
+	}
+	if err := s.checkPassword(ctx, "new_password", req.NewPassword, auth.Username, auth.Email); err != nil {
+		return nil, err
+	}
+
+	hashed, err := s.hasher.Hash(req.NewPassword)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to hash password: %v", err)
+	}
+
+	consumed, err := s.repo.ConsumeResetToken(ctx, auth.ID, hash)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to consume reset token: %v", err)
+	}
+	if !consumed {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid or expired token")
+	}
+
+	auth.Password = hashed
+	auth.ResetToken = ""
+	auth.ResetTokenExpiry = time.Time{}
+	if err := s.repo.SaveAuth(ctx, auth); err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to reset password: %v", err)
+	}
+
+	if err := s.revokeAllSessions(ctx, auth.ID); err != nil {
+		return nil, err
+	}
+
+	s.sendMail(ctx, passwordChangedEmail(auth.Email, auth.Username, "all of your sessions were"))
+	return &pb.ResetPasswordResponse{Success: true, Message: "password reset"}, nil
+}
+
 // CreateTest creates a simple Test record in the database
 func (s *AuthServer) CreateTest(ctx context.Context, req *pb.CreateTestRequest) (*pb.CreateTestResponse, error) {
 	if req.Content == "" {
 		return nil, status.Errorf(codes.InvalidArgument, "content required")
 	}
 	t := &models.Test{Content: req.Content}
-	if err := s.repo.CreateTest(t); err != nil {
+	if err := s.repo.CreateTest(ctx, t); err != nil {
 		return nil, status.Errorf(codes.Internal, "failed to create test: %v", err)
 	}
 	return &pb.CreateTestResponse{
@@ -137,7 +643,7 @@ func (s *AuthServer) CreateTest(ctx context.Context, req *pb.CreateTestRequest)
 
 // ListTests returns recent test records
 func (s *AuthServer) ListTests(ctx context.Context, req *pb.ListTestsRequest) (*pb.ListTestsResponse, error) {
-	tests, err := s.repo.ListTests()
+	tests, err := s.repo.ListTests(ctx)
 	if err != nil {
 		return nil, status.Errorf(codes.Internal, "failed to list tests: %v", err)
 	}
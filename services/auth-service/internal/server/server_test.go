@@ -0,0 +1,206 @@
+package server
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"go-microservices/pkg/dbtest"
+	pb "go-microservices/proto/auth"
+	"go-microservices/services/auth-service/config"
+	"go-microservices/services/auth-service/internal/database"
+	"go-microservices/services/auth-service/internal/mailer"
+	"go-microservices/services/auth-service/internal/models"
+	"go-microservices/services/auth-service/internal/password"
+	"go-microservices/services/auth-service/internal/rbac"
+	"go-microservices/services/auth-service/internal/repository"
+	"go-microservices/services/auth-service/internal/revocation"
+	"go-microservices/services/auth-service/internal/utils"
+
+	"golang.org/x/crypto/bcrypt"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"gorm.io/gorm"
+)
+
+func newTestServer(t *testing.T) (*AuthServer, *revocation.MemoryStore) {
+	t.Helper()
+	store := revocation.NewMemoryStore()
+	return NewAuthServer(nil, WithRevocationStore(store)), store
+}
+
+// newDBTestServer returns a server backed by a fresh in-memory SQLite
+// database, its in-memory mailer and its configuration.
+func newDBTestServer(t *testing.T, opts ...Option) (*AuthServer, *mailer.MemoryMailer, *config.Env) {
+	t.Helper()
+	db := dbtest.Open(t, database.Migrate)
+	repo := repository.NewRepository(db)
+	if err := rbac.Seed(context.Background(), repo, ""); err != nil {
+		t.Fatalf("seed roles: %v", err)
+	}
+
+	env := config.LoadEnv()
+	env.PasswordHash = password.AlgBcrypt
+	env.BcryptCost = bcrypt.MinCost
+	mail := mailer.NewMemoryMailer()
+	opts = append([]Option{WithConfig(env), WithMailer(mail)}, opts...)
+	return NewAuthServer(repo, opts...), mail, env
+}
+
+func issueAccessToken(t *testing.T, id uint) string {
+	t.Helper()
+	access, _, err := utils.GenerateJWT(models.Auth{Model: gorm.Model{ID: id}, Email: "jane@example.com"}, utils.Grant{Roles: []string{"user"}, Permissions: []string{"users:read"}})
+	if err != nil {
+		t.Fatalf("GenerateJWT: %v", err)
+	}
+	return access
+}
+
+func TestValidateTokenAcceptsFreshToken(t *testing.T) {
+	srv, _ := newTestServer(t)
+	token := issueAccessToken(t, 7)
+
+	resp, err := srv.ValidateToken(context.Background(), &pb.ValidateTokenRequest{Token: token})
+	if err != nil {
+		t.Fatalf("ValidateToken: %v", err)
+	}
+	if !resp.Valid || resp.UserId != "7" {
+		t.Fatalf("expected valid token for user 7, got %+v", resp)
+	}
+}
+
+func TestSignOutRevokesAccessToken(t *testing.T) {
+	srv, _ := newTestServer(t)
+	ctx := context.Background()
+	token := issueAccessToken(t, 7)
+	other := issueAccessToken(t, 7)
+
+	if _, err := srv.SignOut(ctx, &pb.SignOutRequest{AccessToken: token}); err != nil {
+		t.Fatalf("SignOut: %v", err)
+	}
+
+	resp, err := srv.ValidateToken(ctx, &pb.ValidateTokenRequest{Token: token})
+	if err != nil {
+		t.Fatalf("ValidateToken: %v", err)
+	}
+	if resp.Valid {
+		t.Fatal("expected signed-out token to be rejected")
+	}
+
+	resp, err = srv.ValidateToken(ctx, &pb.ValidateTokenRequest{Token: other})
+	if err != nil {
+		t.Fatalf("ValidateToken: %v", err)
+	}
+	if !resp.Valid {
+		t.Fatal("expected unrelated token to remain valid")
+	}
+}
+
+func TestSignOutIsIdempotent(t *testing.T) {
+	srv, _ := newTestServer(t)
+	ctx := context.Background()
+	token := issueAccessToken(t, 7)
+
+	for i := 0; i < 2; i++ {
+		if _, err := srv.SignOut(ctx, &pb.SignOutRequest{AccessToken: token}); err != nil {
+			t.Fatalf("SignOut #%d: %v", i+1, err)
+		}
+	}
+	if _, err := srv.SignOut(ctx, &pb.SignOutRequest{AccessToken: "not-a-jwt"}); err != nil {
+		t.Fatalf("SignOut with invalid token: %v", err)
+	}
+}
+
+func TestRevokeAllForUserRejectsEarlierTokens(t *testing.T) {
+	srv, store := newTestServer(t)
+	ctx := context.Background()
+	token := issueAccessToken(t, 7)
+	otherUser := issueAccessToken(t, 8)
+
+	if err := store.RevokeAllForUser(ctx, "7", time.Now().Add(time.Second)); err != nil {
+		t.Fatalf("RevokeAllForUser: %v", err)
+	}
+
+	resp, err := srv.ValidateToken(ctx, &pb.ValidateTokenRequest{Token: token})
+	if err != nil {
+		t.Fatalf("ValidateToken: %v", err)
+	}
+	if resp.Valid {
+		t.Fatal("expected token issued before the cutoff to be rejected")
+	}
+
+	resp, err = srv.ValidateToken(ctx, &pb.ValidateTokenRequest{Token: otherUser})
+	if err != nil {
+		t.Fatalf("ValidateToken: %v", err)
+	}
+	if !resp.Valid {
+		t.Fatal("expected other users' tokens to remain valid")
+	}
+}
+
+func TestValidateTokenAcceptsClientToken(t *testing.T) {
+	// The database-backed store parses subjects as account IDs; a client's
+	// hex ID must not make validation fail.
+	srv, _, _ := newDBTestServer(t)
+	token, err := utils.GenerateClientToken("3f2a9c", utils.Grant{ClientID: "3f2a9c", Scope: []string{"posts:read"}})
+	if err != nil {
+		t.Fatalf("GenerateClientToken: %v", err)
+	}
+	resp, err := srv.ValidateToken(context.Background(), &pb.ValidateTokenRequest{Token: token})
+	if err != nil {
+		t.Fatalf("ValidateToken: %v", err)
+	}
+	if !resp.Valid || resp.ClientId != "3f2a9c" {
+		t.Fatalf("expected a valid client token, got %+v", resp)
+	}
+}
+
+// signIn signs up an account and returns its first sign-in.
+func signIn(t *testing.T, srv *AuthServer) *pb.SignInResponse {
+	t.Helper()
+	ctx := context.Background()
+	if _, err := srv.SignUp(ctx, &pb.SignUpRequest{Username: "jane", Email: "jane@example.com", Password: "correct horse battery staple"}); err != nil {
+		t.Fatalf("SignUp: %v", err)
+	}
+	resp, err := srv.SignIn(ctx, &pb.SignInRequest{Email: "jane@example.com", Password: "correct horse battery staple"})
+	if err != nil {
+		t.Fatalf("SignIn: %v", err)
+	}
+	return resp
+}
+
+func TestRefreshTokenRotates(t *testing.T) {
+	srv, _, _ := newDBTestServer(t)
+	ctx := context.Background()
+	first := signIn(t, srv).RefreshToken
+
+	resp, err := srv.RefreshToken(ctx, &pb.RefreshTokenRequest{RefreshToken: first})
+	if err != nil {
+		t.Fatalf("RefreshToken: %v", err)
+	}
+	if resp.RefreshToken == "" || resp.RefreshToken == first {
+		t.Fatal("expected a new refresh token")
+	}
+	if _, err := srv.RefreshToken(ctx, &pb.RefreshTokenRequest{RefreshToken: first}); status.Code(err) != codes.Unauthenticated {
+		t.Fatalf("expected a rotated refresh token to be rejected, got %v", err)
+	}
+}
+
+func TestRefreshTokenReuseRevokesFamily(t *testing.T) {
+	srv, _, _ := newDBTestServer(t)
+	ctx := context.Background()
+	first := signIn(t, srv).RefreshToken
+
+	rotated, err := srv.RefreshToken(ctx, &pb.RefreshTokenRequest{RefreshToken: first})
+	if err != nil {
+		t.Fatalf("RefreshToken: %v", err)
+	}
+	// Presenting the old token again looks like theft: the token the
+	// legitimate client just received must stop working too.
+	if _, err := srv.RefreshToken(ctx, &pb.RefreshTokenRequest{RefreshToken: first}); status.Code(err) != codes.Unauthenticated {
+		t.Fatalf("expected reuse to be rejected, got %v", err)
+	}
+	if _, err := srv.RefreshToken(ctx, &pb.RefreshTokenRequest{RefreshToken: rotated.RefreshToken}); status.Code(err) != codes.Unauthenticated {
+		t.Fatalf("expected the rest of the family to be revoked, got %v", err)
+	}
+}
@@ -0,0 +1,164 @@
+package server
+
+import (
+	"context"
+	"log"
+	"time"
+
+	pb "go-microservices/proto/auth"
+	"go-microservices/services/auth-service/internal/models"
+	"go-microservices/services/auth-service/internal/utils"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// maxUserAgentLen bounds the user agent stored with a session.
+const maxUserAgentLen = 512
+
+// Authentication methods reported in the amr claim (RFC 8176). A magic link
+// is a one-time secret sent by email, so it counts as otp.
+const (
+	amrPassword = "pwd"
+	amrOTP      = "otp"
+	amrMFA      = "mfa"
+)
+
+// sessionAMR lists the methods a sign-in used: its first factor and, when a
+// second one was passed, mfa.
+func sessionAMR(method string, mfa bool) []string {
+	amr := []string{method}
+	if mfa {
+		amr = append(amr, amrMFA)
+	}
+	return amr
+}
+
+// ListSessions returns the account's active sessions. The one named by
+// req.CurrentSessionId is flagged as current.
+func (s *AuthServer) ListSessions(ctx context.Context, req *pb.ListSessionsRequest) (*pb.ListSessionsResponse, error) {
+	auth, err := s.authByUserID(ctx, req.UserId)
+	if err != nil {
+		return nil, err
+	}
+	sessions, err := s.repo.ListActiveSessions(ctx, auth.ID)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to list sessions: %v", err)
+	}
+	resp := &pb.ListSessionsResponse{Sessions: make([]*pb.Session, 0, len(sessions))}
+	for _, sess := range sessions {
+		resp.Sessions = append(resp.Sessions, &pb.Session{
+			Id:         sess.ID,
+			UserAgent:  sess.UserAgent,
+			Ip:         sess.IP,
+			CreatedAt:  sess.CreatedAt.Unix(),
+			LastSeenAt: sess.LastSeenAt.Unix(),
+			ExpiresAt:  sess.ExpiresAt.Unix(),
+			Mfa:        sess.MFA,
+			Current:    sess.ID == req.CurrentSessionId,
+		})
+	}
+	return resp, nil
+}
+
+// TerminateSession signs the account out of one session: its refresh tokens
+// are revoked and its access tokens stop validating at once.
+func (s *AuthServer) TerminateSession(ctx context.Context, req *pb.TerminateSessionRequest) (*pb.TerminateSessionResponse, error) {
+	if err := rejectImpersonation(ctx); err != nil {
+		return nil, err
+	}
+	auth, err := s.authByUserID(ctx, req.UserId)
+	if err != nil {
+		return nil, err
+	}
+	ended, err := s.endSession(ctx, auth.ID, req.SessionId)
+	if err != nil {
+		return nil, err
+	}
+	if !ended {
+		return nil, status.Errorf(codes.NotFound, "session not found")
+	}
+	return &pb.TerminateSessionResponse{Success: true, Message: "session terminated"}, nil
+}
+
+// endSession revokes the session's refresh tokens and invalidates its access
+// tokens at once. It reports false when the account has no such session.
+func (s *AuthServer) endSession(ctx context.Context, authID uint, id string) (bool, error) {
+	ended, err := s.repo.EndSession(ctx, authID, id)
+	if err != nil {
+		return false, status.Errorf(codes.Internal, "failed to end session: %v", err)
+	}
+	if !ended {
+		return false, nil
+	}
+	if err := s.revoked.Revoke(ctx, sessionRevocationKey(id), time.Now().Add(utils.AccessTokenTTL)); err != nil {
+		return false, status.Errorf(codes.Internal, "failed to revoke access tokens: %v", err)
+	}
+	return true, nil
+}
+
+// endOtherSessions ends every active session of the account except keep.
+func (s *AuthServer) endOtherSessions(ctx context.Context, authID uint, keep string) error {
+	sessions, err := s.repo.ListActiveSessions(ctx, authID)
+	if err != nil {
+		return status.Errorf(codes.Internal, "failed to list sessions: %v", err)
+	}
+	for _, sess := range sessions {
+		if sess.ID == keep {
+			continue
+		}
+		if _, err := s.endSession(ctx, authID, sess.ID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// startSession records a sign-in from the calling device and issues the
+// session's first token pair. method is the sign-in's first factor.
+func (s *AuthServer) startSession(ctx context.Context, auth *models.Auth, method string, mfa bool) (string, string, error) {
+	now := time.Now()
+	session := &models.Session{
+		ID:         utils.GenerateRandomToken(),
+		AuthID:     auth.ID,
+		UserAgent:  clientUserAgent(ctx),
+		IP:         clientIP(ctx),
+		MFA:        mfa,
+		LastSeenAt: now,
+		ExpiresAt:  now.Add(utils.RefreshTokenTTL),
+	}
+	if err := s.repo.CreateSession(ctx, session); err != nil {
+		return "", "", status.Errorf(codes.Internal, "failed to create session: %v", err)
+	}
+	return s.issueTokens(ctx, auth, session.ID, method, mfa)
+}
+
+// touchSession records that a session refreshed its tokens. Failures are
+// logged; they must not fail the refresh.
+func (s *AuthServer) touchSession(ctx context.Context, id string) {
+	now := time.Now()
+	if err := s.repo.TouchSession(ctx, id, clientIP(ctx), now, now.Add(utils.RefreshTokenTTL)); err != nil {
+		log.Printf("failed to update session %s: %v", id, err)
+	}
+}
+
+// sessionRevocationKey is the revocation store entry that invalidates every
+// access token carrying the session's sid.
+func sessionRevocationKey(id string) string {
+	return "session:" + id
+}
+
+// clientUserAgent returns the caller's user agent as forwarded by the
+// gateway.
+func clientUserAgent(ctx context.Context) string {
+	md, _ := metadata.FromIncomingContext(ctx)
+	ua := md.Get("x-client-user-agent")
+	if len(ua) == 0 {
+		return ""
+	}
+	if len(ua[0]) > maxUserAgentLen {
+		return ua[0][:maxUserAgentLen]
+	}
+	return ua[0]
+}
@@ -0,0 +1,61 @@
+package totp
+
+import (
+	"testing"
+	"time"
+)
+
+// rfcSecret is the SHA-1 seed of RFC 6238 appendix B, "12345678901234567890",
+// base32-encoded.
+const rfcSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+// The RFC lists eight-digit codes; six-digit codes are their last six digits.
+var rfcVectors = []struct {
+	unix int64
+	code string
+}{
+	{59, "287082"},
+	{1111111109, "081804"},
+	{1111111111, "050471"},
+	{1234567890, "005924"},
+	{2000000000, "279037"},
+	{20000000000, "353130"},
+}
+
+func TestCodeMatchesRFC6238(t *testing.T) {
+	for _, v := range rfcVectors {
+		got, err := Code(rfcSecret, Step(time.Unix(v.unix, 0)))
+		if err != nil {
+			t.Fatalf("Code at %d: %v", v.unix, err)
+		}
+		if got != v.code {
+			t.Errorf("Code at %d = %s, want %s", v.unix, got, v.code)
+		}
+	}
+}
+
+func TestValidateAcceptsAdjacentSteps(t *testing.T) {
+	now := time.Unix(1111111111, 0)
+	for _, d := range []time.Duration{-Period, 0, Period} {
+		code, _ := Code(rfcSecret, Step(now.Add(d)))
+		step, ok := Validate(rfcSecret, code, now)
+		if !ok || step != Step(now.Add(d)) {
+			t.Errorf("code %v from now: got step %d ok=%v", d, step, ok)
+		}
+	}
+	code, _ := Code(rfcSecret, Step(now.Add(2*Period)))
+	if _, ok := Validate(rfcSecret, code, now); ok {
+		t.Error("accepted a code two periods ahead")
+	}
+}
+
+func TestValidateRejectsEmptySecret(t *testing.T) {
+	now := time.Now()
+	code, err := Code("", Step(now))
+	if err != nil {
+		t.Fatalf("Code: %v", err)
+	}
+	if _, ok := Validate("", code, now); ok {
+		t.Fatal("accepted a code for an empty secret")
+	}
+}
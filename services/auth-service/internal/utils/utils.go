@@ -2,20 +2,40 @@ package utils
 
 import (
 	"crypto/rand"
+	"crypto/sha256"
 	"encoding/hex"
 	"errors"
 	"log"
 	"os"
+	"strconv"
+	"strings"
 	"time"
 
+	"go-microservices/services/auth-service/internal/keys"
 	"go-microservices/services/auth-service/internal/models"
 
 	"github.com/golang-jwt/jwt/v4"
 )
 
+const (
+	AccessTokenTTL  = 15 * time.Minute
+	RefreshTokenTTL = 7 * 24 * time.Hour
+)
+
+const (
+	tokenTypeAccess  = "access"
+	tokenTypeRefresh = "refresh"
+	tokenTypeID      = "id"
+)
+
 var (
 	accessTokenSecret  []byte
 	refreshTokenSecret []byte
+
+	// keyRing signs tokens asymmetrically when set. HS256 tokens are still
+	// accepted while a JWT secret is configured, so tokens issued before the
+	// switch stay valid until they expire.
+	keyRing *keys.Ring
 )
 
 func init() {
@@ -32,32 +52,58 @@ func init() {
 	refreshTokenSecret = []byte(refresh)
 }
 
-// GenerateJWT generates an access token and refresh token for the provided user.
-// The function expects the provided models.Auth (or models.User) to have ID, Email and Role fields.
-func GenerateJWT(user models.Auth) (string, string, error) {
-	accessClaims := jwt.MapClaims{
-		"sub":   user.ID,
-		"email": user.Email,
-		"role":  user.Role,
-		"exp":   time.Now().Add(15 * time.Minute).Unix(),
-		"iat":   time.Now().Unix(),
-	}
+// UseKeyRing switches token signing from HS256 to the ring's asymmetric keys.
+func UseKeyRing(ring *keys.Ring) {
+	keyRing = ring
+}
+
+// KeyRing returns the ring set by UseKeyRing, or nil in HS256 mode.
+func KeyRing() *keys.Ring {
+	return keyRing
+}
 
-	accessToken := jwt.NewWithClaims(jwt.SigningMethodHS256, accessClaims)
-	signedAccessToken, err := accessToken.SignedString(accessTokenSecret)
+// Grant is what an access token authorizes beyond the user's identity.
+type Grant struct {
+	Roles       []string
+	Permissions []string
+	// AMR lists the authentication methods the session used (RFC 8176),
+	// e.g. "pwd" and "otp".
+	AMR []string
+	// SessionID identifies the sign-in, i.e. the refresh-token family, the
+	// tokens belong to.
+	SessionID string
+	// ClientID and Scope are set on tokens issued to an OAuth client.
+	ClientID string
+	Scope    []string
+	// Actor is set on impersonation tokens to the user ID of the admin
+	// acting as the subject. It becomes the RFC 8693 act claim.
+	Actor string
+}
+
+// GenerateJWT generates an access token and refresh token for the provided user.
+// The access token carries the grant so the gateway can authorize requests
+// without another round-trip.
+func GenerateJWT(user models.Auth, grant Grant) (string, string, error) {
+	signedAccessToken, err := signToken(accessClaims(user.ID, user.Email, grant), accessTokenSecret)
 	if err != nil {
 		log.Println("Error generating access token:", err)
 		return "", "", err
 	}
 
+	// jti keeps refresh tokens unique even when two are minted for the same
+	// user within one second, which rotation relies on.
 	refreshClaims := jwt.MapClaims{
 		"sub": user.ID,
-		"exp": time.Now().Add(7 * 24 * time.Hour).Unix(),
+		"jti": GenerateRandomToken(),
+		"typ": tokenTypeRefresh,
+		"exp": time.Now().Add(RefreshTokenTTL).Unix(),
 		"iat": time.Now().Unix(),
 	}
+	if grant.SessionID != "" {
+		refreshClaims["sid"] = grant.SessionID
+	}
 
-	refreshToken := jwt.NewWithClaims(jwt.SigningMethodHS256, refreshClaims)
-	signedRefreshToken, err := refreshToken.SignedString(refreshTokenSecret)
+	signedRefreshToken, err := signToken(refreshClaims, refreshTokenSecret)
 	if err != nil {
 		log.Println("Error generating refresh token:", err)
 		return "", "", err
@@ -66,20 +112,97 @@ func GenerateJWT(user models.Auth) (string, string, error) {
 	return signedAccessToken, signedRefreshToken, nil
 }
 
+// GenerateAccessToken generates an access token for user without a refresh
+// token, as issued to OAuth clients acting on the user's behalf and to
+// admins impersonating the user.
+func GenerateAccessToken(user models.Auth, grant Grant) (string, error) {
+	return signToken(accessClaims(user.ID, user.Email, grant), accessTokenSecret)
+}
+
+// GenerateClientToken generates an access token for an OAuth client acting as
+// itself. Its subject is the client ID.
+func GenerateClientToken(clientID string, grant Grant) (string, error) {
+	return signToken(accessClaims(clientID, "", grant), accessTokenSecret)
+}
+
+func accessClaims(sub interface{}, email string, grant Grant) jwt.MapClaims {
+	claims := jwt.MapClaims{
+		"sub":   sub,
+		"jti":   GenerateRandomToken(),
+		"typ":   tokenTypeAccess,
+		"email": email,
+		"roles": grant.Roles,
+		"perms": grant.Permissions,
+		"amr":   grant.AMR,
+		"exp":   time.Now().Add(AccessTokenTTL).Unix(),
+		"iat":   time.Now().Unix(),
+	}
+	if grant.SessionID != "" {
+		claims["sid"] = grant.SessionID
+	}
+	if grant.ClientID != "" {
+		claims["client_id"] = grant.ClientID
+		claims["scope"] = strings.Join(grant.Scope, " ")
+	}
+	if grant.Actor != "" {
+		claims["act"] = map[string]interface{}{"sub": grant.Actor}
+	}
+	return claims
+}
+
+// IDToken holds the OpenID Connect specifics of an ID token.
+type IDToken struct {
+	Issuer   string
+	Audience string
+	Nonce    string
+	AMR      []string
+	// Email and Profile add the claims of the email and profile scopes.
+	Email   bool
+	Profile bool
+}
+
+// GenerateIDToken generates an OpenID Connect ID token for user. It carries
+// the identity claims of an access token, but its typ keeps it from being
+// accepted as one. Relying parties verify it with the published JWKS, so it
+// can only be signed by the key ring.
+func GenerateIDToken(user models.Auth, id IDToken) (string, error) {
+	if keyRing == nil {
+		return "", errors.New("ID tokens require an asymmetric signing key")
+	}
+	claims := jwt.MapClaims{
+		"iss": id.Issuer,
+		"sub": strconv.FormatUint(uint64(user.ID), 10),
+		"aud": id.Audience,
+		"typ": tokenTypeID,
+		"amr": id.AMR,
+		"exp": time.Now().Add(AccessTokenTTL).Unix(),
+		"iat": time.Now().Unix(),
+	}
+	if id.Nonce != "" {
+		claims["nonce"] = id.Nonce
+	}
+	if id.Email {
+		claims["email"] = user.Email
+		claims["email_verified"] = user.EmailVerified
+	}
+	if id.Profile {
+		claims["preferred_username"] = user.Username
+	}
+	return signToken(claims, nil)
+}
+
 // ValidateJWT parses and validates the provided token string.
 // If isRefreshToken is true, the refresh secret is used; otherwise the access secret is used.
+// Asymmetrically signed tokens are verified against the key ring by kid.
 func ValidateJWT(tokenString string, isRefreshToken bool) (jwt.MapClaims, error) {
 	secret := accessTokenSecret
+	expectedType := tokenTypeAccess
 	if isRefreshToken {
 		secret = refreshTokenSecret
+		expectedType = tokenTypeRefresh
 	}
 
-	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, jwt.NewValidationError("invalid signing method", jwt.ValidationErrorSignatureInvalid)
-		}
-		return secret, nil
-	})
+	token, err := jwt.Parse(tokenString, keyFunc(secret))
 	if err != nil {
 		return nil, err
 	}
@@ -87,9 +210,55 @@ func ValidateJWT(tokenString string, isRefreshToken bool) (jwt.MapClaims, error)
 	if !ok || !token.Valid {
 		return nil, errors.New("invalid token")
 	}
+
+	// Access and refresh tokens share ring keys, so only typ tells them
+	// apart. Tokens issued before typ existed are HS256 with separate secrets.
+	typ, hasType := claims["typ"].(string)
+	_, isHMAC := token.Method.(*jwt.SigningMethodHMAC)
+	if (hasType && typ != expectedType) || (!hasType && !isHMAC) {
+		return nil, errors.New("invalid token type")
+	}
 	return claims, nil
 }
 
+func signToken(claims jwt.MapClaims, secret []byte) (string, error) {
+	if keyRing == nil {
+		return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(secret)
+	}
+
+	k, err := keyRing.SigningKey()
+	if err != nil {
+		return "", err
+	}
+	token := jwt.NewWithClaims(k.SigningMethod(), claims)
+	token.Header["kid"] = k.ID
+	return token.SignedString(k.Private)
+}
+
+func keyFunc(secret []byte) jwt.Keyfunc {
+	return func(token *jwt.Token) (interface{}, error) {
+		switch token.Method.(type) {
+		case *jwt.SigningMethodHMAC:
+			if keyRing != nil && len(secret) == 0 {
+				return nil, jwt.NewValidationError("HS256 tokens are not accepted", jwt.ValidationErrorSignatureInvalid)
+			}
+			return secret, nil
+		case *jwt.SigningMethodRSA, *jwt.SigningMethodEd25519:
+			if keyRing == nil {
+				return nil, jwt.NewValidationError("invalid signing method", jwt.ValidationErrorSignatureInvalid)
+			}
+			kid, _ := token.Header["kid"].(string)
+			k, ok := keyRing.VerificationKey(kid)
+			if !ok || k.SigningMethod().Alg() != token.Method.Alg() {
+				return nil, jwt.NewValidationError("unknown signing key", jwt.ValidationErrorSignatureInvalid)
+			}
+			return k.Public(), nil
+		default:
+			return nil, jwt.NewValidationError("invalid signing method", jwt.ValidationErrorSignatureInvalid)
+		}
+	}
+}
+
 // GenerateRandomToken returns a 128-bit random hex token (32 chars).
 func GenerateRandomToken() string {
 	bytes := make([]byte, 16)
@@ -99,3 +268,10 @@ func GenerateRandomToken() string {
 	}
 	return hex.EncodeToString(bytes)
 }
+
+// HashToken returns the hex-encoded SHA-256 digest of token, used to store
+// bearer secrets without keeping them in plaintext.
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -2,7 +2,9 @@ package main
 
 import (
 	"fmt"
+	"go-microservices/pkg/grpcx"
 	pb "go-microservices/proto/post"
+	"go-microservices/services/post-service/config"
 	"go-microservices/services/post-service/internal/database"
 	"go-microservices/services/post-service/internal/repository"
 	"go-microservices/services/post-service/internal/server"
@@ -29,9 +31,13 @@ func main() {
 		log.Fatalf("failed to init database: %v", err)
 	}
 
+	env := config.LoadEnv()
+	if env.GatewaySecret == "" {
+		log.Fatal("GATEWAY_SECRET is required")
+	}
 	repo := repository.NewRepository(db)
 
-	grpcServer := grpc.NewServer()
+	grpcServer := grpc.NewServer(grpc.ChainUnaryInterceptor(grpcx.GatewayAuth(env.GatewaySecret), grpcx.UnaryInterceptor))
 	pb.RegisterPostServiceServer(grpcServer, server.NewPostServer(repo))
 	log.Printf("Post Service listening on %s", port)
 	if err := grpcServer.Serve(lis); err != nil {
@@ -6,17 +6,56 @@ import (
 )
 
 type Env struct {
-	Port           int
-	JWTSecret      string
-	TokenDuration  int
-	DatabaseURL    string
-	RedisAddr      string
-	RedisPassword  string
-	RedisDB        int
-	EmailHost      string
-	EmailPort      int
-	EmailUsername  string
-	EmailPassword  string
-	EmailFrom      string
-	FrontendURL    string
-}
\ No newline at end of file
+	Port          int
+	JWTSecret     string
+	TokenDuration int
+	DatabaseURL   string
+	RedisAddr     string
+	RedisPassword string
+	RedisDB       int
+	EmailHost     string
+	EmailPort     int
+	EmailUsername string
+	EmailPassword string
+	EmailFrom     string
+	FrontendURL   string
+
+	// GatewaySecret authenticates the gateway's calls; calls without it are
+	// refused. It must match the gateway's GATEWAY_SECRET.
+	GatewaySecret string
+}
+
+func LoadEnv() *Env {
+	return &Env{
+		Port:          getEnvInt("PORT", 50053),
+		JWTSecret:     os.Getenv("JWT_SECRET"),
+		TokenDuration: getEnvInt("TOKEN_DURATION", 15),
+		DatabaseURL:   os.Getenv("DATABASE_URL"),
+		RedisAddr:     os.Getenv("REDIS_ADDR"),
+		RedisPassword: os.Getenv("REDIS_PASSWORD"),
+		RedisDB:       getEnvInt("REDIS_DB", 0),
+		EmailHost:     os.Getenv("EMAIL_HOST"),
+		EmailPort:     getEnvInt("EMAIL_PORT", 587),
+		EmailUsername: os.Getenv("EMAIL_USERNAME"),
+		EmailPassword: os.Getenv("EMAIL_PASSWORD"),
+		EmailFrom:     os.Getenv("EMAIL_FROM"),
+		FrontendURL:   getEnv("FRONTEND_URL", "http://localhost:3000"),
+
+		GatewaySecret: os.Getenv("GATEWAY_SECRET"),
+	}
+}
+
+func getEnv(key, fallback string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return fallback
+}
+
+func getEnvInt(key string, fallback int) int {
+	v, err := strconv.Atoi(os.Getenv(key))
+	if err != nil {
+		return fallback
+	}
+	return v
+}
@@ -3,6 +3,8 @@ package database
 import (
 	"os"
 
+	"go-microservices/services/post-service/internal/models"
+
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
 )
@@ -13,10 +15,19 @@ func Init() (*gorm.DB, error) {
 		dsn = "host=localhost user=postgres password=postgres dbname=postgres port=5432 sslmode=disable TimeZone=UTC"
 	}
 
-	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{})
+	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{TranslateError: true})
 	if err != nil {
 		return nil, err
 	}
 
+	if err := Migrate(db); err != nil {
+		return nil, err
+	}
+
 	return db, nil
 }
+
+// Migrate creates or updates the tables of every model.
+func Migrate(db *gorm.DB) error {
+	return db.AutoMigrate(&models.Post{})
+}
@@ -1,7 +1,12 @@
 package models
 
-// TODO: Add database models for post service
-// Example models:
-// - Post model for blog posts
-// - Comment model for post comments
-// - Category model for post categorization
\ No newline at end of file
+import (
+	"gorm.io/gorm"
+)
+
+type Post struct {
+	gorm.Model
+	AuthorID string `gorm:"type:varchar(64);index;not null"`
+	Title    string `gorm:"not null"`
+	Content  string `gorm:"type:text"`
+}
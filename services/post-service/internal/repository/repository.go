@@ -1,7 +1,6 @@
 package repository
 
-// TODO: Add database repository implementations
-// Example repositories:
-// - PostRepository for post data access
-// - CommentRepository for comment management
-// - CategoryRepository for category management
\ No newline at end of file
+import "errors"
+
+// ErrNotFound is returned when a post does not exist or was deleted.
+var ErrNotFound = errors.New("record not found")
@@ -1,6 +1,11 @@
 package repository
 
 import (
+	"context"
+	"errors"
+
+	"go-microservices/services/post-service/internal/models"
+
 	"gorm.io/gorm"
 )
 
@@ -11,3 +16,48 @@ type Repository struct {
 func NewRepository(db *gorm.DB) *Repository {
 	return &Repository{DB: db}
 }
+
+func (r *Repository) CreatePost(ctx context.Context, p *models.Post) error {
+	return r.DB.WithContext(ctx).Create(p).Error
+}
+
+func (r *Repository) GetPostByID(ctx context.Context, id uint) (*models.Post, error) {
+	var p models.Post
+	if err := r.DB.WithContext(ctx).First(&p, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	return &p, nil
+}
+
+// UpdatePost applies the non-zero fields of updates to the post.
+func (r *Repository) UpdatePost(ctx context.Context, p *models.Post, updates models.Post) error {
+	return r.DB.WithContext(ctx).Model(p).Updates(updates).Error
+}
+
+// DeletePost soft-deletes the post.
+func (r *Repository) DeletePost(ctx context.Context, id uint) error {
+	res := r.DB.WithContext(ctx).Delete(&models.Post{}, id)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// ListPosts returns one page of posts, newest first, with the total number of posts.
+func (r *Repository) ListPosts(ctx context.Context, offset, limit int) ([]models.Post, int64, error) {
+	var total int64
+	if err := r.DB.WithContext(ctx).Model(&models.Post{}).Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	var posts []models.Post
+	if err := r.DB.WithContext(ctx).Order("created_at desc, id desc").Offset(offset).Limit(limit).Find(&posts).Error; err != nil {
+		return nil, 0, err
+	}
+	return posts, total, nil
+}
@@ -2,12 +2,29 @@ package server
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"go-microservices/pkg/grpcx"
 	pb "go-microservices/proto/post"
+	"go-microservices/services/post-service/internal/models"
 	"go-microservices/services/post-service/internal/repository"
 
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
 	"google.golang.org/protobuf/types/known/emptypb"
 )
 
+const (
+	defaultPageSize = 20
+	maxPageSize     = 100
+
+	// permPostsDelete lets moderators delete other users' posts.
+	permPostsDelete = "posts:delete"
+)
+
 type PostServer struct {
 	pb.UnimplementedPostServiceServer
 	repo *repository.Repository
@@ -17,31 +34,172 @@ func NewPostServer(repo *repository.Repository) *PostServer {
 	return &PostServer{repo: repo}
 }
 
+// CreatePost publishes a post authored by the caller.
 func (s *PostServer) CreatePost(ctx context.Context, req *pb.CreatePostRequest) (*pb.CreatePostResponse, error) {
-	// TODO: Implement create post logic
-	post := &pb.Post{Id: "1", AuthorId: req.AuthorId, Title: req.Title, Content: req.Content, CreatedAt: 0, UpdatedAt: 0}
-	return &pb.CreatePostResponse{Post: post}, nil
+	authorID, _ := caller(ctx)
+	if authorID == "" {
+		return nil, status.Errorf(codes.Unauthenticated, "sign-in required")
+	}
+	if req.Title == "" {
+		return nil, grpcx.BadRequest(grpcx.Violation("title", "is required"))
+	}
+
+	post := &models.Post{AuthorID: authorID, Title: req.Title, Content: req.Content}
+	if err := s.repo.CreatePost(ctx, post); err != nil {
+		return nil, repoError(err, "create post")
+	}
+	return &pb.CreatePostResponse{Post: toProto(post)}, nil
 }
 
 func (s *PostServer) GetPost(ctx context.Context, req *pb.GetPostRequest) (*pb.GetPostResponse, error) {
-	// TODO: Implement get post logic
-	post := &pb.Post{Id: req.Id, AuthorId: "author", Title: "title", Content: "content", CreatedAt: 0, UpdatedAt: 0}
-	return &pb.GetPostResponse{Post: post}, nil
+	id, err := parseID(req.Id)
+	if err != nil {
+		return nil, err
+	}
+	post, err := s.repo.GetPostByID(ctx, id)
+	if err != nil {
+		return nil, repoError(err, "get post")
+	}
+	return &pb.GetPostResponse{Post: toProto(post)}, nil
 }
 
+// UpdatePost changes the title and/or content; empty fields are left as they
+// are. Only the post's author may edit it.
 func (s *PostServer) UpdatePost(ctx context.Context, req *pb.UpdatePostRequest) (*pb.UpdatePostResponse, error) {
-	// TODO: Implement update post logic
-	post := &pb.Post{Id: req.Id, AuthorId: "author", Title: req.Title, Content: req.Content, CreatedAt: 0, UpdatedAt: 0}
-	return &pb.UpdatePostResponse{Post: post}, nil
+	id, err := parseID(req.Id)
+	if err != nil {
+		return nil, err
+	}
+	post, err := s.repo.GetPostByID(ctx, id)
+	if err != nil {
+		return nil, repoError(err, "get post")
+	}
+	if err := authorize(ctx, post, ""); err != nil {
+		return nil, err
+	}
+
+	if err := s.repo.UpdatePost(ctx, post, models.Post{Title: req.Title, Content: req.Content}); err != nil {
+		return nil, repoError(err, "update post")
+	}
+	if post, err = s.repo.GetPostByID(ctx, id); err != nil {
+		return nil, repoError(err, "get post")
+	}
+	return &pb.UpdatePostResponse{Post: toProto(post)}, nil
 }
 
+// DeletePost deletes a post. Authors may delete their own posts; deleting
+// someone else's needs posts:delete.
 func (s *PostServer) DeletePost(ctx context.Context, req *pb.DeletePostRequest) (*emptypb.Empty, error) {
-	// TODO: Implement delete post logic
+	id, err := parseID(req.Id)
+	if err != nil {
+		return nil, err
+	}
+	post, err := s.repo.GetPostByID(ctx, id)
+	if err != nil {
+		return nil, repoError(err, "get post")
+	}
+	if err := authorize(ctx, post, permPostsDelete); err != nil {
+		return nil, err
+	}
+	if err := s.repo.DeletePost(ctx, id); err != nil {
+		return nil, repoError(err, "delete post")
+	}
 	return &emptypb.Empty{}, nil
 }
 
+// ListPosts pages through posts, newest first. page is 1-based; page_size
+// defaults to defaultPageSize and may not exceed maxPageSize.
 func (s *PostServer) ListPosts(ctx context.Context, req *pb.ListPostsRequest) (*pb.ListPostsResponse, error) {
-	// TODO: Implement list posts logic
-	posts := []*pb.Post{}
-	return &pb.ListPostsResponse{Posts: posts}, nil
+	page, pageSize := int(req.Page), int(req.PageSize)
+	if page < 0 {
+		return nil, grpcx.BadRequest(grpcx.Violation("page", "must not be negative"))
+	}
+	if pageSize < 0 || pageSize > maxPageSize {
+		return nil, grpcx.BadRequest(grpcx.Violation("page_size", fmt.Sprintf("must be between 1 and %d", maxPageSize)))
+	}
+	if page == 0 {
+		page = 1
+	}
+	if pageSize == 0 {
+		pageSize = defaultPageSize
+	}
+
+	posts, total, err := s.repo.ListPosts(ctx, (page-1)*pageSize, pageSize)
+	if err != nil {
+		return nil, repoError(err, "list posts")
+	}
+	resp := &pb.ListPostsResponse{
+		Posts:      make([]*pb.Post, 0, len(posts)),
+		TotalCount: total,
+		Page:       int32(page),
+		PageSize:   int32(pageSize),
+	}
+	for i := range posts {
+		resp.Posts = append(resp.Posts, toProto(&posts[i]))
+	}
+	return resp, nil
+}
+
+// authorize allows the post's author, or a caller holding perm when perm is
+// set.
+func authorize(ctx context.Context, post *models.Post, perm string) error {
+	userID, perms := caller(ctx)
+	if userID == "" {
+		return status.Errorf(codes.Unauthenticated, "sign-in required")
+	}
+	if userID == post.AuthorID {
+		return nil
+	}
+	if perm != "" {
+		for _, p := range perms {
+			if p == perm {
+				return nil
+			}
+		}
+		return status.Errorf(codes.PermissionDenied, "only the author or a holder of %s may do this", perm)
+	}
+	return status.Errorf(codes.PermissionDenied, "only the author may do this")
+}
+
+// caller returns the user and permissions the gateway forwarded. Calls not
+// authenticated as coming from the gateway have no caller.
+func caller(ctx context.Context) (string, []string) {
+	if !grpcx.FromGateway(ctx) {
+		return "", nil
+	}
+	md, _ := metadata.FromIncomingContext(ctx)
+	var userID string
+	if ids := md.Get("x-user-id"); len(ids) > 0 {
+		userID = ids[0]
+	}
+	return userID, md.Get("x-user-permissions")
+}
+
+func toProto(p *models.Post) *pb.Post {
+	return &pb.Post{
+		Id:        strconv.FormatUint(uint64(p.ID), 10),
+		AuthorId:  p.AuthorID,
+		Title:     p.Title,
+		Content:   p.Content,
+		CreatedAt: p.CreatedAt.Unix(),
+		UpdatedAt: p.UpdatedAt.Unix(),
+	}
+}
+
+func parseID(id string) (uint, error) {
+	if id == "" {
+		return 0, grpcx.BadRequest(grpcx.Violation("id", "is required"))
+	}
+	n, err := strconv.ParseUint(id, 10, 64)
+	if err != nil {
+		return 0, grpcx.BadRequest(grpcx.Violation("id", "must be a positive integer"))
+	}
+	return uint(n), nil
+}
+
+func repoError(err error, op string) error {
+	if errors.Is(err, repository.ErrNotFound) {
+		return status.Errorf(codes.NotFound, "post not found")
+	}
+	return status.Errorf(codes.Internal, "failed to %s: %v", op, err)
 }
@@ -0,0 +1,98 @@
+package server
+
+import (
+	"context"
+	"testing"
+
+	"go-microservices/pkg/dbtest"
+	"go-microservices/pkg/grpcx/grpcxtest"
+	pb "go-microservices/proto/post"
+	"go-microservices/services/post-service/internal/database"
+	"go-microservices/services/post-service/internal/repository"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func newTestServer(t *testing.T) *PostServer {
+	t.Helper()
+	return NewPostServer(repository.NewRepository(dbtest.Open(t, database.Migrate)))
+}
+
+// as returns a context carrying the caller metadata the gateway forwards.
+func as(userID string, perms ...string) context.Context {
+	pairs := []string{"x-user-id", userID}
+	for _, p := range perms {
+		pairs = append(pairs, "x-user-permissions", p)
+	}
+	return grpcxtest.FromGateway(metadata.Pairs(pairs...))
+}
+
+func TestOnlyAuthorCanUpdatePost(t *testing.T) {
+	srv := newTestServer(t)
+	created, err := srv.CreatePost(as("1"), &pb.CreatePostRequest{Title: "hello"})
+	if err != nil {
+		t.Fatalf("CreatePost: %v", err)
+	}
+	id := created.Post.Id
+
+	for _, ctx := range []context.Context{as("2", "posts:write"), as("3", "posts:write", "posts:delete")} {
+		if _, err := srv.UpdatePost(ctx, &pb.UpdatePostRequest{Id: id, Title: "hijacked"}); status.Code(err) != codes.PermissionDenied {
+			t.Fatalf("expected PermissionDenied for another user, got %v", err)
+		}
+	}
+	// Caller metadata on a call that did not come through the gateway is
+	// not believed.
+	forged := metadata.NewIncomingContext(context.Background(), metadata.Pairs("x-user-id", "1"))
+	if _, err := srv.UpdatePost(forged, &pb.UpdatePostRequest{Id: id, Title: "forged"}); status.Code(err) != codes.Unauthenticated {
+		t.Fatalf("expected forged metadata to be ignored, got %v", err)
+	}
+	resp, err := srv.UpdatePost(as("1"), &pb.UpdatePostRequest{Id: id, Title: "edited"})
+	if err != nil {
+		t.Fatalf("UpdatePost by author: %v", err)
+	}
+	if resp.Post.Title != "edited" {
+		t.Fatalf("expected edited title, got %q", resp.Post.Title)
+	}
+}
+
+func TestDeletePostNeedsAuthorOrModerator(t *testing.T) {
+	srv := newTestServer(t)
+	ids := make([]string, 2)
+	for i := range ids {
+		created, err := srv.CreatePost(as("1"), &pb.CreatePostRequest{Title: "hello"})
+		if err != nil {
+			t.Fatalf("CreatePost: %v", err)
+		}
+		ids[i] = created.Post.Id
+	}
+
+	if _, err := srv.DeletePost(as("2", "posts:write"), &pb.DeletePostRequest{Id: ids[0]}); status.Code(err) != codes.PermissionDenied {
+		t.Fatalf("expected PermissionDenied for another user, got %v", err)
+	}
+	if _, err := srv.DeletePost(as("1"), &pb.DeletePostRequest{Id: ids[0]}); err != nil {
+		t.Fatalf("DeletePost by author: %v", err)
+	}
+	if _, err := srv.DeletePost(as("3", "posts:delete"), &pb.DeletePostRequest{Id: ids[1]}); err != nil {
+		t.Fatalf("DeletePost by moderator: %v", err)
+	}
+	if _, err := srv.DeletePost(context.Background(), &pb.DeletePostRequest{Id: ids[1]}); status.Code(err) != codes.NotFound {
+		t.Fatalf("expected deleted post to be gone, got %v", err)
+	}
+}
+
+func TestCreatePostIsAuthoredByCaller(t *testing.T) {
+	srv := newTestServer(t)
+	created, err := srv.CreatePost(as("5"), &pb.CreatePostRequest{Title: "hello"})
+	if err != nil {
+		t.Fatalf("CreatePost: %v", err)
+	}
+	if created.Post.AuthorId != "5" {
+		t.Fatalf("expected author 5, got %q", created.Post.AuthorId)
+	}
+	forged := metadata.NewIncomingContext(context.Background(), metadata.Pairs("x-user-id", "5"))
+	if _, err := srv.CreatePost(forged, &pb.CreatePostRequest{Title: "hello"}); status.Code(err) != codes.Unauthenticated {
+		t.Fatalf("expected a call from outside the gateway to be refused, got %v", err)
+	}
+}
@@ -1,8 +1,13 @@
 package main
 
 import (
+	"context"
 	"fmt"
+	"go-microservices/pkg/events/transport"
+	"go-microservices/pkg/grpcx"
 	pb "go-microservices/proto/user"
+	"go-microservices/services/user-service/config"
+	"go-microservices/services/user-service/internal/consumer"
 	"go-microservices/services/user-service/internal/database"
 	"go-microservices/services/user-service/internal/repository"
 	"go-microservices/services/user-service/internal/server"
@@ -28,9 +33,36 @@ func main() {
 		log.Fatalf("failed to init database: %v", err)
 	}
 
+	env := config.LoadEnv()
+	if env.GatewaySecret == "" {
+		log.Fatal("GATEWAY_SECRET is required")
+	}
 	repo := repository.NewRepository(db)
 
-	grpcServer := grpc.NewServer()
+	if env.EventsTransport != "" {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		bus, err := transport.Open(ctx, transport.Config{
+			Kind:        env.EventsTransport,
+			PostgresDSN: database.DSN(),
+			NATSURL:     env.NATSURL,
+			Consumer:    "user-service",
+		})
+		if err != nil {
+			log.Fatalf("failed to open events transport: %v", err)
+		}
+		defer bus.Close()
+		go func() {
+			if err := bus.Subscribe(ctx, consumer.New(repo).Handle); err != nil && ctx.Err() == nil {
+				log.Fatalf("account events subscription failed: %v", err)
+			}
+		}()
+		log.Printf("Consuming account events over %s", env.EventsTransport)
+	} else {
+		log.Println("EVENTS_TRANSPORT not set; profiles will not follow account changes")
+	}
+
+	grpcServer := grpc.NewServer(grpc.ChainUnaryInterceptor(grpcx.GatewayAuth(env.GatewaySecret), grpcx.UnaryInterceptor))
 	pb.RegisterUserServiceServer(grpcServer, server.NewUserServer(repo))
 	log.Printf("User Service listening on %s", port)
 	if err := grpcServer.Serve(lis); err != nil {
@@ -0,0 +1,191 @@
+// Package consumer keeps profiles in step with auth-service accounts by
+// applying the account events the auth service publishes.
+package consumer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+
+	"go-microservices/pkg/events"
+	"go-microservices/services/user-service/internal/models"
+	"go-microservices/services/user-service/internal/repository"
+)
+
+type Consumer struct {
+	repo *repository.Repository
+}
+
+func New(repo *repository.Repository) *Consumer {
+	return &Consumer{repo: repo}
+}
+
+// Handle applies one event. Each event is applied at most once: it is
+// recorded in the same transaction as the profile change it causes, and
+// events already recorded are skipped. Events may also arrive out of
+// order; one older than the last applied to the profile, or about an
+// account already deleted, is recorded but changes nothing.
+func (c *Consumer) Handle(ctx context.Context, e events.Event) error {
+	return c.repo.Transaction(ctx, func(tx *repository.Repository) error {
+		fresh, err := tx.MarkEventProcessed(ctx, e.ID, e.Type)
+		if err != nil {
+			return fmt.Errorf("record event %s: %w", e.ID, err)
+		}
+		if !fresh {
+			return nil
+		}
+		switch e.Type {
+		case events.TypeUserRegistered:
+			var p events.UserRegistered
+			if err := e.Decode(&p); err != nil {
+				return err
+			}
+			return userRegistered(ctx, tx, e.Seq, p)
+		case events.TypeEmailChanged:
+			var p events.EmailChanged
+			if err := e.Decode(&p); err != nil {
+				return err
+			}
+			return emailChanged(ctx, tx, e.Seq, p)
+		case events.TypeUsernameChanged:
+			var p events.UsernameChanged
+			if err := e.Decode(&p); err != nil {
+				return err
+			}
+			return usernameChanged(ctx, tx, e.Seq, p)
+		case events.TypeAccountDeleted:
+			var p events.AccountDeleted
+			if err := e.Decode(&p); err != nil {
+				return err
+			}
+			return accountDeleted(ctx, tx, p)
+		default:
+			log.Printf("ignoring event %s of unknown type %q", e.ID, e.Type)
+			return nil
+		}
+	})
+}
+
+// userRegistered creates the account's profile, or adopts a profile that
+// was already created for its email.
+func userRegistered(ctx context.Context, tx *repository.Repository, seq uint64, p events.UserRegistered) error {
+	authID, err := parseAuthID(p.UserID)
+	if err != nil {
+		return err
+	}
+	user, news, err := current(ctx, tx, authID, p.Email, seq)
+	if err != nil || !news {
+		return err
+	}
+	if user == nil {
+		return tx.CreateUser(ctx, &models.User{AuthID: &authID, AccountSeq: seq, Email: p.Email, Username: p.Username})
+	}
+	return tx.UpdateUser(ctx, user, models.User{AuthID: &authID, AccountSeq: seq, Username: p.Username})
+}
+
+// emailChanged moves the profile to the new address, creating it if the
+// registration has not arrived yet.
+func emailChanged(ctx context.Context, tx *repository.Repository, seq uint64, p events.EmailChanged) error {
+	authID, err := parseAuthID(p.UserID)
+	if err != nil {
+		return err
+	}
+	user, news, err := current(ctx, tx, authID, p.OldEmail, seq)
+	if err != nil || !news {
+		return err
+	}
+	if user == nil {
+		if p.Username == "" {
+			return nil
+		}
+		return tx.CreateUser(ctx, &models.User{AuthID: &authID, AccountSeq: seq, Email: p.NewEmail, Username: p.Username})
+	}
+	return tx.UpdateUser(ctx, user, models.User{AuthID: &authID, AccountSeq: seq, Email: p.NewEmail})
+}
+
+// usernameChanged renames the profile, creating it if the registration has
+// not arrived yet.
+func usernameChanged(ctx context.Context, tx *repository.Repository, seq uint64, p events.UsernameChanged) error {
+	authID, err := parseAuthID(p.UserID)
+	if err != nil {
+		return err
+	}
+	user, news, err := current(ctx, tx, authID, p.Email, seq)
+	if err != nil || !news {
+		return err
+	}
+	if user == nil {
+		return tx.CreateUser(ctx, &models.User{AuthID: &authID, AccountSeq: seq, Email: p.Email, Username: p.NewUsername})
+	}
+	return tx.UpdateUser(ctx, user, models.User{AuthID: &authID, AccountSeq: seq, Username: p.NewUsername})
+}
+
+// accountDeleted deletes the profile outright so the email and username
+// can be registered again, and remembers the account so later events about
+// it are ignored.
+func accountDeleted(ctx context.Context, tx *repository.Repository, p events.AccountDeleted) error {
+	authID, err := parseAuthID(p.UserID)
+	if err != nil {
+		return err
+	}
+	if err := tx.MarkAccountDeleted(ctx, authID); err != nil {
+		return err
+	}
+	user, err := profile(ctx, tx, authID, p.Email)
+	if err != nil || user == nil {
+		return err
+	}
+	return tx.PurgeUser(ctx, user.ID)
+}
+
+// current finds the account's profile like profile does, and reports
+// whether an event numbered seq is news to it: events about deleted
+// accounts, and events no newer than the last one applied, are not.
+func current(ctx context.Context, tx *repository.Repository, authID uint, email string, seq uint64) (*models.User, bool, error) {
+	deleted, err := tx.IsAccountDeleted(ctx, authID)
+	if err != nil || deleted {
+		return nil, false, err
+	}
+	user, err := profile(ctx, tx, authID, email)
+	if err != nil {
+		return nil, false, err
+	}
+	if user != nil && seq != 0 && seq <= user.AccountSeq {
+		return user, false, nil
+	}
+	return user, true, nil
+}
+
+// profile finds the account's profile, falling back to email for profiles
+// not yet linked to an account. It returns nil when there is none.
+func profile(ctx context.Context, tx *repository.Repository, authID uint, email string) (*models.User, error) {
+	user, err := tx.GetUserByAuthID(ctx, authID)
+	if err == nil || !errors.Is(err, repository.ErrNotFound) {
+		return user, err
+	}
+	if email == "" {
+		return nil, nil
+	}
+	user, err = tx.GetUserByEmail(ctx, email)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if user.AuthID != nil && *user.AuthID != authID {
+		// The address belongs to another account's profile now.
+		return nil, nil
+	}
+	return user, nil
+}
+
+func parseAuthID(s string) (uint, error) {
+	id, err := strconv.ParseUint(s, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid account id %q", s)
+	}
+	return uint(id), nil
+}
@@ -0,0 +1,114 @@
+package consumer
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"go-microservices/pkg/dbtest"
+	"go-microservices/pkg/events"
+	"go-microservices/services/user-service/internal/database"
+	"go-microservices/services/user-service/internal/models"
+	"go-microservices/services/user-service/internal/repository"
+)
+
+func newTestRepo(t *testing.T) *repository.Repository {
+	t.Helper()
+	return repository.NewRepository(dbtest.Open(t, database.Migrate))
+}
+
+// newBus returns a memory bus delivering to a consumer backed by repo.
+func newBus(t *testing.T, repo *repository.Repository) *events.MemoryBus {
+	t.Helper()
+	bus := events.NewMemoryBus()
+	t.Cleanup(func() { bus.Close() })
+	stop, err := bus.Listen(New(repo).Handle)
+	if err != nil {
+		t.Fatalf("Listen: %v", err)
+	}
+	t.Cleanup(stop)
+	return bus
+}
+
+func event(t *testing.T, id string, seq uint64, typ, key string, payload interface{}) events.Event {
+	t.Helper()
+	e, err := events.New(id, typ, key, time.Now(), payload)
+	if err != nil {
+		t.Fatalf("events.New: %v", err)
+	}
+	e.Seq = seq
+	return e
+}
+
+func publish(t *testing.T, bus *events.MemoryBus, e events.Event) {
+	t.Helper()
+	if err := bus.Publish(context.Background(), e); err != nil {
+		t.Fatalf("publish %s: %v", e.ID, err)
+	}
+}
+
+func profileOf(t *testing.T, repo *repository.Repository, authID uint) *models.User {
+	t.Helper()
+	user, err := repo.GetUserByAuthID(context.Background(), authID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		t.Fatalf("GetUserByAuthID: %v", err)
+	}
+	return user
+}
+
+func TestConsumerFollowsAccountEvents(t *testing.T) {
+	repo := newTestRepo(t)
+	bus := newBus(t, repo)
+
+	// Registration delivered twice: the repeat is recognised and skipped.
+	registered := event(t, "e1", 1, events.TypeUserRegistered, "1", events.UserRegistered{UserID: "1", Username: "jane", Email: "a@example.com"})
+	publish(t, bus, registered)
+	publish(t, bus, event(t, "e2", 2, events.TypeUsernameChanged, "1", events.UsernameChanged{UserID: "1", Email: "a@example.com", OldUsername: "jane", NewUsername: "janed"}))
+	publish(t, bus, registered)
+	var processed int64
+	repo.DB.Model(&models.ProcessedEvent{}).Count(&processed)
+	if processed != 2 {
+		t.Fatalf("expected 2 processed events, got %d", processed)
+	}
+	if user := profileOf(t, repo, 1); user == nil || user.Username != "janed" {
+		t.Fatalf("expected the redelivered registration to be skipped, got %+v", user)
+	}
+
+	// Two email changes arriving newest first: the older one is stale.
+	publish(t, bus, event(t, "e4", 4, events.TypeEmailChanged, "1", events.EmailChanged{UserID: "1", Username: "janed", OldEmail: "b@example.com", NewEmail: "c@example.com"}))
+	publish(t, bus, event(t, "e3", 3, events.TypeEmailChanged, "1", events.EmailChanged{UserID: "1", Username: "janed", OldEmail: "a@example.com", NewEmail: "b@example.com"}))
+	if user := profileOf(t, repo, 1); user == nil || user.Email != "c@example.com" {
+		t.Fatalf("expected the newest email to win, got %+v", user)
+	}
+
+	// Deleting the account removes the profile, and a late event about it
+	// does not bring it back.
+	publish(t, bus, event(t, "e6", 6, events.TypeAccountDeleted, "1", events.AccountDeleted{UserID: "1", Email: "c@example.com"}))
+	if user := profileOf(t, repo, 1); user != nil {
+		t.Fatalf("expected the profile to be deleted, got %+v", user)
+	}
+	publish(t, bus, event(t, "e5", 5, events.TypeUsernameChanged, "1", events.UsernameChanged{UserID: "1", Email: "c@example.com", OldUsername: "janed", NewUsername: "jd"}))
+	if user := profileOf(t, repo, 1); user != nil {
+		t.Fatalf("expected a late event not to recreate the profile, got %+v", user)
+	}
+}
+
+func TestConsumerCreatesProfileWhenRegistrationArrivesLate(t *testing.T) {
+	repo := newTestRepo(t)
+	bus := newBus(t, repo)
+
+	publish(t, bus, event(t, "e2", 2, events.TypeEmailChanged, "7", events.EmailChanged{UserID: "7", Username: "sam", OldEmail: "old@example.com", NewEmail: "new@example.com"}))
+	publish(t, bus, event(t, "e1", 1, events.TypeUserRegistered, "7", events.UserRegistered{UserID: "7", Username: "sam", Email: "old@example.com"}))
+
+	user := profileOf(t, repo, 7)
+	if user == nil || user.Email != "new@example.com" || user.Username != "sam" {
+		t.Fatalf("expected a profile with the changed email, got %+v", user)
+	}
+	if _, err := repo.GetUserByEmail(context.Background(), "old@example.com"); !errors.Is(err, repository.ErrNotFound) {
+		t.Fatalf("expected no profile for the old email, got %v", err)
+	}
+}
@@ -9,20 +9,31 @@ import (
 	"gorm.io/gorm"
 )
 
-func Init() (*gorm.DB, error) {
-	dsn := os.Getenv("DATABASE_URL")
-	if dsn == "" {
-		dsn = "host=localhost user=postgres password=postgres dbname=postgres port=5432 sslmode=disable TimeZone=UTC"
+// DSN returns DATABASE_URL, or a local development database when unset.
+func DSN() string {
+	if dsn := os.Getenv("DATABASE_URL"); dsn != "" {
+		return dsn
 	}
+	return "host=localhost user=postgres password=postgres dbname=postgres port=5432 sslmode=disable TimeZone=UTC"
+}
+
+func Init() (*gorm.DB, error) {
+	dsn := DSN()
 
-	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{})
+	// TranslateError maps unique violations to gorm.ErrDuplicatedKey.
+	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{TranslateError: true})
 	if err != nil {
 		return nil, err
 	}
 
-	if err := db.AutoMigrate(&models.User{}, &models.Client{}); err != nil {
+	if err := Migrate(db); err != nil {
 		return nil, err
 	}
 
 	return db, nil
 }
+
+// Migrate creates or updates the tables of every model.
+func Migrate(db *gorm.DB) error {
+	return db.AutoMigrate(&models.User{}, &models.Client{}, &models.ProcessedEvent{}, &models.DeletedAccount{})
+}
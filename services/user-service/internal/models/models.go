@@ -1,6 +1,8 @@
 package models
 
 import (
+	"time"
+
 	"gorm.io/gorm"
 )
 
@@ -12,16 +14,39 @@ type Client struct {
 	Address string
 }
 
+// User is a profile. AuthID links it to the auth-service account it was
+// created for; profiles created directly through the API have none until
+// an account registers with their email. AccountSeq is the sequence number
+// of the last account event applied to it.
 type User struct {
 	gorm.Model
+	AuthID       *uint  `gorm:"uniqueIndex"`
+	AccountSeq   uint64 `gorm:"not null;default:0"`
 	Email        string `gorm:"uniqueIndex;not null"`
 	Name         string
 	Role         string `gorm:"default:User"`
 	Active       bool   `gorm:"default:true"`
 	Username     string `gorm:"uniqueIndex"`
+	Bio          string `gorm:"type:text"`
+	AvatarURL    string
 	Address      string
 	PhoneNumber  string
 	ProfilePhoto []byte `gorm:"type:bytea"`
 	ClientID     *uint
 	Client       *Client
 }
+
+// ProcessedEvent records a domain event that has been applied, so a
+// redelivered event is recognised and skipped.
+type ProcessedEvent struct {
+	EventID     string `gorm:"primaryKey;type:varchar(64)"`
+	Type        string `gorm:"type:varchar(100);not null"`
+	ProcessedAt time.Time
+}
+
+// DeletedAccount remembers an auth-service account that was deleted, so
+// events about it that arrive late do not bring its profile back.
+type DeletedAccount struct {
+	AuthID    uint `gorm:"primaryKey;autoIncrement:false"`
+	DeletedAt time.Time
+}
@@ -1,7 +1,9 @@
 package repository
 
-// TODO: Add database repository implementations
-// Example repositories:
-// - UserRepository for user data access
-// - ProfileRepository for profile management
-// - PreferenceRepository for user settings
\ No newline at end of file
+import "errors"
+
+// Errors returned by the repository so callers need not depend on GORM.
+var (
+	ErrNotFound  = errors.New("record not found")
+	ErrDuplicate = errors.New("duplicate record")
+)
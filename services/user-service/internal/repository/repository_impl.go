@@ -1,7 +1,14 @@
 package repository
 
 import (
+	"context"
+	"errors"
+	"time"
+
+	"go-microservices/services/user-service/internal/models"
+
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type Repository struct {
@@ -11,3 +18,109 @@ type Repository struct {
 func NewRepository(db *gorm.DB) *Repository {
 	return &Repository{DB: db}
 }
+
+// Transaction runs fn with a repository whose queries all belong to one
+// database transaction, committed if fn returns nil and rolled back
+// otherwise.
+func (r *Repository) Transaction(ctx context.Context, fn func(tx *Repository) error) error {
+	return r.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return fn(&Repository{DB: tx})
+	})
+}
+
+func (r *Repository) CreateUser(ctx context.Context, u *models.User) error {
+	return translate(r.DB.WithContext(ctx).Create(u).Error)
+}
+
+func (r *Repository) GetUserByID(ctx context.Context, id uint) (*models.User, error) {
+	var u models.User
+	if err := r.DB.WithContext(ctx).First(&u, id).Error; err != nil {
+		return nil, translate(err)
+	}
+	return &u, nil
+}
+
+func (r *Repository) GetUserByEmail(ctx context.Context, email string) (*models.User, error) {
+	var u models.User
+	if err := r.DB.WithContext(ctx).Where("email = ?", email).First(&u).Error; err != nil {
+		return nil, translate(err)
+	}
+	return &u, nil
+}
+
+func (r *Repository) GetUserByAuthID(ctx context.Context, authID uint) (*models.User, error) {
+	var u models.User
+	if err := r.DB.WithContext(ctx).Where("auth_id = ?", authID).First(&u).Error; err != nil {
+		return nil, translate(err)
+	}
+	return &u, nil
+}
+
+// UpdateUser applies the non-zero fields of updates to the user.
+func (r *Repository) UpdateUser(ctx context.Context, u *models.User, updates models.User) error {
+	return translate(r.DB.WithContext(ctx).Model(u).Updates(updates).Error)
+}
+
+// DeleteUser soft-deletes the user.
+func (r *Repository) DeleteUser(ctx context.Context, id uint) error {
+	res := r.DB.WithContext(ctx).Delete(&models.User{}, id)
+	if res.Error != nil {
+		return translate(res.Error)
+	}
+	if res.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// PurgeUser permanently deletes the user, freeing its email and username.
+func (r *Repository) PurgeUser(ctx context.Context, id uint) error {
+	return translate(r.DB.WithContext(ctx).Unscoped().Delete(&models.User{}, id).Error)
+}
+
+// MarkEventProcessed records the event as applied. It reports false when it
+// already was, in which case it must not be applied again.
+func (r *Repository) MarkEventProcessed(ctx context.Context, id, typ string) (bool, error) {
+	res := r.DB.WithContext(ctx).Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&models.ProcessedEvent{EventID: id, Type: typ, ProcessedAt: time.Now()})
+	if res.Error != nil {
+		return false, translate(res.Error)
+	}
+	return res.RowsAffected == 1, nil
+}
+
+// MarkAccountDeleted records that the auth-service account no longer exists.
+func (r *Repository) MarkAccountDeleted(ctx context.Context, authID uint) error {
+	return translate(r.DB.WithContext(ctx).Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&models.DeletedAccount{AuthID: authID, DeletedAt: time.Now()}).Error)
+}
+
+// IsAccountDeleted reports whether MarkAccountDeleted was called for the
+// account.
+func (r *Repository) IsAccountDeleted(ctx context.Context, authID uint) (bool, error) {
+	var n int64
+	err := r.DB.WithContext(ctx).Model(&models.DeletedAccount{}).Where("auth_id = ?", authID).Count(&n).Error
+	return n > 0, err
+}
+
+// ListUsers returns one page of users ordered by ID.
+func (r *Repository) ListUsers(ctx context.Context, offset, limit int) ([]models.User, error) {
+	var users []models.User
+	if err := r.DB.WithContext(ctx).Order("id asc").Offset(offset).Limit(limit).Find(&users).Error; err != nil {
+		return nil, err
+	}
+	return users, nil
+}
+
+func translate(err error) error {
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return ErrNotFound
+	case errors.Is(err, gorm.ErrDuplicatedKey):
+		return ErrDuplicate
+	default:
+		return err
+	}
+}
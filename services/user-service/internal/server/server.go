@@ -2,14 +2,27 @@ package server
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"strconv"
 
+	"go-microservices/pkg/grpcx"
 	pb "go-microservices/proto/user"
 
+	"go-microservices/services/user-service/internal/models"
 	"go-microservices/services/user-service/internal/repository"
 
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 	"google.golang.org/protobuf/types/known/emptypb"
 )
 
+const (
+	defaultPageSize = 20
+	maxPageSize     = 100
+)
+
 type UserServer struct {
 	pb.UnimplementedUserServiceServer
 	repo *repository.Repository
@@ -20,30 +33,139 @@ func NewUserServer(repo *repository.Repository) *UserServer {
 }
 
 func (s *UserServer) CreateUser(ctx context.Context, req *pb.CreateUserRequest) (*pb.CreateUserResponse, error) {
-	// TODO: Implement create user logic
-	user := &pb.User{Id: "1", Username: req.Username, Email: req.Email, Bio: req.Bio, AvatarUrl: req.AvatarUrl, CreatedAt: 0, UpdatedAt: 0}
-	return &pb.CreateUserResponse{User: user}, nil
+	var violations []*errdetails.BadRequest_FieldViolation
+	if req.Username == "" {
+		violations = append(violations, grpcx.Violation("username", "is required"))
+	}
+	if req.Email == "" {
+		violations = append(violations, grpcx.Violation("email", "is required"))
+	}
+	if len(violations) > 0 {
+		return nil, grpcx.BadRequest(violations...)
+	}
+
+	user := &models.User{
+		Username:  req.Username,
+		Email:     req.Email,
+		Bio:       req.Bio,
+		AvatarURL: req.AvatarUrl,
+	}
+	if err := s.repo.CreateUser(ctx, user); err != nil {
+		return nil, repoError(err, "create user")
+	}
+	return &pb.CreateUserResponse{User: toProto(user)}, nil
 }
 
 func (s *UserServer) GetUser(ctx context.Context, req *pb.GetUserRequest) (*pb.GetUserResponse, error) {
-	// TODO: Implement get user logic
-	user := &pb.User{Id: req.Id, Username: "user", Email: "user@example.com", Bio: "bio", AvatarUrl: "", CreatedAt: 0, UpdatedAt: 0}
-	return &pb.GetUserResponse{User: user}, nil
+	id, err := parseID(req.Id)
+	if err != nil {
+		return nil, err
+	}
+	user, err := s.repo.GetUserByID(ctx, id)
+	if err != nil {
+		return nil, repoError(err, "get user")
+	}
+	return &pb.GetUserResponse{User: toProto(user)}, nil
 }
 
+// UpdateUser changes the fields set in the request; empty fields are left as they are.
 func (s *UserServer) UpdateUser(ctx context.Context, req *pb.UpdateUserRequest) (*pb.UpdateUserResponse, error) {
-	// TODO: Implement update user logic
-	user := &pb.User{Id: req.Id, Username: req.Username, Email: req.Email, Bio: req.Bio, AvatarUrl: req.AvatarUrl, CreatedAt: 0, UpdatedAt: 0}
-	return &pb.UpdateUserResponse{User: user}, nil
+	id, err := parseID(req.Id)
+	if err != nil {
+		return nil, err
+	}
+	user, err := s.repo.GetUserByID(ctx, id)
+	if err != nil {
+		return nil, repoError(err, "get user")
+	}
+
+	updates := models.User{
+		Username:  req.Username,
+		Email:     req.Email,
+		Bio:       req.Bio,
+		AvatarURL: req.AvatarUrl,
+	}
+	if err := s.repo.UpdateUser(ctx, user, updates); err != nil {
+		return nil, repoError(err, "update user")
+	}
+	if user, err = s.repo.GetUserByID(ctx, id); err != nil {
+		return nil, repoError(err, "get user")
+	}
+	return &pb.UpdateUserResponse{User: toProto(user)}, nil
 }
 
+// DeleteUser soft-deletes the user; the row is kept but no longer returned.
 func (s *UserServer) DeleteUser(ctx context.Context, req *pb.DeleteUserRequest) (*emptypb.Empty, error) {
-	// TODO: Implement delete user logic
+	id, err := parseID(req.Id)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.repo.DeleteUser(ctx, id); err != nil {
+		return nil, repoError(err, "delete user")
+	}
 	return &emptypb.Empty{}, nil
 }
 
+// ListUsers pages through users. page is 1-based; page_size defaults to
+// defaultPageSize and may not exceed maxPageSize.
 func (s *UserServer) ListUsers(ctx context.Context, req *pb.ListUsersRequest) (*pb.ListUsersResponse, error) {
-	// TODO: Implement list users logic
-	users := []*pb.User{}
-	return &pb.ListUsersResponse{Users: users}, nil
+	page, pageSize := int(req.Page), int(req.PageSize)
+	if page < 0 {
+		return nil, grpcx.BadRequest(grpcx.Violation("page", "must not be negative"))
+	}
+	if pageSize < 0 || pageSize > maxPageSize {
+		return nil, grpcx.BadRequest(grpcx.Violation("page_size", fmt.Sprintf("must be between 1 and %d", maxPageSize)))
+	}
+	if page == 0 {
+		page = 1
+	}
+	if pageSize == 0 {
+		pageSize = defaultPageSize
+	}
+
+	users, err := s.repo.ListUsers(ctx, (page-1)*pageSize, pageSize)
+	if err != nil {
+		return nil, repoError(err, "list users")
+	}
+	resp := &pb.ListUsersResponse{Users: make([]*pb.User, 0, len(users))}
+	for i := range users {
+		resp.Users = append(resp.Users, toProto(&users[i]))
+	}
+	return resp, nil
+}
+
+// toProto maps the model onto the API representation. The model's Name,
+// Address, PhoneNumber and ProfilePhoto are not part of the public profile.
+func toProto(u *models.User) *pb.User {
+	return &pb.User{
+		Id:        strconv.FormatUint(uint64(u.ID), 10),
+		Username:  u.Username,
+		Email:     u.Email,
+		Bio:       u.Bio,
+		AvatarUrl: u.AvatarURL,
+		CreatedAt: u.CreatedAt.Unix(),
+		UpdatedAt: u.UpdatedAt.Unix(),
+	}
+}
+
+func parseID(id string) (uint, error) {
+	if id == "" {
+		return 0, grpcx.BadRequest(grpcx.Violation("id", "is required"))
+	}
+	n, err := strconv.ParseUint(id, 10, 64)
+	if err != nil {
+		return 0, grpcx.BadRequest(grpcx.Violation("id", "must be a positive integer"))
+	}
+	return uint(n), nil
+}
+
+func repoError(err error, op string) error {
+	switch {
+	case errors.Is(err, repository.ErrNotFound):
+		return status.Errorf(codes.NotFound, "user not found")
+	case errors.Is(err, repository.ErrDuplicate):
+		return status.Errorf(codes.AlreadyExists, "username or email already taken")
+	default:
+		return status.Errorf(codes.Internal, "failed to %s: %v", op, err)
+	}
 }